@@ -0,0 +1,128 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	ResultOK      = "ok"
+	ResultError   = "error"
+	ResultRefused = "refused"
+)
+
+// Entry is one line of the audit log
+type Entry struct {
+	Time      time.Time `json:"time"`
+	Profile   string    `json:"profile"`
+	Account   string    `json:"account,omitempty"`
+	Operation string    `json:"operation"`
+	Bucket    string    `json:"bucket"`
+	Key       string    `json:"key,omitempty"`
+	Source    string    `json:"source,omitempty"`
+	VersionID string    `json:"version_id,omitempty"`
+	Size      int64     `json:"size,omitempty"`
+	// Encryption is how a written object is encrypted at rest
+	Encryption string `json:"encryption,omitempty"`
+	Result     string `json:"result"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Log appends an Entry as a JSON line for every mutating operation. A nil
+// *Log records nothing, so repositories work without one.
+type Log struct {
+	Path string
+	// Principal names who is making the changes
+	Principal func() (profile, account string)
+
+	mu sync.Mutex
+}
+
+// Record fills in the time, principal and result of e and appends it to the
+// log. Failing to write the log is reported but never fails the operation.
+func (l *Log) Record(e Entry, err error) {
+	result := ResultOK
+	if err != nil {
+		result = ResultError
+	}
+	l.record(e, result, err)
+}
+
+// Refused records an operation that was never sent, e.g. in read-only mode
+func (l *Log) Refused(e Entry, err error) {
+	l.record(e, ResultRefused, err)
+}
+
+func (l *Log) record(e Entry, result string, err error) {
+	if l == nil {
+		return
+	}
+	e.Time = time.Now().UTC()
+	if l.Principal != nil {
+		e.Profile, e.Account = l.Principal()
+	}
+	e.Result = result
+	if err != nil {
+		e.Error = err.Error()
+	}
+	if err := l.write(e); err != nil {
+		log.Printf("could not write audit log: %v", err)
+	}
+}
+
+func (l *Log) write(e Entry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(l.Path), 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// Read returns every entry in the log, oldest first
+func (l *Log) Read() ([]Entry, error) {
+	if l == nil {
+		return nil, nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, err := os.Open(l.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not open audit log: %w", err)
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("could not parse audit log: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
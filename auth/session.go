@@ -0,0 +1,178 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+const (
+	// accountTimeout bounds looking up the account of the credentials
+	accountTimeout = 5 * time.Second
+	// accountRetry is how long a failed lookup of the account is remembered
+	accountRetry = time.Minute
+)
+
+// ErrMFATokenRequired is returned by the credential chain when a role needs
+// a fresh MFA token that has not been entered in the TUI yet.
+var ErrMFATokenRequired = errors.New("mfa token required")
+
+// Session holds the resolved AWS configuration for a profile. Profiles with a
+// role_arn and mfa_serial get their token from the TUI instead of stdin, and
+// the temporary credentials are cached by the SDK until they expire.
+type Session struct {
+	Profile   string
+	MFASerial string
+	Config    aws.Config
+	// EndpointURL replaces the AWS endpoint, e.g. for MinIO or LocalStack
+	EndpointURL string
+	// OnMFARequired is called when the credentials need a token that has not
+	// been entered, such as when they expire mid-session
+	OnMFARequired func()
+
+	mu      sync.Mutex
+	token   string
+	account string
+	// accountFailed is when looking up the account last failed
+	accountFailed time.Time
+}
+
+func NewSession(ctx context.Context, profile, region string) (*Session, error) {
+	s := &Session{Profile: profile}
+	if s.Profile == "" {
+		s.Profile = os.Getenv("AWS_PROFILE")
+	}
+	if s.Profile == "" {
+		s.Profile = "default"
+	}
+
+	opts := []func(*config.LoadOptions) error{
+		config.WithAssumeRoleCredentialOptions(func(o *stscreds.AssumeRoleOptions) {
+			if o.SerialNumber != nil {
+				s.MFASerial = *o.SerialNumber
+			}
+			o.TokenProvider = s.tokenProvider
+		}),
+	}
+	if profile != "" {
+		opts = append(opts, config.WithSharedConfigProfile(profile))
+	}
+	if region != "" {
+		opts = append(opts, config.WithRegion(region))
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("could not load aws config: %w", err)
+	}
+	s.Config = cfg
+	return s, nil
+}
+
+// tokenProvider hands the last entered token to stscreds. Tokens are single
+// use, so once consumed the next refresh has to prompt again.
+func (s *Session) tokenProvider() (string, error) {
+	s.mu.Lock()
+	token := s.token
+	s.token = ""
+	s.mu.Unlock()
+	if token == "" {
+		if s.OnMFARequired != nil {
+			s.OnMFARequired()
+		}
+		return "", ErrMFATokenRequired
+	}
+	return token, nil
+}
+
+// NeedsMFA reports whether the session has no valid credentials and cannot
+// get new ones without an MFA token.
+func (s *Session) NeedsMFA(ctx context.Context) bool {
+	if s.MFASerial == "" {
+		return false
+	}
+	_, err := s.Config.Credentials.Retrieve(ctx)
+	return errors.Is(err, ErrMFATokenRequired)
+}
+
+// Login assumes the profile's role using the given MFA token.
+func (s *Session) Login(ctx context.Context, token string) error {
+	s.mu.Lock()
+	s.token = token
+	s.mu.Unlock()
+
+	if _, err := s.Config.Credentials.Retrieve(ctx); err != nil {
+		return fmt.Errorf("could not assume role: %w", err)
+	}
+	return nil
+}
+
+// Expires returns when the cached credentials expire. ok is false for
+// credentials that never expire, such as static access keys.
+func (s *Session) Expires(ctx context.Context) (expires time.Time, ok bool) {
+	creds, err := s.Config.Credentials.Retrieve(ctx)
+	if err != nil || !creds.CanExpire {
+		return time.Time{}, false
+	}
+	return creds.Expires, true
+}
+
+// SetRegion switches the region new clients are created for.
+func (s *Session) SetRegion(region string) {
+	s.Config.Region = region
+}
+
+// S3Client returns a client for the session's current region.
+func (s *Session) S3Client() *s3.Client {
+	return s3.NewFromConfig(s.Config, func(o *s3.Options) {
+		if s.EndpointURL != "" {
+			o.BaseEndpoint = &s.EndpointURL
+			// Most S3 compatible servers don't support virtual-hosted buckets
+			o.UsePathStyle = true
+		}
+	})
+}
+
+// KMSClient returns a KMS client for the session's region, used to pick keys
+// for SSE-KMS.
+func (s *Session) KMSClient() *kms.Client {
+	return kms.NewFromConfig(s.Config, func(o *kms.Options) {
+		if s.EndpointURL != "" {
+			o.BaseEndpoint = &s.EndpointURL
+		}
+	})
+}
+
+// Account returns the AWS account ID the credentials belong to, or an empty
+// string if it cannot be looked up yet. A failed lookup is not tried again
+// for accountRetry, so callers on every request are not slowed down by it.
+func (s *Session) Account(ctx context.Context) string {
+	s.mu.Lock()
+	account, failed := s.account, s.accountFailed
+	s.mu.Unlock()
+	if account != "" || time.Since(failed) < accountRetry {
+		return account
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, accountTimeout)
+	defer cancel()
+	out, err := sts.NewFromConfig(s.Config).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.accountFailed = time.Now()
+		return ""
+	}
+	s.account = aws.ToString(out.Account)
+	return s.account
+}
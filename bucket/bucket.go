@@ -2,11 +2,12 @@ package bucket
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
-	"os"
 	"time"
 
+	"github.com/Wondrous27/s3-tui/audit"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/charmbracelet/bubbles/list"
@@ -16,13 +17,20 @@ const (
 	DDMMYYYYhhmmss = "2006-01-02 15:04:05"
 )
 
+// ErrReadOnly is returned by every mutating method in read-only mode
+var ErrReadOnly = errors.New("refused in read-only mode")
+
 type S3Repository struct {
 	Client *s3.Client
+	// ReadOnly refuses every call that would modify a bucket
+	ReadOnly bool
+	// Audit records every call that modifies a bucket
+	Audit *audit.Log
 }
 
 type Bucket struct {
-	Name         string
-	CreationDate time.Time
+	Name         string    `json:"name"`
+	CreationDate time.Time `json:"creation_date"`
 }
 
 // Implement the `Item` interface
@@ -38,7 +46,7 @@ func (b Bucket) FilterValue() string { return b.Name }
 func (s S3Repository) GetAllBuckets() ([]list.Item, error) {
 	s3buckets, err := s.Client.ListBuckets(context.TODO(), &s3.ListBucketsInput{})
 	if err != nil {
-		return nil, fmt.Errorf("could not list buckets: %v", err)
+		return nil, fmt.Errorf("could not list buckets: %w", err)
 	}
 
 	var buckets []list.Item
@@ -52,12 +60,19 @@ func (s S3Repository) GetAllBuckets() ([]list.Item, error) {
 }
 
 func (s S3Repository) CreateBucket(bucketName string) error {
-	_, err := s.Client.CreateBucket(context.TODO(), &s3.CreateBucketInput{
-		Bucket: &bucketName,
-		CreateBucketConfiguration: &types.CreateBucketConfiguration{
-			LocationConstraint: types.BucketLocationConstraint(os.Getenv("AWS_REGION")),
-		},
-	})
+	entry := audit.Entry{Operation: "create-bucket", Bucket: bucketName}
+	if err := s.refuse(entry); err != nil {
+		return err
+	}
+	input := &s3.CreateBucketInput{Bucket: &bucketName}
+	// us-east-1 is the default location and is rejected as a constraint
+	if region := s.Client.Options().Region; region != "us-east-1" {
+		input.CreateBucketConfiguration = &types.CreateBucketConfiguration{
+			LocationConstraint: types.BucketLocationConstraint(region),
+		}
+	}
+	_, err := s.Client.CreateBucket(context.TODO(), input)
+	s.Audit.Record(entry, err)
 	if err != nil {
 		log.Printf("Failed to create bucket %s %v", bucketName, err)
 		return fmt.Errorf("could not create bucket %v", err)
@@ -66,10 +81,42 @@ func (s S3Repository) CreateBucket(bucketName string) error {
 }
 
 func (s S3Repository) DeleteBucket(bucketName string) error {
+	entry := audit.Entry{Operation: "delete-bucket", Bucket: bucketName}
+	if err := s.refuse(entry); err != nil {
+		return err
+	}
 	_, err := s.Client.DeleteBucket(context.TODO(), &s3.DeleteBucketInput{Bucket: &bucketName})
+	s.Audit.Record(entry, err)
 	if err != nil {
 		log.Printf("Failed to delete bucket %s %v", bucketName, err)
 		return fmt.Errorf("failed to delete %s: %v", bucketName, err)
 	}
 	return nil
 }
+
+// refuse fails a mutating operation in read-only mode and audits the refusal
+func (s S3Repository) refuse(entry audit.Entry) error {
+	if !s.ReadOnly {
+		return nil
+	}
+	err := fmt.Errorf("could not %s %s: %w", entry.Operation, entry.Bucket, ErrReadOnly)
+	s.Audit.Refused(entry, err)
+	return err
+}
+
+// GetBucketRegion returns the region a bucket lives in, which requests about
+// its objects have to be made to
+func (s S3Repository) GetBucketRegion(bucketName string) (string, error) {
+	out, err := s.Client.GetBucketLocation(context.TODO(), &s3.GetBucketLocationInput{Bucket: &bucketName})
+	if err != nil {
+		return "", fmt.Errorf("could not get the location of %s: %w", bucketName, err)
+	}
+	switch out.LocationConstraint {
+	case "":
+		// Buckets in us-east-1 have no location constraint
+		return "us-east-1", nil
+	case types.BucketLocationConstraintEu:
+		return "eu-west-1", nil
+	}
+	return string(out.LocationConstraint), nil
+}
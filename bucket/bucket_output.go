@@ -1,3 +1,8 @@
 package bucket
 
-func FormatBucket() {}
+import "fmt"
+
+// FormatBucket formats a bucket as one line of a listing, like `aws s3 ls`
+func FormatBucket(bucket Bucket) string {
+	return fmt.Sprintf("%s %s", bucket.CreationDate.Format(DDMMYYYYhhmmss), bucket.Name)
+}
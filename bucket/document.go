@@ -0,0 +1,50 @@
+package bucket
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EncodeDocument writes a bucket configuration as "yaml" or "json" for
+// editing.
+func EncodeDocument(v any, format string) (string, error) {
+	switch format {
+	case "yaml":
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(v); err != nil {
+			return "", err
+		}
+		return buf.String(), nil
+	case "json":
+		data, err := json.MarshalIndent(v, "", "  ")
+		return string(data) + "\n", err
+	}
+	return "", fmt.Errorf("unknown document format %q", format)
+}
+
+// DecodeDocument parses a document written by EncodeDocument, rejecting
+// unknown fields so that typos do not silently drop a setting.
+func DecodeDocument(data, format string, v any) error {
+	switch format {
+	case "yaml":
+		dec := yaml.NewDecoder(bytes.NewBufferString(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(v); err != nil {
+			return fmt.Errorf("not valid YAML: %w", err)
+		}
+		return nil
+	case "json":
+		dec := json.NewDecoder(bytes.NewBufferString(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(v); err != nil {
+			return fmt.Errorf("not valid JSON: %w", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown document format %q", format)
+}
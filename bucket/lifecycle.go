@@ -0,0 +1,385 @@
+package bucket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/Wondrous27/s3-tui/audit"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// LifecycleRule is an editable form of a lifecycle rule, flattening the
+// filter union and the optional numbers of the S3 types.
+type LifecycleRule struct {
+	ID     string           `json:"id,omitempty" yaml:"id,omitempty"`
+	Status string           `json:"status" yaml:"status"`
+	Filter *LifecycleFilter `json:"filter,omitempty" yaml:"filter,omitempty"`
+	// Transitions and Expiration act on current versions
+	Transitions []LifecycleTransition `json:"transitions,omitempty" yaml:"transitions,omitempty"`
+	Expiration  *LifecycleExpiration  `json:"expiration,omitempty" yaml:"expiration,omitempty"`
+	// NoncurrentTransitions and NoncurrentExpiration count days from when a
+	// version stopped being the current one.
+	NoncurrentTransitions      []LifecycleTransition `json:"noncurrent_transitions,omitempty" yaml:"noncurrent_transitions,omitempty"`
+	NoncurrentExpiration       *LifecycleExpiration  `json:"noncurrent_expiration,omitempty" yaml:"noncurrent_expiration,omitempty"`
+	AbortIncompleteUploadsDays int32                 `json:"abort_incomplete_uploads_days,omitempty" yaml:"abort_incomplete_uploads_days,omitempty"`
+}
+
+// LifecycleFilter selects the objects a rule applies to, all conditions must
+// match.
+type LifecycleFilter struct {
+	Prefix          string            `json:"prefix,omitempty" yaml:"prefix,omitempty"`
+	Tags            map[string]string `json:"tags,omitempty" yaml:"tags,omitempty"`
+	SizeGreaterThan int64             `json:"size_greater_than,omitempty" yaml:"size_greater_than,omitempty"`
+	SizeLessThan    int64             `json:"size_less_than,omitempty" yaml:"size_less_than,omitempty"`
+}
+
+type LifecycleTransition struct {
+	Days         int32      `json:"days,omitempty" yaml:"days,omitempty"`
+	Date         *time.Time `json:"date,omitempty" yaml:"date,omitempty"`
+	StorageClass string     `json:"storage_class" yaml:"storage_class"`
+	// NewerVersions keeps that many noncurrent versions from transitioning
+	NewerVersions int32 `json:"newer_versions,omitempty" yaml:"newer_versions,omitempty"`
+}
+
+type LifecycleExpiration struct {
+	Days                      int32      `json:"days,omitempty" yaml:"days,omitempty"`
+	Date                      *time.Time `json:"date,omitempty" yaml:"date,omitempty"`
+	ExpiredObjectDeleteMarker bool       `json:"expired_object_delete_marker,omitempty" yaml:"expired_object_delete_marker,omitempty"`
+	// NewerVersions keeps that many noncurrent versions from expiring
+	NewerVersions int32 `json:"newer_versions,omitempty" yaml:"newer_versions,omitempty"`
+}
+
+type lifecycleDocument struct {
+	Rules []LifecycleRule `json:"rules" yaml:"rules"`
+}
+
+// GetBucketLifecycle returns the lifecycle rules of a bucket, none when the
+// bucket has no lifecycle configuration.
+func (s S3Repository) GetBucketLifecycle(bucketName string) ([]LifecycleRule, error) {
+	out, err := s.Client.GetBucketLifecycleConfiguration(context.TODO(),
+		&s3.GetBucketLifecycleConfigurationInput{Bucket: &bucketName})
+	if isErrorCode(err, "NoSuchLifecycleConfiguration") {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get the lifecycle of %s: %w", bucketName, err)
+	}
+	rules := make([]LifecycleRule, 0, len(out.Rules))
+	for _, rule := range out.Rules {
+		rules = append(rules, lifecycleRuleFromS3(rule))
+	}
+	return rules, nil
+}
+
+// PutBucketLifecycle replaces the lifecycle rules of a bucket once they
+// passed ValidateLifecycle. No rules deletes the configuration.
+func (s S3Repository) PutBucketLifecycle(bucketName string, rules []LifecycleRule) error {
+	if len(rules) == 0 {
+		return s.DeleteBucketLifecycle(bucketName)
+	}
+	entry := audit.Entry{Operation: "put-bucket-lifecycle", Bucket: bucketName}
+	if err := s.refuse(entry); err != nil {
+		return err
+	}
+	if err := ValidateLifecycle(rules); err != nil {
+		return err
+	}
+	s3Rules := make([]types.LifecycleRule, 0, len(rules))
+	for _, rule := range rules {
+		s3Rules = append(s3Rules, rule.toS3())
+	}
+	_, err := s.Client.PutBucketLifecycleConfiguration(context.TODO(), &s3.PutBucketLifecycleConfigurationInput{
+		Bucket:                 &bucketName,
+		LifecycleConfiguration: &types.BucketLifecycleConfiguration{Rules: s3Rules},
+	})
+	s.Audit.Record(entry, err)
+	if err != nil {
+		return fmt.Errorf("could not put the lifecycle of %s: %w", bucketName, err)
+	}
+	return nil
+}
+
+func (s S3Repository) DeleteBucketLifecycle(bucketName string) error {
+	entry := audit.Entry{Operation: "delete-bucket-lifecycle", Bucket: bucketName}
+	if err := s.refuse(entry); err != nil {
+		return err
+	}
+	_, err := s.Client.DeleteBucketLifecycle(context.TODO(), &s3.DeleteBucketLifecycleInput{Bucket: &bucketName})
+	s.Audit.Record(entry, err)
+	if err != nil {
+		return fmt.Errorf("could not delete the lifecycle of %s: %w", bucketName, err)
+	}
+	return nil
+}
+
+// EncodeLifecycle writes rules as a "yaml" or "json" document
+func EncodeLifecycle(rules []LifecycleRule, format string) (string, error) {
+	if rules == nil {
+		rules = []LifecycleRule{}
+	}
+	return EncodeDocument(lifecycleDocument{Rules: rules}, format)
+}
+
+// DecodeLifecycle parses a document written by EncodeLifecycle
+func DecodeLifecycle(data, format string) ([]LifecycleRule, error) {
+	var doc lifecycleDocument
+	if err := DecodeDocument(data, format, &doc); err != nil {
+		return nil, fmt.Errorf("could not read the lifecycle rules: %w", err)
+	}
+	return doc.Rules, nil
+}
+
+// minIATransitionDays is how long objects have to stay before S3 moves them
+// to STANDARD_IA or ONEZONE_IA
+const minIATransitionDays = 30
+
+// isIA reports whether class is one of the infrequent access classes, which
+// S3 only transitions old and large enough objects to
+func isIA(class string) bool {
+	return class == "STANDARD_IA" || class == "ONEZONE_IA"
+}
+
+// ValidateLifecycle reports every mistake S3 would reject the rules for
+func ValidateLifecycle(rules []LifecycleRule) error {
+	var errs []error
+	addf := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+	if len(rules) > 1000 {
+		addf("a bucket has at most 1000 lifecycle rules")
+	}
+	ids := map[string]bool{}
+	for i, rule := range rules {
+		name := fmt.Sprintf("rule %d", i+1)
+		if rule.ID != "" {
+			name = fmt.Sprintf("rule %q", rule.ID)
+			if ids[rule.ID] {
+				addf("%s: the id is used twice", name)
+			}
+			ids[rule.ID] = true
+			if len(rule.ID) > 255 {
+				addf("%s: the id is longer than 255 characters", name)
+			}
+		}
+		if rule.Status != string(types.ExpirationStatusEnabled) && rule.Status != string(types.ExpirationStatusDisabled) {
+			addf("%s: status must be Enabled or Disabled", name)
+		}
+		if len(rule.Transitions) == 0 && rule.Expiration == nil && len(rule.NoncurrentTransitions) == 0 &&
+			rule.NoncurrentExpiration == nil && rule.AbortIncompleteUploadsDays == 0 {
+			addf("%s: has no action", name)
+		}
+		if f := rule.Filter; f != nil && f.SizeLessThan != 0 && f.SizeGreaterThan >= f.SizeLessThan {
+			addf("%s: size_greater_than must be less than size_less_than", name)
+		}
+		for _, t := range rule.Transitions {
+			if !slices.Contains(types.TransitionStorageClass("").Values(), types.TransitionStorageClass(t.StorageClass)) {
+				addf("%s: %q is not a transition storage class", name, t.StorageClass)
+			}
+			if t.Date != nil && t.Days != 0 {
+				addf("%s: a transition has both days and a date", name)
+			}
+			if isIA(t.StorageClass) && t.Date == nil && t.Days < minIATransitionDays {
+				addf("%s: a transition to %s needs at least %d days", name, t.StorageClass, minIATransitionDays)
+			}
+			if t.NewerVersions != 0 {
+				addf("%s: newer_versions only applies to noncurrent transitions", name)
+			}
+		}
+		for _, t := range rule.NoncurrentTransitions {
+			if !slices.Contains(types.TransitionStorageClass("").Values(), types.TransitionStorageClass(t.StorageClass)) {
+				addf("%s: %q is not a transition storage class", name, t.StorageClass)
+			}
+			if t.Date != nil {
+				addf("%s: noncurrent transitions are by days, not date", name)
+			}
+			if isIA(t.StorageClass) && t.Days < minIATransitionDays {
+				addf("%s: a noncurrent transition to %s needs at least %d days", name, t.StorageClass, minIATransitionDays)
+			}
+		}
+		if e := rule.Expiration; e != nil {
+			set := 0
+			for _, isSet := range []bool{e.Days > 0, e.Date != nil, e.ExpiredObjectDeleteMarker} {
+				if isSet {
+					set++
+				}
+			}
+			if set != 1 {
+				addf("%s: expiration needs exactly one of days, date or expired_object_delete_marker", name)
+			}
+			if e.NewerVersions != 0 {
+				addf("%s: newer_versions only applies to the noncurrent expiration", name)
+			}
+		}
+		if e := rule.NoncurrentExpiration; e != nil {
+			if e.Days <= 0 {
+				addf("%s: the noncurrent expiration needs days", name)
+			}
+			if e.Date != nil || e.ExpiredObjectDeleteMarker {
+				addf("%s: the noncurrent expiration is by days only", name)
+			}
+		}
+		if rule.AbortIncompleteUploadsDays < 0 {
+			addf("%s: abort_incomplete_uploads_days cannot be negative", name)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func lifecycleRuleFromS3(r types.LifecycleRule) LifecycleRule {
+	rule := LifecycleRule{ID: aws.ToString(r.ID), Status: string(r.Status)}
+
+	filter := LifecycleFilter{Prefix: aws.ToString(r.Prefix)}
+	switch f := r.Filter.(type) {
+	case *types.LifecycleRuleFilterMemberPrefix:
+		filter.Prefix = f.Value
+	case *types.LifecycleRuleFilterMemberTag:
+		filter.Tags = map[string]string{aws.ToString(f.Value.Key): aws.ToString(f.Value.Value)}
+	case *types.LifecycleRuleFilterMemberObjectSizeGreaterThan:
+		filter.SizeGreaterThan = f.Value
+	case *types.LifecycleRuleFilterMemberObjectSizeLessThan:
+		filter.SizeLessThan = f.Value
+	case *types.LifecycleRuleFilterMemberAnd:
+		filter.Prefix = aws.ToString(f.Value.Prefix)
+		filter.SizeGreaterThan = aws.ToInt64(f.Value.ObjectSizeGreaterThan)
+		filter.SizeLessThan = aws.ToInt64(f.Value.ObjectSizeLessThan)
+		if len(f.Value.Tags) > 0 {
+			filter.Tags = map[string]string{}
+			for _, tag := range f.Value.Tags {
+				filter.Tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+			}
+		}
+	}
+	if filter.Prefix != "" || len(filter.Tags) > 0 || filter.SizeGreaterThan != 0 || filter.SizeLessThan != 0 {
+		rule.Filter = &filter
+	}
+
+	for _, t := range r.Transitions {
+		rule.Transitions = append(rule.Transitions, LifecycleTransition{
+			Days:         aws.ToInt32(t.Days),
+			Date:         t.Date,
+			StorageClass: string(t.StorageClass),
+		})
+	}
+	if e := r.Expiration; e != nil {
+		rule.Expiration = &LifecycleExpiration{
+			Days:                      aws.ToInt32(e.Days),
+			Date:                      e.Date,
+			ExpiredObjectDeleteMarker: aws.ToBool(e.ExpiredObjectDeleteMarker),
+		}
+	}
+	for _, t := range r.NoncurrentVersionTransitions {
+		rule.NoncurrentTransitions = append(rule.NoncurrentTransitions, LifecycleTransition{
+			Days:          aws.ToInt32(t.NoncurrentDays),
+			StorageClass:  string(t.StorageClass),
+			NewerVersions: aws.ToInt32(t.NewerNoncurrentVersions),
+		})
+	}
+	if e := r.NoncurrentVersionExpiration; e != nil {
+		rule.NoncurrentExpiration = &LifecycleExpiration{
+			Days:          aws.ToInt32(e.NoncurrentDays),
+			NewerVersions: aws.ToInt32(e.NewerNoncurrentVersions),
+		}
+	}
+	if a := r.AbortIncompleteMultipartUpload; a != nil {
+		rule.AbortIncompleteUploadsDays = aws.ToInt32(a.DaysAfterInitiation)
+	}
+	return rule
+}
+
+func (rule LifecycleRule) toS3() types.LifecycleRule {
+	r := types.LifecycleRule{
+		Status: types.ExpirationStatus(rule.Status),
+		Filter: rule.Filter.toS3(),
+	}
+	if rule.ID != "" {
+		r.ID = aws.String(rule.ID)
+	}
+	for _, t := range rule.Transitions {
+		transition := types.Transition{Date: t.Date, StorageClass: types.TransitionStorageClass(t.StorageClass)}
+		if t.Date == nil {
+			transition.Days = aws.Int32(t.Days)
+		}
+		r.Transitions = append(r.Transitions, transition)
+	}
+	if e := rule.Expiration; e != nil {
+		r.Expiration = &types.LifecycleExpiration{Date: e.Date, Days: optional(e.Days)}
+		if e.ExpiredObjectDeleteMarker {
+			r.Expiration.ExpiredObjectDeleteMarker = aws.Bool(true)
+		}
+	}
+	for _, t := range rule.NoncurrentTransitions {
+		r.NoncurrentVersionTransitions = append(r.NoncurrentVersionTransitions, types.NoncurrentVersionTransition{
+			NoncurrentDays:          aws.Int32(t.Days),
+			NewerNoncurrentVersions: optional(t.NewerVersions),
+			StorageClass:            types.TransitionStorageClass(t.StorageClass),
+		})
+	}
+	if e := rule.NoncurrentExpiration; e != nil {
+		r.NoncurrentVersionExpiration = &types.NoncurrentVersionExpiration{
+			NoncurrentDays:          aws.Int32(e.Days),
+			NewerNoncurrentVersions: optional(e.NewerVersions),
+		}
+	}
+	if rule.AbortIncompleteUploadsDays > 0 {
+		r.AbortIncompleteMultipartUpload = &types.AbortIncompleteMultipartUpload{
+			DaysAfterInitiation: aws.Int32(rule.AbortIncompleteUploadsDays),
+		}
+	}
+	return r
+}
+
+// toS3 picks the member of the filter union: a single condition is sent as
+// is, several are combined with And.
+func (f *LifecycleFilter) toS3() types.LifecycleRuleFilter {
+	if f == nil {
+		return &types.LifecycleRuleFilterMemberPrefix{}
+	}
+	conditions := len(f.Tags)
+	for _, isSet := range []bool{f.Prefix != "", f.SizeGreaterThan != 0, f.SizeLessThan != 0} {
+		if isSet {
+			conditions++
+		}
+	}
+	if conditions > 1 {
+		and := types.LifecycleRuleAndOperator{
+			ObjectSizeGreaterThan: optional(f.SizeGreaterThan),
+			ObjectSizeLessThan:    optional(f.SizeLessThan),
+		}
+		if f.Prefix != "" {
+			and.Prefix = aws.String(f.Prefix)
+		}
+		keys := make([]string, 0, len(f.Tags))
+		for k := range f.Tags {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			and.Tags = append(and.Tags, types.Tag{Key: aws.String(k), Value: aws.String(f.Tags[k])})
+		}
+		return &types.LifecycleRuleFilterMemberAnd{Value: and}
+	}
+	switch {
+	case f.SizeGreaterThan != 0:
+		return &types.LifecycleRuleFilterMemberObjectSizeGreaterThan{Value: f.SizeGreaterThan}
+	case f.SizeLessThan != 0:
+		return &types.LifecycleRuleFilterMemberObjectSizeLessThan{Value: f.SizeLessThan}
+	}
+	for k, v := range f.Tags {
+		return &types.LifecycleRuleFilterMemberTag{Value: types.Tag{Key: aws.String(k), Value: aws.String(v)}}
+	}
+	return &types.LifecycleRuleFilterMemberPrefix{Value: f.Prefix}
+}
+
+// optional leaves zero values out of a request
+func optional[T int32 | int64](v T) *T {
+	if v == 0 {
+		return nil
+	}
+	return &v
+}
@@ -0,0 +1,226 @@
+package bucket
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Wondrous27/s3-tui/object"
+	"github.com/Wondrous27/s3-tui/utils"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// LifecycleEvent is an action a lifecycle rule takes on an object
+type LifecycleEvent struct {
+	Key    string
+	Size   int64
+	RuleID string
+	// StorageClass is the target of a transition, empty for an expiration
+	StorageClass string
+	When         time.Time
+}
+
+// LifecycleSimulation is what the rules do to a listing in the coming days
+type LifecycleSimulation struct {
+	Days   int
+	Events []LifecycleEvent
+	// NotSimulated lists the rules left out of the simulation, and why
+	NotSimulated []string
+	// Notes explains the parts of the rules the listing cannot simulate
+	Notes []string
+}
+
+// minIATransitionSize is the size below which S3 does not transition
+// objects to STANDARD_IA or ONEZONE_IA
+const minIATransitionSize = 128 << 10
+
+// storageClassTiers orders the storage classes lifecycle transitions move
+// objects down through. S3 never moves an object back up, e.g. from GLACIER
+// to STANDARD_IA.
+var storageClassTiers = map[string]int{
+	"STANDARD":            0,
+	"REDUCED_REDUNDANCY":  0,
+	"STANDARD_IA":         1,
+	"INTELLIGENT_TIERING": 2,
+	"ONEZONE_IA":          3,
+	"GLACIER_IR":          4,
+	"GLACIER":             5,
+	"DEEP_ARCHIVE":        6,
+}
+
+// SimulateLifecycle runs the enabled rules against the current versions in
+// objects and returns the events due within days of now, earliest first.
+// Like S3, an expiration wins over transitions due at the same time or later,
+// transitions only move objects down the storage class tiers, the deepest
+// winning when several are due at once, and objects under 128 KiB are not
+// moved to STANDARD_IA or ONEZONE_IA.
+func SimulateLifecycle(rules []LifecycleRule, objects []object.Object, now time.Time, days int) LifecycleSimulation {
+	sim := LifecycleSimulation{Days: days}
+	horizon := now.AddDate(0, 0, days)
+
+	var active []LifecycleRule
+	for i, rule := range rules {
+		if rule.ID == "" {
+			rule.ID = fmt.Sprintf("rule %d", i+1)
+		}
+		if rule.Status != string(types.ExpirationStatusEnabled) {
+			continue
+		}
+		if rule.Filter != nil && len(rule.Filter.Tags) > 0 {
+			sim.NotSimulated = append(sim.NotSimulated, fmt.Sprintf("%s filters on tags, which the listing does not include", rule.ID))
+			continue
+		}
+		if len(rule.NoncurrentTransitions) > 0 || rule.NoncurrentExpiration != nil {
+			sim.Notes = append(sim.Notes, fmt.Sprintf("%s acts on noncurrent versions, which the listing does not include", rule.ID))
+		}
+		if rule.AbortIncompleteUploadsDays > 0 {
+			sim.Notes = append(sim.Notes, fmt.Sprintf("%s aborts incomplete uploads, which the listing does not include", rule.ID))
+		}
+		active = append(active, rule)
+	}
+
+	tooSmall := 0
+	for _, obj := range objects {
+		var expiration *LifecycleEvent
+		var transitions []LifecycleEvent
+		for _, rule := range active {
+			if !rule.Filter.matches(obj) {
+				continue
+			}
+			if e := rule.Expiration; e != nil && !e.ExpiredObjectDeleteMarker {
+				when := lifecycleDue(obj.LastModified, e.Days, e.Date, now)
+				if expiration == nil || when.Before(expiration.When) {
+					expiration = &LifecycleEvent{Key: obj.Key, Size: obj.Size, RuleID: rule.ID, When: when}
+				}
+			}
+			for _, t := range rule.Transitions {
+				transitions = append(transitions, LifecycleEvent{
+					Key:          obj.Key,
+					Size:         obj.Size,
+					RuleID:       rule.ID,
+					StorageClass: t.StorageClass,
+					When:         lifecycleDue(obj.LastModified, t.Days, t.Date, now),
+				})
+			}
+		}
+		sort.SliceStable(transitions, func(i, j int) bool {
+			if !transitions[i].When.Equal(transitions[j].When) {
+				return transitions[i].When.Before(transitions[j].When)
+			}
+			return storageClassTiers[transitions[i].StorageClass] > storageClassTiers[transitions[j].StorageClass]
+		})
+
+		class := string(obj.StorageClass)
+		if class == "" {
+			class = string(types.ObjectStorageClassStandard)
+		}
+		tier, known := storageClassTiers[class]
+		small := false
+		for _, t := range transitions {
+			if !known || t.When.After(horizon) || expiration != nil && !t.When.Before(expiration.When) {
+				break
+			}
+			if storageClassTiers[t.StorageClass] <= tier {
+				continue
+			}
+			if obj.Size < minIATransitionSize && isIA(t.StorageClass) {
+				small = true
+				continue
+			}
+			sim.Events = append(sim.Events, t)
+			tier = storageClassTiers[t.StorageClass]
+		}
+		if small {
+			tooSmall++
+		}
+		if expiration != nil && !expiration.When.After(horizon) {
+			sim.Events = append(sim.Events, *expiration)
+		}
+	}
+	if tooSmall > 0 {
+		sim.Notes = append(sim.Notes, fmt.Sprintf("%d objects are under 128 KiB, which S3 does not transition to STANDARD_IA or ONEZONE_IA", tooSmall))
+	}
+
+	sort.SliceStable(sim.Events, func(i, j int) bool {
+		return sim.Events[i].When.Before(sim.Events[j].When)
+	})
+	return sim
+}
+
+// lifecycleDue is when S3 acts on an object: at date, or days after the
+// object was written rounded up to the next midnight UTC. Actions already
+// due happen now.
+func lifecycleDue(modified time.Time, days int32, date *time.Time, now time.Time) time.Time {
+	due := modified.UTC().AddDate(0, 0, int(days))
+	if date != nil {
+		due = *date
+	} else if midnight := due.Truncate(24 * time.Hour); !midnight.Equal(due) {
+		due = midnight.AddDate(0, 0, 1)
+	}
+	if due.Before(now) {
+		return now
+	}
+	return due
+}
+
+func (f *LifecycleFilter) matches(obj object.Object) bool {
+	if f == nil {
+		return true
+	}
+	return strings.HasPrefix(obj.Key, f.Prefix) &&
+		(f.SizeGreaterThan == 0 || obj.Size > f.SizeGreaterThan) &&
+		(f.SizeLessThan == 0 || obj.Size < f.SizeLessThan)
+}
+
+// FormatLifecycleSimulation summarises the bytes affected per action and
+// lists the events.
+func FormatLifecycleSimulation(sim LifecycleSimulation) string {
+	type total struct {
+		count int
+		bytes int64
+	}
+	totals := map[string]*total{}
+	var actions []string
+	for _, e := range sim.Events {
+		action := e.action()
+		if totals[action] == nil {
+			totals[action] = &total{}
+			actions = append(actions, action)
+		}
+		totals[action].count++
+		totals[action].bytes += e.Size
+	}
+	sort.Strings(actions)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "In the next %d days:\n\n", sim.Days)
+	if len(sim.Events) == 0 {
+		sb.WriteString("  nothing happens\n")
+	}
+	for _, action := range actions {
+		t := totals[action]
+		fmt.Fprintf(&sb, "  %-32s %6d objects %12s\n", action, t.count, utils.HumanSize(t.bytes))
+	}
+	for _, rule := range sim.NotSimulated {
+		fmt.Fprintf(&sb, "\nnot simulated: %s", rule)
+	}
+	for _, note := range sim.Notes {
+		fmt.Fprintf(&sb, "\nnote: %s", note)
+	}
+	if len(sim.Events) > 0 {
+		sb.WriteString("\n\n")
+	}
+	for _, e := range sim.Events {
+		fmt.Fprintf(&sb, "%s  %-32s %10s  %s (%s)\n",
+			e.When.Format("2006-01-02"), e.action(), utils.HumanSize(e.Size), e.Key, e.RuleID)
+	}
+	return sb.String()
+}
+
+func (e LifecycleEvent) action() string {
+	if e.StorageClass == "" {
+		return "expire"
+	}
+	return "transition to " + e.StorageClass
+}
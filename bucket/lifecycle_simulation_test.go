@@ -0,0 +1,157 @@
+package bucket
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Wondrous27/s3-tui/object"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func TestSimulateLifecycle(t *testing.T) {
+	now := time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)
+	written := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	date := time.Date(2026, 1, 20, 0, 0, 0, 0, time.UTC)
+	large := int64(1 << 20)
+	obj := func(key string, size int64, class types.ObjectStorageClass) object.Object {
+		return object.Object{Key: key, Size: size, LastModified: written, StorageClass: class}
+	}
+	transition := func(days int32, class string) LifecycleTransition {
+		return LifecycleTransition{Days: days, StorageClass: class}
+	}
+
+	tests := []struct {
+		name    string
+		rules   []LifecycleRule
+		objects []object.Object
+		// events are "date action key (rule)"
+		events       []string
+		notSimulated int
+		notes        int
+	}{
+		{
+			name: "transition then expiration",
+			rules: []LifecycleRule{{
+				ID: "logs", Status: "Enabled", Filter: &LifecycleFilter{Prefix: "logs/"},
+				Transitions: []LifecycleTransition{transition(30, "STANDARD_IA")},
+				Expiration:  &LifecycleExpiration{Days: 60},
+			}},
+			objects: []object.Object{obj("logs/a", large, ""), obj("data/b", large, "")},
+			events: []string{
+				"2026-01-31 transition to STANDARD_IA logs/a (logs)",
+				"2026-03-02 expire logs/a (logs)",
+			},
+		},
+		{
+			name: "expiration wins over later transitions",
+			rules: []LifecycleRule{{
+				Status:      "Enabled",
+				Transitions: []LifecycleTransition{transition(20, "GLACIER")},
+				Expiration:  &LifecycleExpiration{Days: 10},
+			}},
+			objects: []object.Object{obj("a", large, "")},
+			events:  []string{"2026-01-11 expire a (rule 1)"},
+		},
+		{
+			name:    "due already",
+			rules:   []LifecycleRule{{Status: "Enabled", Transitions: []LifecycleTransition{transition(1, "GLACIER")}}},
+			objects: []object.Object{obj("a", large, "")},
+			events:  []string{"2026-01-05 transition to GLACIER a (rule 1)"},
+		},
+		{
+			name:  "rounded up to midnight",
+			rules: []LifecycleRule{{Status: "Enabled", Expiration: &LifecycleExpiration{Days: 2}}},
+			objects: []object.Object{{
+				Key: "a", Size: large, LastModified: time.Date(2026, 1, 4, 10, 0, 0, 0, time.UTC),
+			}},
+			events: []string{"2026-01-07 expire a (rule 1)"},
+		},
+		{
+			name:    "by date",
+			rules:   []LifecycleRule{{Status: "Enabled", Expiration: &LifecycleExpiration{Date: &date}}},
+			objects: []object.Object{obj("a", large, "")},
+			events:  []string{"2026-01-20 expire a (rule 1)"},
+		},
+		{
+			name:    "beyond the horizon",
+			rules:   []LifecycleRule{{Status: "Enabled", Expiration: &LifecycleExpiration{Days: 200}}},
+			objects: []object.Object{obj("a", large, "")},
+		},
+		{
+			name:    "never back up the tiers",
+			rules:   []LifecycleRule{{Status: "Enabled", Transitions: []LifecycleTransition{transition(30, "STANDARD_IA"), transition(40, "GLACIER")}}},
+			objects: []object.Object{obj("archived", large, types.ObjectStorageClassGlacier), obj("ia", large, types.ObjectStorageClassStandardIa)},
+			events: []string{
+				"2026-02-10 transition to GLACIER ia (rule 1)",
+			},
+		},
+		{
+			name: "deepest class wins on the same day",
+			rules: []LifecycleRule{
+				{ID: "ia", Status: "Enabled", Transitions: []LifecycleTransition{transition(30, "STANDARD_IA"), transition(40, "ONEZONE_IA")}},
+				{ID: "glacier", Status: "Enabled", Transitions: []LifecycleTransition{transition(30, "GLACIER")}},
+			},
+			objects: []object.Object{obj("a", large, "")},
+			events:  []string{"2026-01-31 transition to GLACIER a (glacier)"},
+		},
+		{
+			name:    "small objects stay out of IA",
+			rules:   []LifecycleRule{{Status: "Enabled", Transitions: []LifecycleTransition{transition(30, "STANDARD_IA"), transition(40, "GLACIER")}}},
+			objects: []object.Object{obj("small", 1024, ""), obj("large", large, "")},
+			events: []string{
+				"2026-01-31 transition to STANDARD_IA large (rule 1)",
+				"2026-02-10 transition to GLACIER small (rule 1)",
+				"2026-02-10 transition to GLACIER large (rule 1)",
+			},
+			notes: 1,
+		},
+		{
+			name: "size filter",
+			rules: []LifecycleRule{{
+				Status: "Enabled", Filter: &LifecycleFilter{SizeGreaterThan: 100, SizeLessThan: 1000},
+				Expiration: &LifecycleExpiration{Days: 10},
+			}},
+			objects: []object.Object{obj("tiny", 100, ""), obj("fits", 500, ""), obj("big", 1000, "")},
+			events:  []string{"2026-01-11 expire fits (rule 1)"},
+		},
+		{
+			name: "disabled and tag filtered rules",
+			rules: []LifecycleRule{
+				{Status: "Disabled", Expiration: &LifecycleExpiration{Days: 1}},
+				{ID: "pii", Status: "Enabled", Filter: &LifecycleFilter{Tags: map[string]string{"pii": "true"}}, Expiration: &LifecycleExpiration{Days: 1}},
+			},
+			objects:      []object.Object{obj("a", large, "")},
+			notSimulated: 1,
+		},
+		{
+			name: "noncurrent versions and uploads",
+			rules: []LifecycleRule{{
+				Status: "Enabled", NoncurrentExpiration: &LifecycleExpiration{Days: 1}, AbortIncompleteUploadsDays: 1,
+				Expiration: &LifecycleExpiration{Days: 10},
+			}},
+			objects: []object.Object{obj("a", large, "")},
+			events:  []string{"2026-01-11 expire a (rule 1)"},
+			notes:   2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sim := SimulateLifecycle(tt.rules, tt.objects, now, 90)
+			var events []string
+			for _, e := range sim.Events {
+				events = append(events, fmt.Sprintf("%s %s %s (%s)", e.When.Format("2006-01-02"), e.action(), e.Key, e.RuleID))
+			}
+			if !slices.Equal(events, tt.events) {
+				t.Errorf("events:\n%q\nwant:\n%q", events, tt.events)
+			}
+			if len(sim.NotSimulated) != tt.notSimulated {
+				t.Errorf("not simulated %q, want %d", sim.NotSimulated, tt.notSimulated)
+			}
+			if len(sim.Notes) != tt.notes {
+				t.Errorf("notes %q, want %d", sim.Notes, tt.notes)
+			}
+		})
+	}
+}
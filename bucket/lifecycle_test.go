@@ -0,0 +1,138 @@
+package bucket
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateLifecycle(t *testing.T) {
+	date := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	expire := &LifecycleExpiration{Days: 30}
+	tests := []struct {
+		name  string
+		rules []LifecycleRule
+		// want are parts of the errors, none when the rules are valid
+		want []string
+	}{
+		{name: "no rules"},
+		{
+			name: "valid",
+			rules: []LifecycleRule{{
+				ID:          "logs",
+				Status:      "Enabled",
+				Filter:      &LifecycleFilter{Prefix: "logs/", SizeGreaterThan: 1, SizeLessThan: 10},
+				Transitions: []LifecycleTransition{{Days: 30, StorageClass: "STANDARD_IA"}, {Date: &date, StorageClass: "GLACIER"}},
+				Expiration:  &LifecycleExpiration{Days: 365},
+			}, {
+				Status:                     "Disabled",
+				NoncurrentTransitions:      []LifecycleTransition{{Days: 30, StorageClass: "GLACIER", NewerVersions: 2}},
+				NoncurrentExpiration:       &LifecycleExpiration{Days: 90, NewerVersions: 1},
+				AbortIncompleteUploadsDays: 7,
+			}, {
+				Status:     "Enabled",
+				Expiration: &LifecycleExpiration{ExpiredObjectDeleteMarker: true},
+			}},
+		},
+		{
+			name:  "duplicate id",
+			rules: []LifecycleRule{{ID: "a", Status: "Enabled", Expiration: expire}, {ID: "a", Status: "Enabled", Expiration: expire}},
+			want:  []string{`rule "a": the id is used twice`},
+		},
+		{
+			name:  "status and action",
+			rules: []LifecycleRule{{Status: "enabled"}},
+			want:  []string{"rule 1: status must be Enabled or Disabled", "rule 1: has no action"},
+		},
+		{
+			name:  "size range",
+			rules: []LifecycleRule{{Status: "Enabled", Filter: &LifecycleFilter{SizeGreaterThan: 10, SizeLessThan: 10}, Expiration: expire}},
+			want:  []string{"size_greater_than must be less than size_less_than"},
+		},
+		{
+			name: "transitions",
+			rules: []LifecycleRule{{
+				Status:      "Enabled",
+				Transitions: []LifecycleTransition{{Days: 1, StorageClass: "STANDARD"}, {Days: 1, Date: &date, StorageClass: "GLACIER", NewerVersions: 1}},
+			}},
+			want: []string{
+				`"STANDARD" is not a transition storage class`,
+				"a transition has both days and a date",
+				"newer_versions only applies to noncurrent transitions",
+			},
+		},
+		{
+			name: "infrequent access after 30 days",
+			rules: []LifecycleRule{{
+				Status: "Enabled",
+				Transitions: []LifecycleTransition{
+					{Days: 29, StorageClass: "STANDARD_IA"},
+					{Days: 0, StorageClass: "ONEZONE_IA"},
+					{Date: &date, StorageClass: "STANDARD_IA"},
+					{Days: 1, StorageClass: "GLACIER"},
+				},
+				NoncurrentTransitions: []LifecycleTransition{{Days: 7, StorageClass: "ONEZONE_IA"}, {Days: 30, StorageClass: "STANDARD_IA"}},
+			}},
+			want: []string{
+				"a transition to STANDARD_IA needs at least 30 days",
+				"a transition to ONEZONE_IA needs at least 30 days",
+				"a noncurrent transition to ONEZONE_IA needs at least 30 days",
+			},
+		},
+		{
+			name: "noncurrent by days",
+			rules: []LifecycleRule{{
+				Status:                "Enabled",
+				NoncurrentTransitions: []LifecycleTransition{{Date: &date, StorageClass: "GLACIER"}},
+				NoncurrentExpiration:  &LifecycleExpiration{Date: &date},
+			}},
+			want: []string{
+				"noncurrent transitions are by days, not date",
+				"the noncurrent expiration needs days",
+				"the noncurrent expiration is by days only",
+			},
+		},
+		{
+			name: "expiration",
+			rules: []LifecycleRule{
+				{Status: "Enabled", Expiration: &LifecycleExpiration{Days: 1, Date: &date}},
+				{Status: "Enabled", Expiration: &LifecycleExpiration{}},
+				{Status: "Enabled", Expiration: &LifecycleExpiration{Days: 1, NewerVersions: 1}},
+			},
+			want: []string{
+				"rule 1: expiration needs exactly one of days, date or expired_object_delete_marker",
+				"rule 2: expiration needs exactly one of days, date or expired_object_delete_marker",
+				"rule 3: newer_versions only applies to the noncurrent expiration",
+			},
+		},
+		{
+			name:  "negative abort",
+			rules: []LifecycleRule{{Status: "Enabled", AbortIncompleteUploadsDays: -1, Expiration: expire}},
+			want:  []string{"abort_incomplete_uploads_days cannot be negative"},
+		},
+		{
+			name:  "too many rules",
+			rules: make([]LifecycleRule, 1001),
+			want:  []string{"at most 1000 lifecycle rules"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateLifecycle(tt.rules)
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("ValidateLifecycle: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("ValidateLifecycle accepted the rules, want %q", tt.want)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("ValidateLifecycle error %q does not contain %q", err, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package bucket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// ObjectLock is the object lock configuration of a bucket and the default
+// retention it applies to new objects.
+type ObjectLock struct {
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	// Mode is GOVERNANCE or COMPLIANCE, empty for no default retention
+	Mode  string `json:"mode,omitempty" yaml:"mode,omitempty"`
+	Days  int32  `json:"days,omitempty" yaml:"days,omitempty"`
+	Years int32  `json:"years,omitempty" yaml:"years,omitempty"`
+}
+
+func (s S3Repository) GetObjectLock(bucketName string) (*ObjectLock, error) {
+	out, err := s.Client.GetObjectLockConfiguration(context.TODO(),
+		&s3.GetObjectLockConfigurationInput{Bucket: &bucketName})
+	if isErrorCode(err, "ObjectLockConfigurationNotFoundError") {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get the object lock of %s: %w", bucketName, err)
+	}
+	config := out.ObjectLockConfiguration
+	if config == nil {
+		return nil, nil
+	}
+	lock := &ObjectLock{Enabled: config.ObjectLockEnabled == types.ObjectLockEnabledEnabled}
+	if config.Rule != nil && config.Rule.DefaultRetention != nil {
+		retention := config.Rule.DefaultRetention
+		lock.Mode = string(retention.Mode)
+		lock.Days = aws.ToInt32(retention.Days)
+		lock.Years = aws.ToInt32(retention.Years)
+	}
+	return lock, nil
+}
+
+// PutObjectLock enables object lock and sets the default retention, no mode
+// removes the default retention. Object lock needs versioning and cannot be
+// disabled once enabled.
+func (s S3Repository) PutObjectLock(bucketName string, lock *ObjectLock) error {
+	if err := ValidateObjectLock(lock); err != nil {
+		return err
+	}
+	config := &types.ObjectLockConfiguration{ObjectLockEnabled: types.ObjectLockEnabledEnabled}
+	if lock.Mode != "" {
+		config.Rule = &types.ObjectLockRule{DefaultRetention: &types.DefaultRetention{
+			Mode:  types.ObjectLockRetentionMode(lock.Mode),
+			Days:  optional(lock.Days),
+			Years: optional(lock.Years),
+		}}
+	}
+	return s.putSetting("put-object-lock", bucketName, func() error {
+		_, err := s.Client.PutObjectLockConfiguration(context.TODO(), &s3.PutObjectLockConfigurationInput{
+			Bucket:                  &bucketName,
+			ObjectLockConfiguration: config,
+		})
+		return err
+	})
+}
+
+// ValidateObjectLock reports the mistakes S3 would reject lock for
+func ValidateObjectLock(lock *ObjectLock) error {
+	if lock == nil || !lock.Enabled {
+		return errors.New("object lock cannot be disabled once enabled")
+	}
+	if lock.Mode == "" {
+		if lock.Days != 0 || lock.Years != 0 {
+			return errors.New("a default retention needs a mode, GOVERNANCE or COMPLIANCE")
+		}
+		return nil
+	}
+	mode := types.ObjectLockRetentionMode(lock.Mode)
+	if mode != types.ObjectLockRetentionModeGovernance && mode != types.ObjectLockRetentionModeCompliance {
+		return errors.New("mode must be GOVERNANCE or COMPLIANCE")
+	}
+	if (lock.Days > 0) == (lock.Years > 0) {
+		return errors.New("the default retention needs either days or years")
+	}
+	return nil
+}
@@ -0,0 +1,291 @@
+package bucket
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/Wondrous27/s3-tui/audit"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+)
+
+// policyVersions are the policy language versions IAM understands
+var policyVersions = []string{"2012-10-17", "2008-10-17"}
+
+var sidPattern = regexp.MustCompile(`^[A-Za-z0-9]*$`)
+
+var principalTypes = []string{"AWS", "CanonicalUser", "Federated", "Service"}
+
+// GetBucketPolicy returns the policy document of a bucket, empty when the
+// bucket has no policy.
+func (s S3Repository) GetBucketPolicy(bucketName string) (string, error) {
+	out, err := s.Client.GetBucketPolicy(context.TODO(), &s3.GetBucketPolicyInput{Bucket: &bucketName})
+	if isErrorCode(err, "NoSuchBucketPolicy") {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("could not get the policy of %s: %w", bucketName, err)
+	}
+	return *out.Policy, nil
+}
+
+// PutBucketPolicy replaces the policy of a bucket once it passed ValidatePolicy
+func (s S3Repository) PutBucketPolicy(bucketName, policy string) error {
+	entry := audit.Entry{Operation: "put-bucket-policy", Bucket: bucketName}
+	if err := s.refuse(entry); err != nil {
+		return err
+	}
+	if err := ValidatePolicy(bucketName, policy); err != nil {
+		return err
+	}
+	_, err := s.Client.PutBucketPolicy(context.TODO(), &s3.PutBucketPolicyInput{
+		Bucket: &bucketName,
+		Policy: &policy,
+	})
+	s.Audit.Record(entry, err)
+	if err != nil {
+		return fmt.Errorf("could not put the policy of %s: %w", bucketName, err)
+	}
+	return nil
+}
+
+func (s S3Repository) DeleteBucketPolicy(bucketName string) error {
+	entry := audit.Entry{Operation: "delete-bucket-policy", Bucket: bucketName}
+	if err := s.refuse(entry); err != nil {
+		return err
+	}
+	_, err := s.Client.DeleteBucketPolicy(context.TODO(), &s3.DeleteBucketPolicyInput{Bucket: &bucketName})
+	s.Audit.Record(entry, err)
+	if err != nil {
+		return fmt.Errorf("could not delete the policy of %s: %w", bucketName, err)
+	}
+	return nil
+}
+
+// FormatPolicy pretty-prints a policy document, returning it unchanged when
+// it is not JSON.
+func FormatPolicy(policy string) string {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, []byte(policy), "", "  "); err != nil {
+		return policy
+	}
+	return buf.String()
+}
+
+// ValidatePolicy checks that policy is JSON and follows the grammar of a
+// policy for bucketName, reporting every mistake it finds at once.
+func ValidatePolicy(bucketName, policy string) error {
+	var doc map[string]any
+	if err := json.Unmarshal([]byte(policy), &doc); err != nil {
+		return fmt.Errorf("policy is not a JSON object: %w", err)
+	}
+
+	v := policyValidator{bucket: bucketName, sids: map[string]bool{}}
+	v.keys("policy", doc, "Version", "Id", "Statement")
+	if version, ok := doc["Version"]; ok {
+		if s, _ := version.(string); !slices.Contains(policyVersions, s) {
+			v.addf("Version must be one of %s", strings.Join(policyVersions, ", "))
+		}
+	}
+
+	switch statements := doc["Statement"].(type) {
+	case nil:
+		v.addf("Statement is missing")
+	case map[string]any:
+		v.statement("Statement", statements)
+	case []any:
+		if len(statements) == 0 {
+			v.addf("Statement is empty")
+		}
+		for i, statement := range statements {
+			name := fmt.Sprintf("Statement[%d]", i)
+			if st, ok := statement.(map[string]any); ok {
+				v.statement(name, st)
+			} else {
+				v.addf("%s must be an object", name)
+			}
+		}
+	default:
+		v.addf("Statement must be an object or an array of objects")
+	}
+	return errors.Join(v.errs...)
+}
+
+type policyValidator struct {
+	bucket string
+	sids   map[string]bool
+	errs   []error
+}
+
+func (v *policyValidator) addf(format string, args ...any) {
+	v.errs = append(v.errs, fmt.Errorf(format, args...))
+}
+
+// keys reports the keys of obj that are not allowed
+func (v *policyValidator) keys(name string, obj map[string]any, allowed ...string) {
+	for _, k := range sortedKeys(obj) {
+		if !slices.Contains(allowed, k) {
+			v.addf("%s has an unknown element %q", name, k)
+		}
+	}
+}
+
+func (v *policyValidator) statement(name string, st map[string]any) {
+	if sid, ok := st["Sid"]; ok {
+		s, isString := sid.(string)
+		switch {
+		case !isString || !sidPattern.MatchString(s):
+			v.addf("%s: Sid must only contain letters and digits", name)
+		case v.sids[s]:
+			v.addf("%s: Sid %q is used twice", name, s)
+		default:
+			v.sids[s] = true
+			name = fmt.Sprintf("%s (%s)", name, s)
+		}
+	}
+	v.keys(name, st, "Sid", "Effect", "Principal", "NotPrincipal", "Action", "NotAction",
+		"Resource", "NotResource", "Condition")
+
+	if effect, _ := st["Effect"].(string); effect != "Allow" && effect != "Deny" {
+		v.addf("%s: Effect must be Allow or Deny", name)
+	}
+	if p := v.oneOf(name, st, "Principal", "NotPrincipal"); p != "" {
+		v.principal(name+": "+p, st[p])
+	}
+	if a := v.oneOf(name, st, "Action", "NotAction"); a != "" {
+		for _, action := range v.strings(name+": "+a, st[a]) {
+			// Action names are case-insensitive, S3:GetObject is s3:GetObject
+			if action != "*" && (len(action) < 3 || !strings.EqualFold(action[:3], "s3:")) {
+				v.addf("%s: %s %q is not an S3 action", name, a, action)
+			}
+		}
+	}
+	if r := v.oneOf(name, st, "Resource", "NotResource"); r != "" {
+		for _, resource := range v.strings(name+": "+r, st[r]) {
+			v.resource(name+": "+r, resource)
+		}
+	}
+	if condition, ok := st["Condition"]; ok {
+		v.condition(name+": Condition", condition)
+	}
+}
+
+// oneOf returns whichever of the two exclusive elements st has, reporting
+// when it has both or neither.
+func (v *policyValidator) oneOf(name string, st map[string]any, a, b string) string {
+	_, hasA := st[a]
+	_, hasB := st[b]
+	switch {
+	case hasA && hasB:
+		v.addf("%s: %s and %s cannot be used together", name, a, b)
+	case hasA:
+		return a
+	case hasB:
+		return b
+	default:
+		v.addf("%s: %s or %s is missing", name, a, b)
+	}
+	return ""
+}
+
+// strings returns a string or an array of strings, reporting anything else
+func (v *policyValidator) strings(name string, value any) []string {
+	switch value := value.(type) {
+	case string:
+		return []string{value}
+	case []any:
+		if len(value) == 0 {
+			v.addf("%s is empty", name)
+		}
+		values := make([]string, 0, len(value))
+		for _, elem := range value {
+			s, ok := elem.(string)
+			if !ok {
+				v.addf("%s must only contain strings", name)
+				return nil
+			}
+			values = append(values, s)
+		}
+		return values
+	}
+	v.addf("%s must be a string or an array of strings", name)
+	return nil
+}
+
+func (v *policyValidator) principal(name string, value any) {
+	if value == "*" {
+		return
+	}
+	principals, ok := value.(map[string]any)
+	if !ok || len(principals) == 0 {
+		v.addf("%s must be \"*\" or an object of %s", name, strings.Join(principalTypes, ", "))
+		return
+	}
+	v.keys(name, principals, principalTypes...)
+	for _, t := range principalTypes {
+		if p, ok := principals[t]; ok {
+			v.strings(name+"."+t, p)
+		}
+	}
+}
+
+// resource checks that resource is this bucket or objects in it
+func (v *policyValidator) resource(name, resource string) {
+	if resource == "*" {
+		return
+	}
+	arn := strings.SplitN(resource, ":", 6)
+	if len(arn) != 6 || arn[0] != "arn" || arn[2] != "s3" || arn[3] != "" || arn[4] != "" {
+		v.addf("%s %q is not an S3 ARN like arn:aws:s3:::%s/*", name, resource, v.bucket)
+		return
+	}
+	bucketName, _, _ := strings.Cut(arn[5], "/")
+	if ok, _ := path.Match(bucketName, v.bucket); !ok {
+		v.addf("%s %q is not in bucket %s", name, resource, v.bucket)
+	}
+}
+
+func (v *policyValidator) condition(name string, value any) {
+	operators, ok := value.(map[string]any)
+	if !ok {
+		v.addf("%s must be an object of condition operators", name)
+		return
+	}
+	for _, operator := range sortedKeys(operators) {
+		conditions, ok := operators[operator].(map[string]any)
+		if !ok || len(conditions) == 0 {
+			v.addf("%s.%s must be an object of condition keys", name, operator)
+			continue
+		}
+		for _, conditionKey := range sortedKeys(conditions) {
+			values := conditions[conditionKey]
+			if _, isObject := values.(map[string]any); isObject || values == nil {
+				v.addf("%s.%s.%s must be a value or an array of values", name, operator, conditionKey)
+			}
+		}
+	}
+}
+
+// isErrorCode reports whether err is an S3 error with the given code
+func isErrorCode(err error, code string) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == code
+}
+
+// sortedKeys keeps the reported mistakes in a stable order
+func sortedKeys(obj map[string]any) []string {
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
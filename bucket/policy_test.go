@@ -0,0 +1,109 @@
+package bucket
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidatePolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+		// want are parts of the errors, none when the policy is valid
+		want []string
+	}{
+		{
+			name: "valid",
+			policy: `{"Version": "2012-10-17", "Statement": [{
+				"Sid": "AllowRead", "Effect": "Allow",
+				"Principal": {"AWS": "arn:aws:iam::123456789012:root"},
+				"Action": "s3:GetObject", "Resource": "arn:aws:s3:::photos/*"}]}`,
+		},
+		{
+			name: "single statement and wildcards",
+			policy: `{"Statement": {"Effect": "Deny", "Principal": "*", "NotAction": ["s3:*"],
+				"Resource": ["arn:aws:s3:::photos", "arn:aws:s3:::pho*/*"],
+				"Condition": {"Bool": {"aws:SecureTransport": "false"}}}}`,
+		},
+		{
+			name: "actions ignore case",
+			policy: `{"Statement": {"Effect": "Allow", "Principal": "*",
+				"Action": ["S3:GetObject", "s3:listbucket"], "Resource": "arn:aws:s3:::photos/*"}}`,
+		},
+		{
+			name:   "not json",
+			policy: `{"Statement": [`,
+			want:   []string{"not a JSON object"},
+		},
+		{
+			name:   "no statement",
+			policy: `{"Version": "2012-10-17"}`,
+			want:   []string{"Statement is missing"},
+		},
+		{
+			name:   "empty statements",
+			policy: `{"Statement": []}`,
+			want:   []string{"Statement is empty"},
+		},
+		{
+			name:   "unknown version and element",
+			policy: `{"Version": "2020-01-01", "Statment": [], "Statement": {"Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "*"}}`,
+			want:   []string{"Version must be one of", `unknown element "Statment"`},
+		},
+		{
+			name: "every mistake of a statement",
+			policy: `{"Statement": [{"Sid": "bad-sid", "Effect": "allow", "Principal": {"User": "me"},
+				"Action": "ec2:RunInstances", "Resource": "arn:aws:s3:::other/*", "Condition": []}]}`,
+			want: []string{
+				"Sid must only contain letters and digits",
+				"Effect must be Allow or Deny",
+				`unknown element "User"`,
+				`"ec2:RunInstances" is not an S3 action`,
+				"is not in bucket photos",
+				"Condition must be an object of condition operators",
+			},
+		},
+		{
+			name: "exclusive elements",
+			policy: `{"Statement": [{"Effect": "Allow", "Principal": "*", "NotPrincipal": "*",
+				"Resource": "*"}]}`,
+			want: []string{"Principal and NotPrincipal cannot be used together", "Action or NotAction is missing"},
+		},
+		{
+			name: "duplicate sid",
+			policy: `{"Statement": [
+				{"Sid": "A", "Effect": "Allow", "Principal": "*", "Action": "s3:*", "Resource": "*"},
+				{"Sid": "A", "Effect": "Allow", "Principal": "*", "Action": "s3:*", "Resource": "*"}]}`,
+			want: []string{`Sid "A" is used twice`},
+		},
+		{
+			name:   "not an s3 arn",
+			policy: `{"Statement": {"Effect": "Allow", "Principal": "*", "Action": "s3:*", "Resource": "photos/*"}}`,
+			want:   []string{`"photos/*" is not an S3 ARN`},
+		},
+		{
+			name:   "bad action list",
+			policy: `{"Statement": {"Effect": "Allow", "Principal": "*", "Action": [1], "Resource": []}}`,
+			want:   []string{"Action must only contain strings", "Resource is empty"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePolicy("photos", tt.policy)
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("ValidatePolicy: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("ValidatePolicy accepted the policy, want %q", tt.want)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("ValidatePolicy error %q does not contain %q", err, want)
+				}
+			}
+		})
+	}
+}
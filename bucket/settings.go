@@ -0,0 +1,406 @@
+package bucket
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/Wondrous27/s3-tui/audit"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// The settings below are editable forms of the S3 bucket configurations,
+// with nil meaning the configuration is not set.
+
+type CORSRule struct {
+	ID             string   `json:"id,omitempty" yaml:"id,omitempty"`
+	AllowedOrigins []string `json:"allowed_origins" yaml:"allowed_origins"`
+	AllowedMethods []string `json:"allowed_methods" yaml:"allowed_methods"`
+	AllowedHeaders []string `json:"allowed_headers,omitempty" yaml:"allowed_headers,omitempty"`
+	ExposeHeaders  []string `json:"expose_headers,omitempty" yaml:"expose_headers,omitempty"`
+	MaxAgeSeconds  int32    `json:"max_age_seconds,omitempty" yaml:"max_age_seconds,omitempty"`
+}
+
+type Website struct {
+	IndexDocument string `json:"index_document,omitempty" yaml:"index_document,omitempty"`
+	ErrorDocument string `json:"error_document,omitempty" yaml:"error_document,omitempty"`
+	// RedirectAllRequestsTo replaces the documents and routing rules
+	RedirectAllRequestsTo *WebsiteRedirect     `json:"redirect_all_requests_to,omitempty" yaml:"redirect_all_requests_to,omitempty"`
+	RoutingRules          []WebsiteRoutingRule `json:"routing_rules,omitempty" yaml:"routing_rules,omitempty"`
+}
+
+type WebsiteRedirect struct {
+	HostName             string `json:"host_name,omitempty" yaml:"host_name,omitempty"`
+	Protocol             string `json:"protocol,omitempty" yaml:"protocol,omitempty"`
+	HttpRedirectCode     string `json:"http_redirect_code,omitempty" yaml:"http_redirect_code,omitempty"`
+	ReplaceKeyPrefixWith string `json:"replace_key_prefix_with,omitempty" yaml:"replace_key_prefix_with,omitempty"`
+	ReplaceKeyWith       string `json:"replace_key_with,omitempty" yaml:"replace_key_with,omitempty"`
+}
+
+type WebsiteRoutingRule struct {
+	KeyPrefixEquals             string          `json:"key_prefix_equals,omitempty" yaml:"key_prefix_equals,omitempty"`
+	HttpErrorCodeReturnedEquals string          `json:"http_error_code_returned_equals,omitempty" yaml:"http_error_code_returned_equals,omitempty"`
+	Redirect                    WebsiteRedirect `json:"redirect" yaml:"redirect"`
+}
+
+type Versioning struct {
+	// Status is Enabled or Suspended, a bucket that never had versioning has
+	// no Versioning
+	Status string `json:"status" yaml:"status"`
+	// MFADelete is Enabled or Disabled, changing it needs the root user's MFA
+	MFADelete string `json:"mfa_delete,omitempty" yaml:"mfa_delete,omitempty"`
+}
+
+type Encryption struct {
+	// SSEAlgorithm is AES256, aws:kms or aws:kms:dsse
+	SSEAlgorithm     string `json:"sse_algorithm" yaml:"sse_algorithm"`
+	KMSMasterKeyID   string `json:"kms_master_key_id,omitempty" yaml:"kms_master_key_id,omitempty"`
+	BucketKeyEnabled bool   `json:"bucket_key_enabled,omitempty" yaml:"bucket_key_enabled,omitempty"`
+}
+
+type PublicAccessBlock struct {
+	BlockPublicAcls       bool `json:"block_public_acls" yaml:"block_public_acls"`
+	IgnorePublicAcls      bool `json:"ignore_public_acls" yaml:"ignore_public_acls"`
+	BlockPublicPolicy     bool `json:"block_public_policy" yaml:"block_public_policy"`
+	RestrictPublicBuckets bool `json:"restrict_public_buckets" yaml:"restrict_public_buckets"`
+}
+
+func (s S3Repository) GetBucketCors(bucketName string) ([]CORSRule, error) {
+	out, err := s.Client.GetBucketCors(context.TODO(), &s3.GetBucketCorsInput{Bucket: &bucketName})
+	if isErrorCode(err, "NoSuchCORSConfiguration") {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get the CORS rules of %s: %w", bucketName, err)
+	}
+	rules := make([]CORSRule, 0, len(out.CORSRules))
+	for _, r := range out.CORSRules {
+		rules = append(rules, CORSRule{
+			ID:             aws.ToString(r.ID),
+			AllowedOrigins: r.AllowedOrigins,
+			AllowedMethods: r.AllowedMethods,
+			AllowedHeaders: r.AllowedHeaders,
+			ExposeHeaders:  r.ExposeHeaders,
+			MaxAgeSeconds:  aws.ToInt32(r.MaxAgeSeconds),
+		})
+	}
+	return rules, nil
+}
+
+// PutBucketCors replaces the CORS rules of a bucket, no rules deletes them
+func (s S3Repository) PutBucketCors(bucketName string, rules []CORSRule) error {
+	if len(rules) == 0 {
+		return s.DeleteBucketCors(bucketName)
+	}
+	corsRules := make([]types.CORSRule, 0, len(rules))
+	for _, r := range rules {
+		corsRules = append(corsRules, types.CORSRule{
+			ID:             optionalString(r.ID),
+			AllowedOrigins: r.AllowedOrigins,
+			AllowedMethods: r.AllowedMethods,
+			AllowedHeaders: r.AllowedHeaders,
+			ExposeHeaders:  r.ExposeHeaders,
+			MaxAgeSeconds:  optional(r.MaxAgeSeconds),
+		})
+	}
+	return s.putSetting("put-bucket-cors", bucketName, func() error {
+		_, err := s.Client.PutBucketCors(context.TODO(), &s3.PutBucketCorsInput{
+			Bucket:            &bucketName,
+			CORSConfiguration: &types.CORSConfiguration{CORSRules: corsRules},
+		})
+		return err
+	})
+}
+
+func (s S3Repository) DeleteBucketCors(bucketName string) error {
+	return s.putSetting("delete-bucket-cors", bucketName, func() error {
+		_, err := s.Client.DeleteBucketCors(context.TODO(), &s3.DeleteBucketCorsInput{Bucket: &bucketName})
+		return err
+	})
+}
+
+func (s S3Repository) GetBucketWebsite(bucketName string) (*Website, error) {
+	out, err := s.Client.GetBucketWebsite(context.TODO(), &s3.GetBucketWebsiteInput{Bucket: &bucketName})
+	if isErrorCode(err, "NoSuchWebsiteConfiguration") {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get the website of %s: %w", bucketName, err)
+	}
+	website := &Website{}
+	if out.IndexDocument != nil {
+		website.IndexDocument = aws.ToString(out.IndexDocument.Suffix)
+	}
+	if out.ErrorDocument != nil {
+		website.ErrorDocument = aws.ToString(out.ErrorDocument.Key)
+	}
+	if r := out.RedirectAllRequestsTo; r != nil {
+		website.RedirectAllRequestsTo = &WebsiteRedirect{HostName: aws.ToString(r.HostName), Protocol: string(r.Protocol)}
+	}
+	for _, rule := range out.RoutingRules {
+		routingRule := WebsiteRoutingRule{}
+		if c := rule.Condition; c != nil {
+			routingRule.KeyPrefixEquals = aws.ToString(c.KeyPrefixEquals)
+			routingRule.HttpErrorCodeReturnedEquals = aws.ToString(c.HttpErrorCodeReturnedEquals)
+		}
+		if r := rule.Redirect; r != nil {
+			routingRule.Redirect = WebsiteRedirect{
+				HostName:             aws.ToString(r.HostName),
+				Protocol:             string(r.Protocol),
+				HttpRedirectCode:     aws.ToString(r.HttpRedirectCode),
+				ReplaceKeyPrefixWith: aws.ToString(r.ReplaceKeyPrefixWith),
+				ReplaceKeyWith:       aws.ToString(r.ReplaceKeyWith),
+			}
+		}
+		website.RoutingRules = append(website.RoutingRules, routingRule)
+	}
+	return website, nil
+}
+
+// PutBucketWebsite enables static website hosting, nil disables it
+func (s S3Repository) PutBucketWebsite(bucketName string, website *Website) error {
+	if website == nil {
+		return s.DeleteBucketWebsite(bucketName)
+	}
+	config := &types.WebsiteConfiguration{}
+	if website.IndexDocument != "" {
+		config.IndexDocument = &types.IndexDocument{Suffix: aws.String(website.IndexDocument)}
+	}
+	if website.ErrorDocument != "" {
+		config.ErrorDocument = &types.ErrorDocument{Key: aws.String(website.ErrorDocument)}
+	}
+	if r := website.RedirectAllRequestsTo; r != nil {
+		config.RedirectAllRequestsTo = &types.RedirectAllRequestsTo{
+			HostName: aws.String(r.HostName),
+			Protocol: types.Protocol(r.Protocol),
+		}
+	}
+	for _, rule := range website.RoutingRules {
+		routingRule := types.RoutingRule{Redirect: &types.Redirect{
+			HostName:             optionalString(rule.Redirect.HostName),
+			Protocol:             types.Protocol(rule.Redirect.Protocol),
+			HttpRedirectCode:     optionalString(rule.Redirect.HttpRedirectCode),
+			ReplaceKeyPrefixWith: optionalString(rule.Redirect.ReplaceKeyPrefixWith),
+			ReplaceKeyWith:       optionalString(rule.Redirect.ReplaceKeyWith),
+		}}
+		if rule.KeyPrefixEquals != "" || rule.HttpErrorCodeReturnedEquals != "" {
+			routingRule.Condition = &types.Condition{
+				KeyPrefixEquals:             optionalString(rule.KeyPrefixEquals),
+				HttpErrorCodeReturnedEquals: optionalString(rule.HttpErrorCodeReturnedEquals),
+			}
+		}
+		config.RoutingRules = append(config.RoutingRules, routingRule)
+	}
+	return s.putSetting("put-bucket-website", bucketName, func() error {
+		_, err := s.Client.PutBucketWebsite(context.TODO(), &s3.PutBucketWebsiteInput{
+			Bucket:               &bucketName,
+			WebsiteConfiguration: config,
+		})
+		return err
+	})
+}
+
+func (s S3Repository) DeleteBucketWebsite(bucketName string) error {
+	return s.putSetting("delete-bucket-website", bucketName, func() error {
+		_, err := s.Client.DeleteBucketWebsite(context.TODO(), &s3.DeleteBucketWebsiteInput{Bucket: &bucketName})
+		return err
+	})
+}
+
+func (s S3Repository) GetBucketTags(bucketName string) (map[string]string, error) {
+	out, err := s.Client.GetBucketTagging(context.TODO(), &s3.GetBucketTaggingInput{Bucket: &bucketName})
+	if isErrorCode(err, "NoSuchTagSet") {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get the tags of %s: %w", bucketName, err)
+	}
+	tags := make(map[string]string, len(out.TagSet))
+	for _, tag := range out.TagSet {
+		tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	return tags, nil
+}
+
+// PutBucketTags replaces the tags of a bucket, no tags deletes them
+func (s S3Repository) PutBucketTags(bucketName string, tags map[string]string) error {
+	if len(tags) == 0 {
+		return s.putSetting("delete-bucket-tags", bucketName, func() error {
+			_, err := s.Client.DeleteBucketTagging(context.TODO(), &s3.DeleteBucketTaggingInput{Bucket: &bucketName})
+			return err
+		})
+	}
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	tagSet := make([]types.Tag, 0, len(tags))
+	for _, k := range keys {
+		tagSet = append(tagSet, types.Tag{Key: aws.String(k), Value: aws.String(tags[k])})
+	}
+	return s.putSetting("put-bucket-tags", bucketName, func() error {
+		_, err := s.Client.PutBucketTagging(context.TODO(), &s3.PutBucketTaggingInput{
+			Bucket:  &bucketName,
+			Tagging: &types.Tagging{TagSet: tagSet},
+		})
+		return err
+	})
+}
+
+func (s S3Repository) GetBucketVersioning(bucketName string) (*Versioning, error) {
+	out, err := s.Client.GetBucketVersioning(context.TODO(), &s3.GetBucketVersioningInput{Bucket: &bucketName})
+	if err != nil {
+		return nil, fmt.Errorf("could not get the versioning of %s: %w", bucketName, err)
+	}
+	if out.Status == "" {
+		return nil, nil
+	}
+	return &Versioning{Status: string(out.Status), MFADelete: string(out.MFADelete)}, nil
+}
+
+// PutBucketVersioning enables or suspends versioning, and MFA delete when
+// it is set. Versioning cannot be turned off once it was enabled. mfa is the
+// serial number of the root user's MFA device and a code, separated by a
+// space, and is required to change MFA delete or to change a bucket that has
+// it enabled.
+func (s S3Repository) PutBucketVersioning(bucketName string, versioning *Versioning, mfa string) error {
+	if versioning == nil {
+		return fmt.Errorf("versioning cannot be removed, only suspended")
+	}
+	status := types.BucketVersioningStatus(versioning.Status)
+	if status != types.BucketVersioningStatusEnabled && status != types.BucketVersioningStatusSuspended {
+		return fmt.Errorf("versioning status must be Enabled or Suspended")
+	}
+	config := &types.VersioningConfiguration{Status: status}
+	if versioning.MFADelete != "" {
+		config.MFADelete = types.MFADelete(versioning.MFADelete)
+		if config.MFADelete != types.MFADeleteEnabled && config.MFADelete != types.MFADeleteDisabled {
+			return fmt.Errorf("mfa_delete must be Enabled or Disabled")
+		}
+	}
+	return s.putSetting("put-bucket-versioning", bucketName, func() error {
+		_, err := s.Client.PutBucketVersioning(context.TODO(), &s3.PutBucketVersioningInput{
+			Bucket:                  &bucketName,
+			VersioningConfiguration: config,
+			MFA:                     optionalString(mfa),
+		})
+		return err
+	})
+}
+
+func (s S3Repository) GetBucketEncryption(bucketName string) (*Encryption, error) {
+	out, err := s.Client.GetBucketEncryption(context.TODO(), &s3.GetBucketEncryptionInput{Bucket: &bucketName})
+	if isErrorCode(err, "ServerSideEncryptionConfigurationNotFoundError") {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get the encryption of %s: %w", bucketName, err)
+	}
+	if out.ServerSideEncryptionConfiguration == nil || len(out.ServerSideEncryptionConfiguration.Rules) == 0 {
+		return nil, nil
+	}
+	rule := out.ServerSideEncryptionConfiguration.Rules[0]
+	encryption := &Encryption{BucketKeyEnabled: aws.ToBool(rule.BucketKeyEnabled)}
+	if d := rule.ApplyServerSideEncryptionByDefault; d != nil {
+		encryption.SSEAlgorithm = string(d.SSEAlgorithm)
+		encryption.KMSMasterKeyID = aws.ToString(d.KMSMasterKeyID)
+	}
+	return encryption, nil
+}
+
+// PutBucketEncryption sets the default encryption of new objects, nil goes
+// back to the S3 default
+func (s S3Repository) PutBucketEncryption(bucketName string, encryption *Encryption) error {
+	if encryption == nil {
+		return s.putSetting("delete-bucket-encryption", bucketName, func() error {
+			_, err := s.Client.DeleteBucketEncryption(context.TODO(), &s3.DeleteBucketEncryptionInput{Bucket: &bucketName})
+			return err
+		})
+	}
+	rule := types.ServerSideEncryptionRule{
+		ApplyServerSideEncryptionByDefault: &types.ServerSideEncryptionByDefault{
+			SSEAlgorithm:   types.ServerSideEncryption(encryption.SSEAlgorithm),
+			KMSMasterKeyID: optionalString(encryption.KMSMasterKeyID),
+		},
+	}
+	if encryption.BucketKeyEnabled {
+		rule.BucketKeyEnabled = aws.Bool(true)
+	}
+	return s.putSetting("put-bucket-encryption", bucketName, func() error {
+		_, err := s.Client.PutBucketEncryption(context.TODO(), &s3.PutBucketEncryptionInput{
+			Bucket: &bucketName,
+			ServerSideEncryptionConfiguration: &types.ServerSideEncryptionConfiguration{
+				Rules: []types.ServerSideEncryptionRule{rule},
+			},
+		})
+		return err
+	})
+}
+
+func (s S3Repository) GetPublicAccessBlock(bucketName string) (*PublicAccessBlock, error) {
+	out, err := s.Client.GetPublicAccessBlock(context.TODO(), &s3.GetPublicAccessBlockInput{Bucket: &bucketName})
+	if isErrorCode(err, "NoSuchPublicAccessBlockConfiguration") {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get the public access block of %s: %w", bucketName, err)
+	}
+	c := out.PublicAccessBlockConfiguration
+	if c == nil {
+		return nil, nil
+	}
+	return &PublicAccessBlock{
+		BlockPublicAcls:       aws.ToBool(c.BlockPublicAcls),
+		IgnorePublicAcls:      aws.ToBool(c.IgnorePublicAcls),
+		BlockPublicPolicy:     aws.ToBool(c.BlockPublicPolicy),
+		RestrictPublicBuckets: aws.ToBool(c.RestrictPublicBuckets),
+	}, nil
+}
+
+// PutPublicAccessBlock replaces the public access block, nil removes it
+func (s S3Repository) PutPublicAccessBlock(bucketName string, block *PublicAccessBlock) error {
+	if block == nil {
+		return s.putSetting("delete-public-access-block", bucketName, func() error {
+			_, err := s.Client.DeletePublicAccessBlock(context.TODO(), &s3.DeletePublicAccessBlockInput{Bucket: &bucketName})
+			return err
+		})
+	}
+	return s.putSetting("put-public-access-block", bucketName, func() error {
+		_, err := s.Client.PutPublicAccessBlock(context.TODO(), &s3.PutPublicAccessBlockInput{
+			Bucket: &bucketName,
+			PublicAccessBlockConfiguration: &types.PublicAccessBlockConfiguration{
+				BlockPublicAcls:       aws.Bool(block.BlockPublicAcls),
+				IgnorePublicAcls:      aws.Bool(block.IgnorePublicAcls),
+				BlockPublicPolicy:     aws.Bool(block.BlockPublicPolicy),
+				RestrictPublicBuckets: aws.Bool(block.RestrictPublicBuckets),
+			},
+		})
+		return err
+	})
+}
+
+// putSetting runs a call that modifies a bucket configuration, refusing it in
+// read-only mode and auditing it.
+func (s S3Repository) putSetting(operation, bucketName string, call func() error) error {
+	entry := audit.Entry{Operation: operation, Bucket: bucketName}
+	if err := s.refuse(entry); err != nil {
+		return err
+	}
+	err := call()
+	s.Audit.Record(entry, err)
+	if err != nil {
+		return fmt.Errorf("could not %s %s: %w", operation, bucketName, err)
+	}
+	return nil
+}
+
+// optionalString leaves empty strings out of a request
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/Wondrous27/s3-tui/object"
+)
+
+func (c *CLI) cat(fs *flag.FlagSet, args []string) error {
+	meta := fs.Bool("meta", false, "print the object's metadata above its content")
+	asJSON := fs.Bool("json", false, "print the object and its content as JSON")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		fs.Usage()
+		return fmt.Errorf("cat takes exactly one s3:// URI")
+	}
+
+	bucketName, key, err := object.ParseURI(args[0])
+	if err != nil {
+		return err
+	}
+	obj, err := c.Or.GetObject(bucketName, key)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case *asJSON:
+		return c.printJSON(obj)
+	case *meta:
+		_, err = fmt.Fprintln(c.Out, object.FormatObject(*obj))
+	default:
+		_, err = io.WriteString(c.Out, obj.Content)
+	}
+	return err
+}
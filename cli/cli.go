@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Wondrous27/s3-tui/bucket"
+	"github.com/Wondrous27/s3-tui/object"
+	"github.com/Wondrous27/s3-tui/trash"
+)
+
+// CLI runs the non-interactive subcommands against the same repositories
+// the TUI uses.
+type CLI struct {
+	Br *bucket.S3Repository
+	Or *object.S3Repository
+	// Trash receives objects removed by rm, nil deletes them permanently
+	Trash *trash.Trash
+	Out   io.Writer
+}
+
+type command struct {
+	usage string
+	run   func(c *CLI, fs *flag.FlagSet, args []string) error
+}
+
+var commands = map[string]command{
+	"ls":   {"ls [-r] [--json] [s3://bucket/prefix]", (*CLI).ls},
+	"cat":  {"cat [--meta] [--json] s3://bucket/key", (*CLI).cat},
+	"cp":   {"cp [-r] [--json] <source> <destination>", (*CLI).cp},
+	"rm":   {"rm [-r] [--json] s3://bucket/key", (*CLI).rm},
+	"tree": {"tree [--json] s3://bucket/prefix", (*CLI).tree},
+	"sync": {"sync [--delete] [--dryrun] [--workers n] [--json] <source> <destination>", (*CLI).sync},
+}
+
+// IsCommand reports whether name is a subcommand rather than an s3:// URI
+func IsCommand(name string) bool {
+	_, ok := commands[name]
+	return ok
+}
+
+// Usage lists the subcommands for the top-level help
+func Usage() string {
+	s := "Commands:\n"
+	for _, name := range []string{"ls", "cat", "cp", "rm", "tree", "sync"} {
+		s += fmt.Sprintf("  %s\n", commands[name].usage)
+	}
+	return s
+}
+
+func New(br *bucket.S3Repository, or *object.S3Repository) *CLI {
+	return &CLI{Br: br, Or: or, Out: os.Stdout}
+}
+
+// Run executes the subcommand named by args[0]
+func (c *CLI) Run(args []string) error {
+	name := args[0]
+	cmd, ok := commands[name]
+	if !ok {
+		return fmt.Errorf("unknown command %s", name)
+	}
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: s3-tui %s\n", cmd.usage)
+		fs.PrintDefaults()
+	}
+	return cmd.run(c, fs, args[1:])
+}
+
+// parseArgs parses flags wherever they appear, so `ls s3://b --json` works
+// the same as `ls --json s3://b`.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+func (c *CLI) printJSON(v any) error {
+	enc := json.NewEncoder(c.Out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,190 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/Wondrous27/s3-tui/object"
+)
+
+// location is either side of a copy, an s3:// URI or a local path
+type location struct {
+	bucket string
+	key    string
+	local  string
+}
+
+func (l location) remote() bool { return l.bucket != "" }
+
+func (l location) String() string {
+	if l.remote() {
+		return fmt.Sprintf("s3://%s/%s", l.bucket, l.key)
+	}
+	return l.local
+}
+
+// join appends a relative slash separated name to a location
+func (l location) join(name string) location {
+	if l.remote() {
+		if l.key != "" && !strings.HasSuffix(l.key, "/") {
+			l.key += "/"
+		}
+		l.key += name
+		return l
+	}
+	l.local = filepath.Join(l.local, filepath.FromSlash(name))
+	return l
+}
+
+// isDir reports whether a destination should receive the source's base name
+func (l location) isDir() bool {
+	if l.remote() {
+		return l.key == "" || strings.HasSuffix(l.key, "/")
+	}
+	info, err := os.Stat(l.local)
+	return strings.HasSuffix(l.local, string(os.PathSeparator)) || (err == nil && info.IsDir())
+}
+
+// dirPrefix makes a key the prefix of a directory, so that listing logs does
+// not include logs-old/
+func dirPrefix(key string) string {
+	if key != "" && !strings.HasSuffix(key, "/") {
+		key += "/"
+	}
+	return key
+}
+
+func parseLocation(arg string) (location, error) {
+	if !strings.HasPrefix(arg, "s3://") {
+		return location{local: arg}, nil
+	}
+	bucket, key, err := object.ParseURI(arg)
+	return location{bucket: bucket, key: key}, err
+}
+
+type copied struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+}
+
+func (c *CLI) cp(fs *flag.FlagSet, args []string) error {
+	recursive := fs.Bool("r", false, "copy every object below the source prefix or directory")
+	asJSON := fs.Bool("json", false, "print the copied objects as JSON")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 2 {
+		fs.Usage()
+		return fmt.Errorf("cp takes a source and a destination")
+	}
+
+	src, err := parseLocation(args[0])
+	if err != nil {
+		return err
+	}
+	dst, err := parseLocation(args[1])
+	if err != nil {
+		return err
+	}
+	if !src.remote() && !dst.remote() {
+		return fmt.Errorf("cp needs at least one s3:// location")
+	}
+
+	pairs, skipped, err := c.copyPairs(src, dst, *recursive)
+	if err != nil {
+		return err
+	}
+	if !*asJSON {
+		for _, s := range skipped {
+			fmt.Fprintf(c.Out, "skip: %s (the key leads outside of %s)\n", s, dst.local)
+		}
+	}
+	done := []copied{}
+	for _, p := range pairs {
+		if err := c.copyOne(p[0], p[1]); err != nil {
+			return err
+		}
+		done = append(done, copied{Source: p[0].String(), Destination: p[1].String()})
+		if !*asJSON {
+			fmt.Fprintf(c.Out, "copy: %s to %s\n", p[0], p[1])
+		}
+	}
+	if *asJSON {
+		if err := c.printJSON(done); err != nil {
+			return err
+		}
+	}
+	if len(skipped) > 0 {
+		return fmt.Errorf("some objects were skipped, their keys lead outside of %s", dst.local)
+	}
+	return nil
+}
+
+// copyPairs expands a copy into single source and destination objects.
+// skipped are the objects whose keys lead outside of a local destination.
+func (c *CLI) copyPairs(src, dst location, recursive bool) (pairs [][2]location, skipped []location, err error) {
+	if !recursive {
+		if dst.isDir() {
+			name := path.Base(src.key)
+			if !src.remote() {
+				name = filepath.Base(src.local)
+			}
+			dst = dst.join(name)
+		}
+		return [][2]location{{src, dst}}, nil, nil
+	}
+
+	if src.remote() {
+		prefix := dirPrefix(src.key)
+		objects, err := c.Or.ListPrefix(src.bucket, prefix)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, obj := range objects {
+			rel := strings.TrimPrefix(obj.Key, prefix)
+			if _, ok := object.LocalPath(dst.local, rel); !dst.remote() && !ok {
+				skipped = append(skipped, location{bucket: src.bucket, key: obj.Key})
+				continue
+			}
+			pairs = append(pairs, [2]location{{bucket: src.bucket, key: obj.Key}, dst.join(rel)})
+		}
+		return pairs, skipped, nil
+	}
+
+	err = filepath.WalkDir(src.local, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(src.local, p)
+		if err != nil {
+			return err
+		}
+		pairs = append(pairs, [2]location{{local: p}, dst.join(filepath.ToSlash(rel))})
+		return nil
+	})
+	return pairs, nil, err
+}
+
+func (c *CLI) copyOne(src, dst location) error {
+	switch {
+	case src.remote() && dst.remote():
+		return c.Or.CopyObject(src.bucket, src.key, dst.bucket, dst.key)
+
+	case dst.remote():
+		file, err := os.Open(src.local)
+		if err != nil {
+			return fmt.Errorf("could not open %s: %w", src.local, err)
+		}
+		defer file.Close()
+		return c.Or.PutObject(file, dst.bucket, dst.key)
+
+	default:
+		return c.Or.DownloadFile(src.bucket, src.key, dst.local)
+	}
+}
@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/Wondrous27/s3-tui/bucket"
+	"github.com/Wondrous27/s3-tui/object"
+	"github.com/Wondrous27/s3-tui/tree"
+)
+
+type listing struct {
+	Prefixes []string        `json:"prefixes"`
+	Objects  []object.Object `json:"objects"`
+}
+
+func (c *CLI) ls(fs *flag.FlagSet, args []string) error {
+	recursive := fs.Bool("r", false, "list every object below the prefix")
+	asJSON := fs.Bool("json", false, "print JSON instead of text")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return c.lsBuckets(*asJSON)
+	}
+
+	bucketName, prefix, err := object.ParseURI(args[0])
+	if err != nil {
+		return err
+	}
+	objects, err := c.Or.ListPrefix(bucketName, prefix)
+	if err != nil {
+		return err
+	}
+	if *recursive {
+		if *asJSON {
+			return c.printJSON(objects)
+		}
+		for _, obj := range objects {
+			fmt.Fprintln(c.Out, object.FormatListing(obj))
+		}
+		return nil
+	}
+
+	l := groupListing(objects, prefix)
+	if *asJSON {
+		return c.printJSON(l)
+	}
+	for _, p := range l.Prefixes {
+		fmt.Fprintf(c.Out, "%30s %s\n", "PRE", p)
+	}
+	for _, obj := range l.Objects {
+		fmt.Fprintln(c.Out, object.FormatListing(obj))
+	}
+	return nil
+}
+
+func (c *CLI) lsBuckets(asJSON bool) error {
+	items, err := c.Br.GetAllBuckets()
+	if err != nil {
+		return err
+	}
+	buckets := make([]bucket.Bucket, 0, len(items))
+	for _, item := range items {
+		buckets = append(buckets, item.(bucket.Bucket))
+	}
+	if asJSON {
+		return c.printJSON(buckets)
+	}
+	for _, b := range buckets {
+		fmt.Fprintln(c.Out, bucket.FormatBucket(b))
+	}
+	return nil
+}
+
+// groupListing splits the objects below prefix into the directories and files
+// of a single level, grouped by the same tree the TUI shows.
+func groupListing(objects []object.Object, prefix string) listing {
+	byKey := make(map[string]object.Object, len(objects))
+	keys := make([]string, 0, len(objects))
+	for _, obj := range objects {
+		byKey[obj.Key] = obj
+		keys = append(keys, obj.Key)
+	}
+	ft := tree.NewFileTree(keys)
+
+	// A prefix naming a directory lists its contents, anything else lists
+	// the entries of the enclosing directory that start with it.
+	dir, name := ft.Root.Find(prefix), ""
+	if dir == nil || !dir.IsDir {
+		parent, base := path.Split(prefix)
+		dir, name = ft.Root.Find(parent), base
+	}
+
+	l := listing{Prefixes: []string{}, Objects: []object.Object{}}
+	if dir == nil {
+		return l
+	}
+	for _, child := range dir.Children {
+		if !strings.HasPrefix(child.Name, name) {
+			continue
+		}
+		if child.IsDir {
+			l.Prefixes = append(l.Prefixes, child.Path()+"/")
+		} else {
+			l.Objects = append(l.Objects, byKey[child.Path()])
+		}
+	}
+	return l
+}
@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/Wondrous27/s3-tui/object"
+)
+
+type removed struct {
+	Bucket string `json:"bucket"`
+	Key    string `json:"key"`
+}
+
+func (c *CLI) rm(fs *flag.FlagSet, args []string) error {
+	recursive := fs.Bool("r", false, "delete every object below the prefix")
+	asJSON := fs.Bool("json", false, "print the deleted objects as JSON")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		fs.Usage()
+		return fmt.Errorf("rm takes exactly one s3:// URI")
+	}
+
+	bucketName, key, err := object.ParseURI(args[0])
+	if err != nil {
+		return err
+	}
+	keys := []string{key}
+	if *recursive {
+		objects, err := c.Or.ListPrefix(bucketName, dirPrefix(key))
+		if err != nil {
+			return err
+		}
+		keys = keys[:0]
+		for _, obj := range objects {
+			if !c.Trash.Hides(obj.Key) {
+				keys = append(keys, obj.Key)
+			}
+		}
+	}
+
+	deleted := []removed{}
+	for _, k := range keys {
+		if err := c.remove(bucketName, k); err != nil {
+			return err
+		}
+		deleted = append(deleted, removed{Bucket: bucketName, Key: k})
+		if !*asJSON {
+			fmt.Fprintf(c.Out, "delete: s3://%s/%s\n", bucketName, k)
+		}
+	}
+	if *asJSON {
+		return c.printJSON(deleted)
+	}
+	return nil
+}
+
+func (c *CLI) remove(bucketName, key string) error {
+	if c.Trash != nil {
+		_, err := c.Trash.Delete(bucketName, key)
+		return err
+	}
+	return c.Or.DeleteObject(bucketName, key)
+}
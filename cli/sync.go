@@ -0,0 +1,132 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Wondrous27/s3-tui/object"
+)
+
+type syncFailure struct {
+	Op    object.SyncOp `json:"op"`
+	Error string        `json:"error"`
+}
+
+type syncSummary struct {
+	object.SyncReport
+	Skipped []object.SyncOp `json:"skipped"`
+	Failed  []syncFailure   `json:"failed"`
+}
+
+func (c *CLI) sync(fs *flag.FlagSet, args []string) error {
+	del := fs.Bool("delete", false, "delete what exists only at the destination")
+	dryRun := fs.Bool("dryrun", false, "print the plan without applying it")
+	workers := fs.Int("workers", 8, "how many transfers are made at once")
+	asJSON := fs.Bool("json", false, "print the plan, or the report, as JSON")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 2 {
+		fs.Usage()
+		return fmt.Errorf("sync takes a source and a destination")
+	}
+
+	src, err := parseLocation(args[0])
+	if err != nil {
+		return err
+	}
+	dst, err := parseLocation(args[1])
+	if err != nil {
+		return err
+	}
+	var plan *object.SyncPlan
+	opts := object.SyncOptions{Delete: *del, Exclude: c.Trash.Hides}
+	switch {
+	case !src.remote() && dst.remote():
+		plan, err = c.Or.PlanSync(object.SyncUp, dst.bucket, dst.key, src.local, opts)
+	case src.remote() && !dst.remote():
+		plan, err = c.Or.PlanSync(object.SyncDown, src.bucket, src.key, dst.local, opts)
+	default:
+		return fmt.Errorf("sync needs a local directory and an s3:// prefix")
+	}
+	if err != nil {
+		return err
+	}
+
+	if *dryRun {
+		if *asJSON {
+			return c.printJSON(plan)
+		}
+		for _, op := range plan.Ops {
+			fmt.Fprintf(c.Out, "(dryrun) %s (%s)\n", describeSyncOp(plan, op), op.Reason)
+		}
+		c.printSkipped(plan)
+		fmt.Fprintf(c.Out, "%d to sync, %d unchanged, %d skipped\n", len(plan.Ops), plan.Unchanged, len(plan.Skipped))
+		return nil
+	}
+	if !*asJSON {
+		c.printSkipped(plan)
+	}
+
+	var mu sync.Mutex
+	report := c.Or.ApplySync(context.Background(), plan, *workers, c.remove, func(result object.SyncResult) {
+		if *asJSON {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if result.Err != nil {
+			fmt.Fprintf(c.Out, "failed: %s: %v\n", describeSyncOp(plan, result.Op), result.Err)
+			return
+		}
+		fmt.Fprintln(c.Out, describeSyncOp(plan, result.Op))
+	})
+	summary := syncSummary{SyncReport: report, Skipped: plan.Skipped, Failed: []syncFailure{}}
+	if summary.Skipped == nil {
+		summary.Skipped = []object.SyncOp{}
+	}
+	for _, failed := range report.Failed {
+		summary.Failed = append(summary.Failed, syncFailure{Op: failed.Op, Error: failed.Err.Error()})
+	}
+	if *asJSON {
+		if err := c.printJSON(summary); err != nil {
+			return err
+		}
+	} else {
+		fmt.Fprintf(c.Out, "%d uploaded, %d downloaded, %d deleted, %d unchanged, %d skipped, %d bytes in %s\n",
+			report.Uploaded, report.Downloaded, report.Deleted, plan.Unchanged, len(plan.Skipped), report.Bytes, report.Elapsed.Round(time.Millisecond))
+	}
+	if len(report.Failed) > 0 {
+		return fmt.Errorf("%d of %d steps failed", len(report.Failed), len(plan.Ops))
+	}
+	if len(plan.Skipped) > 0 {
+		return fmt.Errorf("some objects were skipped, their keys lead outside of %s", plan.Dir)
+	}
+	return nil
+}
+
+// printSkipped lists the objects a download leaves out
+func (c *CLI) printSkipped(plan *object.SyncPlan) {
+	for _, op := range plan.Skipped {
+		fmt.Fprintf(c.Out, "skip: %s (%s)\n", object.URI(plan.Bucket, op.Key), op.Reason)
+	}
+}
+
+// describeSyncOp prints a step of a plan the way cp and rm print theirs
+func describeSyncOp(plan *object.SyncPlan, op object.SyncOp) string {
+	uri := object.URI(plan.Bucket, op.Key)
+	switch op.Action {
+	case object.SyncUpload:
+		return fmt.Sprintf("upload: %s to %s", op.Path, uri)
+	case object.SyncDownload:
+		return fmt.Sprintf("download: %s to %s", uri, op.Path)
+	}
+	if op.Key != "" {
+		return "delete: " + uri
+	}
+	return "delete: " + op.Path
+}
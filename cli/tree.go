@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/Wondrous27/s3-tui/object"
+	"github.com/Wondrous27/s3-tui/tree"
+)
+
+type treeNode struct {
+	Name     string      `json:"name"`
+	Key      string      `json:"key"`
+	IsDir    bool        `json:"is_dir"`
+	Children []*treeNode `json:"children,omitempty"`
+}
+
+func (c *CLI) tree(fs *flag.FlagSet, args []string) error {
+	asJSON := fs.Bool("json", false, "print the tree as nested JSON")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		fs.Usage()
+		return fmt.Errorf("tree takes exactly one s3:// URI")
+	}
+
+	bucketName, prefix, err := object.ParseURI(args[0])
+	if err != nil {
+		return err
+	}
+	objects, err := c.Or.ListPrefix(bucketName, prefix)
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(objects))
+	for _, obj := range objects {
+		keys = append(keys, obj.Key)
+	}
+	root := tree.NewFileTree(keys).Root.Find(prefix)
+	if root == nil {
+		return fmt.Errorf("s3://%s/%s does not exist", bucketName, prefix)
+	}
+
+	if *asJSON {
+		return c.printJSON(toTreeNode(root))
+	}
+	fmt.Fprintf(c.Out, "s3://%s/%s\n", bucketName, prefix)
+	c.printTree(root, "")
+	return nil
+}
+
+func (c *CLI) printTree(n *tree.Node, indent string) {
+	for i, child := range n.Children {
+		branch, next := "├── ", "│   "
+		if i == len(n.Children)-1 {
+			branch, next = "└── ", "    "
+		}
+		name := child.Name
+		if child.IsDir {
+			name += "/"
+		}
+		fmt.Fprintln(c.Out, indent+branch+name)
+		c.printTree(child, indent+next)
+	}
+}
+
+func toTreeNode(n *tree.Node) *treeNode {
+	t := &treeNode{Name: n.Name, Key: n.Path(), IsDir: n.IsDir}
+	for _, child := range n.Children {
+		t.Children = append(t.Children, toTreeNode(child))
+	}
+	return t
+}
@@ -0,0 +1,118 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the s3-tui config file. Command-line flags take precedence over
+// every value in it.
+type Config struct {
+	Profile     string `yaml:"profile"`
+	Region      string `yaml:"region"`
+	EndpointURL string `yaml:"endpoint_url"`
+	ReadOnly    bool   `yaml:"read_only"`
+	LogFile     string `yaml:"log_file"`
+	// AuditLog is the JSON lines file every mutating operation is recorded in
+	AuditLog string `yaml:"audit_log"`
+	Trash    Trash  `yaml:"trash"`
+	// Encryption requires server-side encryption on some prefixes
+	Encryption []EncryptionRule `yaml:"encryption"`
+	// ClientEncryption encrypts the content of some prefixes before it is
+	// sent to S3
+	ClientEncryption []ClientEncryptionRule `yaml:"client_encryption"`
+	Grep             Grep                   `yaml:"grep"`
+}
+
+// Trash enables moving deleted objects to a trash instead of deleting them.
+// Dir keeps them on the local disk, Prefix in a prefix of their own bucket.
+type Trash struct {
+	Dir    string `yaml:"dir"`
+	Prefix string `yaml:"prefix"`
+}
+
+func (t Trash) Enabled() bool {
+	return t.Dir != "" || t.Prefix != ""
+}
+
+// EncryptionRule requires the objects written under Prefix to use a
+// server-side encryption mode, SSE-S3, SSE-KMS or SSE-C. SSE-KMS may be
+// limited to KMSKeyIDs, the first being the default, and SSE-C reads its key
+// from CustomerKeyFile.
+type EncryptionRule struct {
+	// Bucket limits the rule to one bucket, empty applies it to every one
+	Bucket          string   `yaml:"bucket"`
+	Prefix          string   `yaml:"prefix"`
+	Mode            string   `yaml:"mode"`
+	KMSKeyIDs       []string `yaml:"kms_key_ids"`
+	CustomerKeyFile string   `yaml:"customer_key_file"`
+}
+
+// ClientEncryptionRule encrypts the objects written under Prefix in an
+// AES-GCM envelope, with a data key per object wrapped by the 256 bit key in
+// KeyFile. Objects record which key they use, so any rule's key reads them
+// wherever they are copied.
+type ClientEncryptionRule struct {
+	// Bucket limits the rule to one bucket, empty applies it to every one
+	Bucket  string `yaml:"bucket"`
+	Prefix  string `yaml:"prefix"`
+	KeyFile string `yaml:"key_file"`
+}
+
+// Grep bounds the searches through the content of objects
+type Grep struct {
+	// Workers is how many objects are read at once
+	Workers int `yaml:"workers"`
+	// MaxSize skips the objects larger than this many bytes
+	MaxSize int64 `yaml:"max_size"`
+}
+
+// DefaultPath returns the config file used when --config is not given.
+func DefaultPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "s3-tui", "config.yaml")
+}
+
+func defaultAuditLog() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "s3-tui-audit.log"
+	}
+	return filepath.Join(dir, "s3-tui", "audit.log")
+}
+
+// Load reads the config file at path. A missing file is not an error, so the
+// tool works without any configuration.
+func Load(path string) (*Config, error) {
+	cfg := &Config{}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("could not read config %s: %w", path, err)
+		}
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("could not parse config %s: %w", path, err)
+		}
+	}
+	if cfg.LogFile == "" {
+		cfg.LogFile = "debug.log"
+	}
+	if cfg.Grep.Workers == 0 {
+		cfg.Grep.Workers = 8
+	}
+	if cfg.Grep.MaxSize == 0 {
+		cfg.Grep.MaxSize = 64 << 20
+	}
+	if cfg.AuditLog == "" {
+		cfg.AuditLog = defaultAuditLog()
+	}
+	return cfg, nil
+}
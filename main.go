@@ -2,29 +2,182 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
+	"log"
 	"os"
 
+	"github.com/Wondrous27/s3-tui/audit"
+	"github.com/Wondrous27/s3-tui/auth"
 	"github.com/Wondrous27/s3-tui/bucket"
+	"github.com/Wondrous27/s3-tui/cli"
+	"github.com/Wondrous27/s3-tui/config"
 	"github.com/Wondrous27/s3-tui/object"
+	"github.com/Wondrous27/s3-tui/trash"
 	"github.com/Wondrous27/s3-tui/tui"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 )
 
 func main() {
-	region, ok := os.LookupEnv("AWS_REGION")
-	if !ok {
-		fmt.Println("AWS_REGION environment variable not set")
+	var (
+		configPath  = flag.String("config", config.DefaultPath(), "path to the s3-tui config file")
+		profile     = flag.String("profile", "", "AWS shared config profile to use")
+		region      = flag.String("region", "", "AWS region, overrides the profile and environment")
+		endpointURL = flag.String("endpoint-url", "", "custom S3 endpoint, e.g. for MinIO or LocalStack")
+		readOnly    = flag.Bool("read-only", false, "refuse every operation that modifies buckets or objects")
+		logFile     = flag.String("log-file", "", "file to write the debug log to (default debug.log)")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [s3://bucket/prefix/key]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s [flags] <command> [args]\n\n", os.Args[0])
+		fmt.Fprint(flag.CommandLine.Output(), cli.Usage())
+		fmt.Fprintln(flag.CommandLine.Output(), "\nFlags:")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Println(err)
 		os.Exit(1)
 	}
-	cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(region))
+	// Flags given on the command line win over the config file
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "profile":
+			cfg.Profile = *profile
+		case "region":
+			cfg.Region = *region
+		case "endpoint-url":
+			cfg.EndpointURL = *endpointURL
+		case "read-only":
+			cfg.ReadOnly = *readOnly
+		case "log-file":
+			cfg.LogFile = *logFile
+		}
+	})
+
+	// The region comes from the SDK chain (env vars, then the profile) and is
+	// prompted for in the TUI when none of them set one.
+	session, err := auth.NewSession(context.TODO(), cfg.Profile, cfg.Region)
 	if err != nil {
 		fmt.Println(err)
+		os.Exit(1)
+	}
+	session.EndpointURL = cfg.EndpointURL
+
+	auditLog := &audit.Log{
+		Path: cfg.AuditLog,
+		Principal: func() (string, string) {
+			return session.Profile, session.Account(context.Background())
+		},
+	}
+
+	client := session.S3Client()
+	br := &bucket.S3Repository{Client: client, ReadOnly: cfg.ReadOnly, Audit: auditLog}
+	or := &object.S3Repository{Client: client, ReadOnly: cfg.ReadOnly, Audit: auditLog, KMS: session.KMSClient()}
+	if or.EncryptionRules, err = encryptionRules(cfg.Encryption); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if or.ClientEncryptionRules, err = clientEncryptionRules(cfg.ClientEncryption); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	var bin *trash.Trash
+	if cfg.Trash.Enabled() {
+		bin = &trash.Trash{Or: or, Dir: cfg.Trash.Dir, Prefix: cfg.Trash.Prefix}
 	}
 
-	client := s3.NewFromConfig(cfg)
-	br := &bucket.S3Repository{Client: client}
-	or := &object.S3Repository{Client: client}
-	tui.StartTea(br, or)
+	if cli.IsCommand(flag.Arg(0)) {
+		if err := runCommand(session, br, or, bin, cfg); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	opts := tui.Options{
+		LogFile: cfg.LogFile,
+		Trash:   bin,
+		Grep:    object.GrepOptions{Workers: cfg.Grep.Workers, MaxSize: cfg.Grep.MaxSize},
+	}
+	if uri := flag.Arg(0); uri != "" {
+		opts.Bucket, opts.Key, err = object.ParseURI(uri)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	tui.StartTea(session, br, or, opts)
+}
+
+// runCommand runs a subcommand without starting the TUI. Anything the TUI
+// would prompt for has to come from flags, the config or stdin instead.
+func runCommand(session *auth.Session, br *bucket.S3Repository, or *object.S3Repository, bin *trash.Trash, cfg *config.Config) error {
+	logFile, err := os.OpenFile(cfg.LogFile, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("could not open log file: %w", err)
+	}
+	defer logFile.Close()
+	log.SetOutput(logFile)
+
+	if session.Config.Region == "" {
+		return fmt.Errorf("no region configured, pass --region or set AWS_REGION")
+	}
+	if session.NeedsMFA(context.TODO()) {
+		fmt.Fprintf(os.Stderr, "MFA token for %s: ", session.MFASerial)
+		var token string
+		if _, err := fmt.Scanln(&token); err != nil {
+			return fmt.Errorf("could not read MFA token: %w", err)
+		}
+		if err := session.Login(context.TODO(), token); err != nil {
+			return err
+		}
+	}
+	c := cli.New(br, or)
+	c.Trash = bin
+	return c.Run(flag.Args())
+}
+
+// encryptionRules checks the encryption rules of the config and reads the
+// keys of the SSE-C ones.
+func encryptionRules(rules []config.EncryptionRule) ([]object.EncryptionRule, error) {
+	result := make([]object.EncryptionRule, 0, len(rules))
+	for _, rule := range rules {
+		r := object.EncryptionRule{Bucket: rule.Bucket, Prefix: rule.Prefix, Mode: rule.Mode, KMSKeyIDs: rule.KMSKeyIDs}
+		switch rule.Mode {
+		case object.SSES3, object.SSEKMS:
+		case object.SSEC:
+			if rule.CustomerKeyFile == "" {
+				return nil, fmt.Errorf("the SSE-C rule for %s needs a customer_key_file", rule.Prefix)
+			}
+			key, err := object.ReadCustomerKey(rule.CustomerKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			r.CustomerKey = key
+		default:
+			return nil, fmt.Errorf("the encryption rule for %s has mode %q, expected SSE-S3, SSE-KMS or SSE-C", rule.Prefix, rule.Mode)
+		}
+		result = append(result, r)
+	}
+	return result, nil
+}
+
+// clientEncryptionRules reads the keys of the client-side encryption rules of
+// the config.
+func clientEncryptionRules(rules []config.ClientEncryptionRule) ([]object.ClientEncryptionRule, error) {
+	result := make([]object.ClientEncryptionRule, 0, len(rules))
+	for _, rule := range rules {
+		if rule.KeyFile == "" {
+			return nil, fmt.Errorf("the client_encryption rule for %s needs a key_file", rule.Prefix)
+		}
+		key, err := object.ReadCustomerKey(rule.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, object.ClientEncryptionRule{Bucket: rule.Bucket, Prefix: rule.Prefix, Key: key})
+	}
+	return result, nil
 }
@@ -0,0 +1,284 @@
+package object
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// Server-side encryption modes
+const (
+	SSES3  = "SSE-S3"
+	SSEKMS = "SSE-KMS"
+	SSEC   = "SSE-C"
+)
+
+// EncryptionModes are the modes an object can be written with, the empty one
+// leaves it to the bucket's default encryption
+var EncryptionModes = []string{"", SSES3, SSEKMS, SSEC}
+
+// ErrCustomerKeyRequired is returned when reading an object encrypted with
+// SSE-C without its key
+var ErrCustomerKeyRequired = errors.New("the object is encrypted with a customer key (SSE-C) that has to be supplied to read it")
+
+// Encryption is how S3 encrypts an object at rest
+type Encryption struct {
+	Mode string `json:"mode,omitempty"`
+	// KMSKeyID is the key ID, ARN or alias of SSE-KMS, empty for the AWS
+	// managed key
+	KMSKeyID string `json:"kms_key_id,omitempty"`
+	// CustomerKey is the 256 bit key of SSE-C, never written anywhere
+	CustomerKey []byte `json:"-"`
+	// ClientKey is the ID of the key the content is encrypted with before it
+	// is sent, empty when S3 receives it in clear
+	ClientKey string `json:"client_key,omitempty"`
+}
+
+func (e Encryption) String() string {
+	mode := e.Mode
+	switch {
+	case e.Mode == "":
+		mode = "bucket default"
+	case e.Mode == SSEKMS && e.KMSKeyID != "":
+		mode = SSEKMS + " " + e.KMSKeyID
+	}
+	if e.ClientKey != "" {
+		return mode + ", client-side AES-GCM key " + e.ClientKey
+	}
+	return mode
+}
+
+// EncryptionRule requires the objects under a prefix to be encrypted with a
+// mode, and for SSE-KMS with one of a set of keys
+type EncryptionRule struct {
+	// Bucket is the bucket the rule applies to, empty for every bucket
+	Bucket string
+	Prefix string
+	Mode   string
+	// KMSKeyIDs are the keys SSE-KMS may use, the first is the default
+	KMSKeyIDs []string
+	// CustomerKey is the key of SSE-C, also used to read the objects
+	CustomerKey []byte
+}
+
+// ReadCustomerKey reads an SSE-C or client-side key from a file holding the
+// 32 bytes of the key, or their base64 encoding
+func ReadCustomerKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the key: %w", err)
+	}
+	if len(data) == 32 {
+		return data, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("%s does not hold a 256 bit key, raw or base64 encoded", path)
+	}
+	return key, nil
+}
+
+// encryptionRule returns the rule with the longest prefix matching key
+func (s S3Repository) encryptionRule(bucket, key string) *EncryptionRule {
+	var match *EncryptionRule
+	for i, rule := range s.EncryptionRules {
+		if rule.Bucket != "" && rule.Bucket != bucket || !strings.HasPrefix(key, rule.Prefix) {
+			continue
+		}
+		if match == nil || len(rule.Prefix) > len(match.Prefix) {
+			match = &s.EncryptionRules[i]
+		}
+	}
+	return match
+}
+
+// DefaultEncryption is the encryption the rules ask for at key, the bucket
+// default when no rule matches
+func (s S3Repository) DefaultEncryption(bucket, key string) Encryption {
+	var enc Encryption
+	if rule := s.encryptionRule(bucket, key); rule != nil {
+		enc = Encryption{Mode: rule.Mode, CustomerKey: rule.CustomerKey}
+		if len(rule.KMSKeyIDs) > 0 {
+			enc.KMSKeyID = rule.KMSKeyIDs[0]
+		}
+	}
+	if rule := s.clientEncryptionRule(bucket, key); rule != nil {
+		enc.ClientKey = ClientKeyID(rule.Key)
+	}
+	return enc
+}
+
+// AllowedKMSKeys are the keys SSE-KMS may use at key, nil when any key can
+func (s S3Repository) AllowedKMSKeys(bucket, key string) []string {
+	if rule := s.encryptionRule(bucket, key); rule != nil {
+		return rule.KMSKeyIDs
+	}
+	return nil
+}
+
+// CheckEncryption reports whether enc satisfies the rule at key
+func (s S3Repository) CheckEncryption(bucket, key string, enc Encryption) error {
+	rule := s.encryptionRule(bucket, key)
+	if err := enc.validate(); err != nil {
+		return err
+	}
+	if client := s.clientEncryptionRule(bucket, key); client != nil && enc.ClientKey != ClientKeyID(client.Key) {
+		return fmt.Errorf("objects under s3://%s/%s have to be encrypted client-side with the key %s", bucket, client.Prefix, ClientKeyID(client.Key))
+	}
+	if enc.ClientKey != "" && s.clientKey(enc.ClientKey) == nil {
+		return fmt.Errorf("client key %s: %w", enc.ClientKey, ErrClientKeyMissing)
+	}
+	if rule == nil {
+		return nil
+	}
+	if enc.Mode != rule.Mode {
+		return fmt.Errorf("objects under s3://%s/%s have to be encrypted with %s, not %s", bucket, rule.Prefix, rule.Mode, enc)
+	}
+	if enc.Mode == SSEKMS && len(rule.KMSKeyIDs) > 0 && !slices.ContainsFunc(rule.KMSKeyIDs, func(id string) bool {
+		return s.sameKMSKey(id, enc.KMSKeyID)
+	}) {
+		return fmt.Errorf("objects under s3://%s/%s have to use one of the KMS keys %s", bucket, rule.Prefix, strings.Join(rule.KMSKeyIDs, ", "))
+	}
+	return nil
+}
+
+// sameKMSKey reports whether two key IDs, ARNs or aliases name the same key.
+// S3 reports the ARN of the key an object uses, while rules often name an
+// alias, so they are resolved through KMS when they differ.
+func (s S3Repository) sameKMSKey(a, b string) bool {
+	if a == b {
+		return true
+	}
+	if s.KMS == nil || a == "" || b == "" {
+		return false
+	}
+	arn := func(id string) string {
+		out, err := s.KMS.DescribeKey(context.TODO(), &kms.DescribeKeyInput{KeyId: &id})
+		if err != nil || out.KeyMetadata == nil {
+			return id
+		}
+		return aws.ToString(out.KeyMetadata.Arn)
+	}
+	return arn(a) == arn(b)
+}
+
+func (e Encryption) validate() error {
+	switch e.Mode {
+	case "", SSES3, SSEKMS:
+		return nil
+	case SSEC:
+		if len(e.CustomerKey) != 32 {
+			return fmt.Errorf("SSE-C needs a 256 bit customer key")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown encryption mode %q, expected %s, %s or %s", e.Mode, SSES3, SSEKMS, SSEC)
+}
+
+// serverSide returns the headers that ask S3 to encrypt with e
+func (e Encryption) serverSide() (sse types.ServerSideEncryption, kmsKeyID *string) {
+	switch e.Mode {
+	case SSES3:
+		return types.ServerSideEncryptionAes256, nil
+	case SSEKMS:
+		return types.ServerSideEncryptionAwsKms, optionalString(e.KMSKeyID)
+	}
+	return "", nil
+}
+
+// customerKey returns the SSE-C algorithm, key and key MD5 headers, all nil
+// unless e is SSE-C
+func (e Encryption) customerKey() (algorithm, key, keyMD5 *string) {
+	if e.Mode != SSEC {
+		return nil, nil, nil
+	}
+	sum := md5.Sum(e.CustomerKey)
+	return aws.String("AES256"),
+		aws.String(base64.StdEncoding.EncodeToString(e.CustomerKey)),
+		aws.String(base64.StdEncoding.EncodeToString(sum[:]))
+}
+
+// encryptionOf reads the encryption S3 reports for an object, keeping the
+// customer key that was supplied to read it
+func encryptionOf(sse types.ServerSideEncryption, kmsKeyID, customerAlgorithm *string, customerKey []byte) Encryption {
+	switch {
+	case customerAlgorithm != nil:
+		return Encryption{Mode: SSEC, CustomerKey: customerKey}
+	case sse == types.ServerSideEncryptionAwsKms || sse == types.ServerSideEncryptionAwsKmsDsse:
+		return Encryption{Mode: SSEKMS, KMSKeyID: aws.ToString(kmsKeyID)}
+	case sse == types.ServerSideEncryptionAes256:
+		return Encryption{Mode: SSES3}
+	}
+	return Encryption{}
+}
+
+// isCustomerKeyError reports whether S3 refused a read for a missing or
+// wrong SSE-C key
+func isCustomerKeyError(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.ErrorCode() == "InvalidRequest" && strings.Contains(apiErr.ErrorMessage(), "Server Side Encryption") ||
+		apiErr.ErrorCode() == "AccessDenied" && strings.Contains(apiErr.ErrorMessage(), "customer")
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// ListKMSKeys returns the aliases and IDs of the KMS keys SSE-KMS can use,
+// aliases first. AWS managed aliases other than aws/s3 are left out.
+func (s S3Repository) ListKMSKeys() ([]string, error) {
+	if s.KMS == nil {
+		return nil, fmt.Errorf("no KMS client configured")
+	}
+	var aliases []string
+	aliased := map[string]bool{}
+	aliasPages := kms.NewListAliasesPaginator(s.KMS, &kms.ListAliasesInput{})
+	for aliasPages.HasMorePages() {
+		out, err := aliasPages.NextPage(context.TODO())
+		if err != nil {
+			return nil, fmt.Errorf("could not list KMS aliases: %w", err)
+		}
+		for _, alias := range out.Aliases {
+			name := aws.ToString(alias.AliasName)
+			aliased[aws.ToString(alias.TargetKeyId)] = true
+			if strings.HasPrefix(name, "alias/aws/") && name != "alias/aws/s3" {
+				continue
+			}
+			aliases = append(aliases, name)
+		}
+	}
+	sort.Strings(aliases)
+
+	var keys []string
+	keyPages := kms.NewListKeysPaginator(s.KMS, &kms.ListKeysInput{})
+	for keyPages.HasMorePages() {
+		out, err := keyPages.NextPage(context.TODO())
+		if err != nil {
+			return nil, fmt.Errorf("could not list KMS keys: %w", err)
+		}
+		for _, key := range out.Keys {
+			if id := aws.ToString(key.KeyId); !aliased[id] {
+				keys = append(keys, id)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return append(aliases, keys...), nil
+}
@@ -0,0 +1,175 @@
+package object
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Metadata of the objects encrypted client-side. The content is the nonce
+// followed by the AES-GCM ciphertext, under a data key of its own that is
+// stored wrapped by the client key.
+const (
+	envelopeMeta        = "s3tui-envelope"
+	envelopeKeyMeta     = "s3tui-envelope-key"
+	envelopeWrappedMeta = "s3tui-envelope-wrapped"
+	// envelopeVersion is the only format written so far
+	envelopeVersion = "aes-gcm-v1"
+	// envelopeOverhead is how much larger sealing makes the content, by the
+	// nonce and the GCM tag
+	envelopeOverhead = 12 + 16
+)
+
+// ErrClientKeyMissing is returned when reading an object encrypted
+// client-side with a key no rule holds
+var ErrClientKeyMissing = errors.New("the object is encrypted client-side with a key that is not configured")
+
+// ClientEncryptionRule encrypts the objects under a prefix before they are
+// sent to S3, in an AES-GCM envelope under Key
+type ClientEncryptionRule struct {
+	// Bucket is the bucket the rule applies to, empty for every bucket
+	Bucket string
+	Prefix string
+	// Key is the 256 bit key that wraps the data key of every object
+	Key []byte
+}
+
+// ClientKeyID names a client key by the start of its SHA-256, so objects
+// record which key they need without revealing it
+func ClientKeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// clientEncryptionRule returns the rule with the longest prefix matching key
+func (s S3Repository) clientEncryptionRule(bucket, key string) *ClientEncryptionRule {
+	var match *ClientEncryptionRule
+	for i, rule := range s.ClientEncryptionRules {
+		if rule.Bucket != "" && rule.Bucket != bucket || !strings.HasPrefix(key, rule.Prefix) {
+			continue
+		}
+		if match == nil || len(rule.Prefix) > len(match.Prefix) {
+			match = &s.ClientEncryptionRules[i]
+		}
+	}
+	return match
+}
+
+// clientKey finds the key with id among the rules, wherever they apply, so
+// objects copied out of their prefix can still be read
+func (s S3Repository) clientKey(id string) []byte {
+	for _, rule := range s.ClientEncryptionRules {
+		if ClientKeyID(rule.Key) == id {
+			return rule.Key
+		}
+	}
+	return nil
+}
+
+// seal encrypts plaintext under a new data key wrapped by the client key id,
+// and returns the metadata that has to be stored with it
+func (s S3Repository) seal(id string, plaintext []byte) ([]byte, map[string]string, error) {
+	clientKey := s.clientKey(id)
+	if clientKey == nil {
+		return nil, nil, fmt.Errorf("client key %s: %w", id, ErrClientKeyMissing)
+	}
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, nil, fmt.Errorf("could not generate a data key: %w", err)
+	}
+	wrapped, err := gcmSeal(clientKey, dataKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	ciphertext, err := gcmSeal(dataKey, plaintext)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ciphertext, map[string]string{
+		envelopeMeta:        envelopeVersion,
+		envelopeKeyMeta:     id,
+		envelopeWrappedMeta: base64.StdEncoding.EncodeToString(wrapped),
+	}, nil
+}
+
+// open decrypts the content of an object sealed client-side, and returns the
+// ID of the client key it used. Objects without an envelope are returned as
+// they are, with an empty ID.
+func (s S3Repository) open(metadata map[string]string, content []byte) ([]byte, string, error) {
+	version := metadataValue(metadata, envelopeMeta)
+	if version == "" {
+		return content, "", nil
+	}
+	if version != envelopeVersion {
+		return nil, "", fmt.Errorf("unknown client-side encryption %q", version)
+	}
+	id := metadataValue(metadata, envelopeKeyMeta)
+	clientKey := s.clientKey(id)
+	if clientKey == nil {
+		return nil, id, fmt.Errorf("client key %s: %w", id, ErrClientKeyMissing)
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(metadataValue(metadata, envelopeWrappedMeta))
+	if err != nil {
+		return nil, id, fmt.Errorf("the wrapped data key is not base64: %w", err)
+	}
+	dataKey, err := gcmOpen(clientKey, wrapped)
+	if err != nil {
+		return nil, id, fmt.Errorf("could not unwrap the data key: %w", err)
+	}
+	plaintext, err := gcmOpen(dataKey, content)
+	if err != nil {
+		return nil, id, fmt.Errorf("could not decrypt the content: %w", err)
+	}
+	return plaintext, id, nil
+}
+
+// gcmSeal encrypts plaintext with AES-GCM under key, prefixed by its nonce
+func gcmSeal(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("could not generate a nonce: %w", err)
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// gcmOpen decrypts what gcmSeal encrypted
+func gcmOpen(key, sealed []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("the ciphertext is too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// metadataValue looks up user metadata regardless of the case S3 returns the
+// names in
+func metadataValue(metadata map[string]string, name string) string {
+	for k, v := range metadata {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
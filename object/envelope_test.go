@@ -0,0 +1,138 @@
+package object
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSealOpen(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	other := bytes.Repeat([]byte{2}, 32)
+	id := ClientKeyID(key)
+	s := S3Repository{ClientEncryptionRules: []ClientEncryptionRule{{Prefix: "secret/", Key: key}}}
+
+	plaintext := []byte("hello, world")
+	sealed, metadata, err := s.seal(id, plaintext)
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	if len(sealed) != len(plaintext)+envelopeOverhead {
+		t.Errorf("sealed %d bytes into %d, want %d", len(plaintext), len(sealed), len(plaintext)+envelopeOverhead)
+	}
+	if bytes.Contains(sealed, plaintext) {
+		t.Errorf("the sealed content holds the plaintext")
+	}
+	if metadata[envelopeKeyMeta] != id {
+		t.Errorf("metadata key %q, want %q", metadata[envelopeKeyMeta], id)
+	}
+
+	// the names are matched whatever case S3 returns them in
+	upper := map[string]string{}
+	for k, v := range metadata {
+		upper[strings.ToUpper(k)] = v
+	}
+	withMeta := func(name, value string) map[string]string {
+		m := map[string]string{}
+		for k, v := range metadata {
+			m[k] = v
+		}
+		m[name] = value
+		return m
+	}
+	corrupt := bytes.Clone(sealed)
+	corrupt[len(corrupt)-1] ^= 1
+
+	tests := []struct {
+		name     string
+		s        S3Repository
+		metadata map[string]string
+		content  []byte
+		want     []byte
+		wantID   string
+		// wantErr is part of the error, empty when open succeeds
+		wantErr string
+	}{
+		{name: "round trip", s: s, metadata: metadata, content: sealed, want: plaintext, wantID: id},
+		{name: "metadata case", s: s, metadata: upper, content: sealed, want: plaintext, wantID: id},
+		{name: "no envelope", s: s, metadata: map[string]string{"other": "x"}, content: []byte("plain"), want: []byte("plain")},
+		{
+			name: "key outside of its rule's prefix is still found",
+			s: S3Repository{ClientEncryptionRules: []ClientEncryptionRule{
+				{Bucket: "other", Prefix: "elsewhere/", Key: key},
+			}},
+			metadata: metadata, content: sealed, want: plaintext, wantID: id,
+		},
+		{
+			name:     "missing key",
+			s:        S3Repository{ClientEncryptionRules: []ClientEncryptionRule{{Key: other}}},
+			metadata: metadata, content: sealed, wantID: id,
+			wantErr: ErrClientKeyMissing.Error(),
+		},
+		{
+			name:     "unknown version",
+			s:        s,
+			metadata: withMeta(envelopeMeta, "aes-gcm-v9"), content: sealed,
+			wantErr: `unknown client-side encryption "aes-gcm-v9"`,
+		},
+		{
+			name:     "wrapped key not base64",
+			s:        s,
+			metadata: withMeta(envelopeWrappedMeta, "!"), content: sealed, wantID: id,
+			wantErr: "the wrapped data key is not base64",
+		},
+		{
+			name:     "wrapped key under another key",
+			s:        S3Repository{ClientEncryptionRules: []ClientEncryptionRule{{Key: other}}},
+			metadata: withMeta(envelopeKeyMeta, ClientKeyID(other)), content: sealed, wantID: ClientKeyID(other),
+			wantErr: "could not unwrap the data key",
+		},
+		{name: "corrupt content", s: s, metadata: metadata, content: corrupt, wantID: id, wantErr: "could not decrypt the content"},
+		{name: "short content", s: s, metadata: metadata, content: []byte("short"), wantID: id, wantErr: "the ciphertext is too short"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotID, err := tt.s.open(tt.metadata, tt.content)
+			if gotID != tt.wantID {
+				t.Errorf("open key %q, want %q", gotID, tt.wantID)
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("open error %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("open = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSealMissingKey(t *testing.T) {
+	s := S3Repository{}
+	if _, _, err := s.seal("0123456789abcdef", []byte("x")); !errors.Is(err, ErrClientKeyMissing) {
+		t.Errorf("seal without the key = %v, want %v", err, ErrClientKeyMissing)
+	}
+}
+
+func TestSealUsesNewDataKeys(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	id := ClientKeyID(key)
+	s := S3Repository{ClientEncryptionRules: []ClientEncryptionRule{{Key: key}}}
+	a, metaA, err := s.seal(id, []byte("same"))
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	b, metaB, err := s.seal(id, []byte("same"))
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	if bytes.Equal(a, b) || metaA[envelopeWrappedMeta] == metaB[envelopeWrappedMeta] {
+		t.Errorf("sealing twice gave the same ciphertext or data key")
+	}
+}
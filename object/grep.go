@@ -0,0 +1,157 @@
+package object
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// grepMaxLine is the longest line Grep matches, the rest of longer ones is
+// read past without being kept
+const grepMaxLine = 1024 * 1024
+
+// GrepOptions bound a search through the content of objects
+type GrepOptions struct {
+	// Workers is how many objects are read at once
+	Workers int
+	// MaxSize skips the objects larger than this many bytes, as stored
+	MaxSize int64
+}
+
+// GrepMatch is a line of an object matching the pattern, numbered from 1
+type GrepMatch struct {
+	Key  string
+	Line int
+	Text string
+}
+
+// GrepResult is what searching one object found
+type GrepResult struct {
+	Key     string
+	Matches []GrepMatch
+	// Skipped says why the object was not searched, it is empty when it was
+	Skipped string
+	// Err is set when the object could not be read
+	Err error
+}
+
+// Grep searches the content of objects for lines matching re, reading
+// opts.Workers of them at once. found is called with the result of every
+// object as soon as it is searched, from the workers, so it has to be safe for
+// concurrent use. Gzipped objects are searched decompressed. Cancelling ctx
+// stops the search before the objects not read yet.
+func (s S3Repository) Grep(ctx context.Context, bucket string, objects []Object, re *regexp.Regexp, opts GrepOptions, found func(GrepResult)) {
+	jobs := make(chan Object)
+	var wg sync.WaitGroup
+	for w := 0; w < min(max(opts.Workers, 1), len(objects)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for obj := range jobs {
+				found(s.grepObject(bucket, obj, re, opts))
+			}
+		}()
+	}
+
+feed:
+	for _, obj := range objects {
+		select {
+		case jobs <- obj:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+func (s S3Repository) grepObject(bucket string, obj Object, re *regexp.Regexp, opts GrepOptions) GrepResult {
+	result := GrepResult{Key: obj.Key}
+	switch {
+	case strings.HasSuffix(obj.Key, "/"):
+		result.Skipped = "directory marker"
+		return result
+	case IsArchived(types.StorageClass(obj.StorageClass)):
+		result.Skipped = "in " + string(obj.StorageClass)
+		return result
+	case opts.MaxSize > 0 && obj.Size > opts.MaxSize:
+		result.Skipped = fmt.Sprintf("larger than %d bytes", opts.MaxSize)
+		return result
+	}
+	got, err := s.GetObject(bucket, obj.Key)
+	if errors.Is(err, ErrArchived) {
+		result.Skipped = "archived"
+		return result
+	}
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	r, err := Decompress(strings.NewReader(got.Content))
+	if err != nil {
+		result.Err = fmt.Errorf("could not decompress %s: %w", obj.Key, err)
+		return result
+	}
+	br := bufio.NewReaderSize(r, 64*1024)
+	if head, _ := br.Peek(512); bytes.IndexByte(head, 0) >= 0 {
+		result.Skipped = "binary"
+		return result
+	}
+	result.Matches, result.Err = grepLines(obj.Key, br, re)
+	return result
+}
+
+// grepLines returns the lines of r matching re
+func grepLines(key string, r io.Reader, re *regexp.Regexp) ([]GrepMatch, error) {
+	var matches []GrepMatch
+	reader := bufio.NewReaderSize(r, 64*1024)
+	var text []byte
+	for line := 1; ; line++ {
+		// ReadLine returns a line longer than the buffer in fragments
+		text = text[:0]
+		fragment, more, err := reader.ReadLine()
+		for err == nil {
+			if room := grepMaxLine - len(text); room > 0 {
+				text = append(text, fragment[:min(len(fragment), room)]...)
+			}
+			if !more {
+				break
+			}
+			fragment, more, err = reader.ReadLine()
+		}
+		if errors.Is(err, io.EOF) {
+			return matches, nil
+		}
+		if err != nil {
+			return matches, fmt.Errorf("could not read %s: %w", key, err)
+		}
+		if re.Match(text) {
+			matches = append(matches, GrepMatch{Key: key, Line: line, Text: string(text)})
+		}
+	}
+}
+
+// IsGzip reports whether content starts with the gzip magic number
+func IsGzip(content []byte) bool {
+	return len(content) >= 2 && content[0] == 0x1f && content[1] == 0x8b
+}
+
+// Decompress returns a reader of the decompressed content of r when it is
+// gzipped, and of r as it is otherwise
+func Decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(2)
+	if !IsGzip(head) {
+		return br, nil
+	}
+	return gzip.NewReader(br)
+}
@@ -0,0 +1,42 @@
+package object
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestGrepLines(t *testing.T) {
+	long := "needle " + strings.Repeat("x", 2*grepMaxLine) + " tail"
+	tests := []struct {
+		name    string
+		content string
+		pattern string
+		want    []int
+	}{
+		{name: "no match", content: "a\nb\n", pattern: "c", want: nil},
+		{name: "numbered from 1", content: "a\nneedle\nb\nneedle", pattern: "needle", want: []int{2, 4}},
+		{name: "crlf", content: "a\r\nneedle\r\n", pattern: "needle$", want: []int{2}},
+		{name: "long line is cut", content: long + "\nneedle\n", pattern: "needle", want: []int{1, 2}},
+		{name: "past the cut", content: long + "\nshort\n", pattern: "tail", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches, err := grepLines("key", strings.NewReader(tt.content), regexp.MustCompile(tt.pattern))
+			if err != nil {
+				t.Fatalf("grepLines: %v", err)
+			}
+			var lines []int
+			for _, m := range matches {
+				if len(m.Text) > grepMaxLine {
+					t.Errorf("line %d is %d bytes, longer than %d", m.Line, len(m.Text), grepMaxLine)
+				}
+				lines = append(lines, m.Line)
+			}
+			if !slices.Equal(lines, tt.want) {
+				t.Errorf("matched lines %v, want %v", lines, tt.want)
+			}
+		})
+	}
+}
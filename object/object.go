@@ -1,12 +1,21 @@
 package object
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/Wondrous27/s3-tui/audit"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
@@ -15,17 +24,35 @@ const (
 	DDMMYYYYhhmmss = "2006-01-02 15:04:05"
 )
 
+// ErrReadOnly is returned by every mutating method in read-only mode
+var ErrReadOnly = errors.New("refused in read-only mode")
+
 type S3Repository struct {
 	Client *s3.Client
+	// ReadOnly refuses every call that would modify an object
+	ReadOnly bool
+	// Audit records every call that modifies an object
+	Audit *audit.Log
+	// EncryptionRules choose and enforce the encryption of new objects
+	EncryptionRules []EncryptionRule
+	// ClientEncryptionRules encrypt the content of some prefixes before it is
+	// sent to S3
+	ClientEncryptionRules []ClientEncryptionRule
+	// KMS lists the keys offered for SSE-KMS, nil when it is not available
+	KMS *kms.Client
 }
 
 type Object struct {
-	Key          string
-	LastModified time.Time
-	Size         int64
-	ETag         string
-	StorageClass types.ObjectStorageClass
-	Content      string
+	Key          string                   `json:"key"`
+	LastModified time.Time                `json:"last_modified"`
+	Size         int64                    `json:"size"`
+	ETag         string                   `json:"etag"`
+	StorageClass types.ObjectStorageClass `json:"storage_class,omitempty"`
+	// Restore is the state of a restore from Glacier or Deep Archive
+	Restore *RestoreStatus `json:"restore,omitempty"`
+	// Encryption is how the object was encrypted, known once it was read
+	Encryption *Encryption `json:"encryption,omitempty"`
+	Content    string      `json:"content,omitempty"`
 }
 
 func (o Object) FilterValue() string { return o.Key }
@@ -37,47 +64,326 @@ func (o Object) Description() string {
 func (o Object) Title() string { return o.Key }
 
 func (s S3Repository) ListObjects(bucketName string) ([]string, error) {
-	out, err := s.Client.ListObjectsV2(context.TODO(), &s3.ListObjectsV2Input{Bucket: &bucketName})
+	objects, err := s.ListPrefix(bucketName, "")
 	if err != nil {
-		return nil, fmt.Errorf("could not get objects: %v", err)
+		return nil, err
 	}
 
 	var keys []string
-	for _, obj := range out.Contents {
-		keys = append(keys, *obj.Key)
+	for _, obj := range objects {
+		keys = append(keys, obj.Key)
 	}
 
 	return keys, nil
 }
 
+// ListPrefix returns every object in bucketName whose key starts with prefix,
+// following continuation tokens past the first 1000 keys.
+func (s S3Repository) ListPrefix(bucketName, prefix string) ([]Object, error) {
+	return s.listPrefix(bucketName, prefix, nil)
+}
+
+// listPrefix is ListPrefix calling progress with the number of keys of every
+// page, when it is not nil
+func (s S3Repository) listPrefix(bucketName, prefix string, progress func(int)) ([]Object, error) {
+	paginator := s3.NewListObjectsV2Paginator(s.Client, &s3.ListObjectsV2Input{
+		Bucket: &bucketName,
+		Prefix: &prefix,
+	})
+
+	var objects []Object
+	for paginator.HasMorePages() {
+		out, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			return nil, fmt.Errorf("could not get objects: %w", err)
+		}
+		for _, obj := range out.Contents {
+			objects = append(objects, Object{
+				Key:          aws.ToString(obj.Key),
+				LastModified: aws.ToTime(obj.LastModified),
+				Size:         aws.ToInt64(obj.Size),
+				ETag:         aws.ToString(obj.ETag),
+				StorageClass: obj.StorageClass,
+			})
+		}
+		if progress != nil {
+			progress(len(out.Contents))
+		}
+	}
+	return objects, nil
+}
+
+// GetObject reads an object, with the customer key of the encryption rules
+// when it uses SSE-C
 func (s S3Repository) GetObject(bucket, key string) (*Object, error) {
-	result, err := s.Client.GetObject(context.TODO(), &s3.GetObjectInput{Bucket: &bucket, Key: &key})
-	log.Println("last modified: ", *result.LastModified)
+	return s.GetEncryptedObject(bucket, key, s.DefaultEncryption(bucket, key))
+}
+
+// GetEncryptedObject reads an object, supplying the customer key of enc when
+// it is SSE-C. Other modes need nothing to read.
+func (s S3Repository) GetEncryptedObject(bucket, key string, enc Encryption) (*Object, error) {
+	result, err := s.getObject(bucket, key, enc)
 	if err != nil {
-		return nil, fmt.Errorf("could not get object: %v", err)
+		return nil, err
 	}
+	log.Println("last modified: ", *result.LastModified)
 	defer result.Body.Close()
+	encryption := encryptionOf(result.ServerSideEncryption, result.SSEKMSKeyId, result.SSECustomerAlgorithm, enc.CustomerKey)
 	body, err := io.ReadAll(result.Body)
 	if err != nil {
 		return nil, fmt.Errorf("could not read object: %v", err)
 	}
+	body, encryption.ClientKey, err = s.open(result.Metadata, body)
+	if err != nil {
+		return nil, fmt.Errorf("could not decrypt object %s: %w", key, err)
+	}
 	return &Object{
 		Key:          key,
 		LastModified: *result.LastModified,
 		Size:         *result.ContentLength,
 		ETag:         *result.ETag,
+		StorageClass: types.ObjectStorageClass(result.StorageClass),
+		Restore:      ParseRestore(aws.ToString(result.Restore)),
+		Encryption:   &encryption,
 		Content:      string(body),
 	}, nil
 }
 
+// DownloadFile writes the content of an object to path, creating its
+// directory. Objects encrypted client-side are decrypted in memory, since
+// AES-GCM authenticates the whole content first, and only their owner can
+// read the file. The others are streamed.
+func (s S3Repository) DownloadFile(bucket, key, path string) error {
+	result, err := s.getObject(bucket, key, s.DefaultEncryption(bucket, key))
+	if err != nil {
+		return err
+	}
+	defer result.Body.Close()
+	var body io.Reader = result.Body
+	private := metadataValue(result.Metadata, envelopeMeta) != "" || s.clientEncryptionRule(bucket, key) != nil
+	if private {
+		sealed, err := io.ReadAll(result.Body)
+		if err != nil {
+			return fmt.Errorf("could not read object %s: %w", key, err)
+		}
+		plaintext, _, err := s.open(result.Metadata, sealed)
+		if err != nil {
+			return fmt.Errorf("could not decrypt object %s: %w", key, err)
+		}
+		body = bytes.NewReader(plaintext)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("could not create %s: %w", filepath.Dir(path), err)
+	}
+	perm := os.FileMode(0o644)
+	if private {
+		perm = 0o600
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return fmt.Errorf("could not write %s: %w", path, err)
+	}
+	// OpenFile keeps the mode of a file that exists already
+	if private {
+		err = file.Chmod(perm)
+	}
+	if err == nil {
+		_, err = io.Copy(file, body)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return fmt.Errorf("could not write %s: %w", path, err)
+	}
+	return nil
+}
+
+// getObject starts reading an object, the caller closes its body
+func (s S3Repository) getObject(bucket, key string, enc Encryption) (*s3.GetObjectOutput, error) {
+	input := &s3.GetObjectInput{Bucket: &bucket, Key: &key}
+	input.SSECustomerAlgorithm, input.SSECustomerKey, input.SSECustomerKeyMD5 = enc.customerKey()
+	result, err := s.Client.GetObject(context.TODO(), input)
+	if isCustomerKeyError(err) {
+		return nil, fmt.Errorf("could not get object %s: %w", key, ErrCustomerKeyRequired)
+	}
+	var archived *types.InvalidObjectState
+	if errors.As(err, &archived) {
+		return nil, fmt.Errorf("could not get object %s from %s: %w", key, archived.StorageClass, ErrArchived)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get object: %w", err)
+	}
+	return result, nil
+}
+
+// PutObject writes an object with the encryption the rules ask for at key
 func (s S3Repository) PutObject(r io.Reader, bucket string, key string) error {
-	_, err := s.Client.PutObject(context.TODO(), &s3.PutObjectInput{
+	return s.PutEncryptedObject(r, bucket, key, s.DefaultEncryption(bucket, key))
+}
+
+// PutEncryptedObject writes an object encrypted with enc, which has to
+// satisfy the encryption rules
+func (s S3Repository) PutEncryptedObject(r io.Reader, bucket, key string, enc Encryption) error {
+	return s.putObject(r, bucket, key, enc, nil)
+}
+
+// putObject writes an object encrypted with enc. Content read with
+// GetStoredObject comes with its headers and is written as it was stored,
+// without sealing it again.
+func (s S3Repository) putObject(r io.Reader, bucket, key string, enc Encryption, headers *Headers) error {
+	entry := audit.Entry{Operation: "put", Bucket: bucket, Key: key, Size: readerSize(r), Encryption: enc.String()}
+	if err := s.refuse(entry); err != nil {
+		return err
+	}
+	if err := s.CheckEncryption(bucket, key, enc); err != nil {
+		s.Audit.Refused(entry, err)
+		return err
+	}
+	input := &s3.PutObjectInput{
 		Bucket: &bucket,
 		Key:    &key,
 		Body:   r,
-	})
+	}
+	if headers != nil {
+		headers.apply(input)
+	} else if enc.ClientKey != "" {
+		plaintext, err := io.ReadAll(r)
+		if err != nil {
+			err = fmt.Errorf("could not read the content of %s: %w", key, err)
+			s.Audit.Record(entry, err)
+			return err
+		}
+		ciphertext, metadata, err := s.seal(enc.ClientKey, plaintext)
+		if err != nil {
+			s.Audit.Record(entry, err)
+			return err
+		}
+		input.Body, input.Metadata = bytes.NewReader(ciphertext), metadata
+	}
+	input.ServerSideEncryption, input.SSEKMSKeyId = enc.serverSide()
+	input.SSECustomerAlgorithm, input.SSECustomerKey, input.SSECustomerKeyMD5 = enc.customerKey()
+	out, err := s.Client.PutObject(context.TODO(), input)
+	if err == nil {
+		entry.VersionID = aws.ToString(out.VersionId)
+	}
+	s.Audit.Record(entry, err)
 	if err != nil {
 		return fmt.Errorf("could not put object %v", err)
 	}
 	return nil
 }
+
+// CopyObject copies an object, encrypting the copy the way the rules ask for
+// at dstKey. A copy into a prefix encrypted client-side with another key than
+// the source's is read and written again, since S3 only sees ciphertext.
+func (s S3Repository) CopyObject(srcBucket, srcKey, dstBucket, dstKey string) error {
+	source := copySource(srcBucket, srcKey)
+	enc := s.DefaultEncryption(dstBucket, dstKey)
+	if enc.ClientKey != "" {
+		head, err := s.HeadObject(srcBucket, srcKey)
+		if err != nil {
+			return err
+		}
+		if head.Encryption.ClientKey != enc.ClientKey {
+			obj, err := s.GetObject(srcBucket, srcKey)
+			if err != nil {
+				return err
+			}
+			return s.PutEncryptedObject(strings.NewReader(obj.Content), dstBucket, dstKey, enc)
+		}
+	}
+	entry := audit.Entry{Operation: "copy", Bucket: dstBucket, Key: dstKey, Source: source, Encryption: enc.String()}
+	if err := s.refuse(entry); err != nil {
+		return err
+	}
+	input := &s3.CopyObjectInput{
+		Bucket:     &dstBucket,
+		Key:        &dstKey,
+		CopySource: &source,
+	}
+	input.ServerSideEncryption, input.SSEKMSKeyId = enc.serverSide()
+	input.SSECustomerAlgorithm, input.SSECustomerKey, input.SSECustomerKeyMD5 = enc.customerKey()
+	src := s.DefaultEncryption(srcBucket, srcKey)
+	input.CopySourceSSECustomerAlgorithm, input.CopySourceSSECustomerKey, input.CopySourceSSECustomerKeyMD5 = src.customerKey()
+	out, err := s.Client.CopyObject(context.TODO(), input)
+	if err == nil {
+		entry.VersionID = aws.ToString(out.VersionId)
+	}
+	s.Audit.Record(entry, err)
+	if err != nil {
+		log.Printf("Failed to copy s3://%s/%s to s3://%s/%s %v", srcBucket, srcKey, dstBucket, dstKey, err)
+		return fmt.Errorf("could not copy object %v", err)
+	}
+	return nil
+}
+
+func (s S3Repository) DeleteObject(bucket, key string) error {
+	entry := audit.Entry{Operation: "delete", Bucket: bucket, Key: key}
+	if err := s.refuse(entry); err != nil {
+		return err
+	}
+	// S3 does not say how large a deleted object was, so the audit log looks
+	// it up first
+	if s.Audit != nil {
+		if head, err := s.HeadObject(bucket, key); err == nil {
+			entry.Size = head.Size
+		}
+	}
+	out, err := s.Client.DeleteObject(context.TODO(), &s3.DeleteObjectInput{Bucket: &bucket, Key: &key})
+	if err == nil {
+		entry.VersionID = aws.ToString(out.VersionId)
+	}
+	s.Audit.Record(entry, err)
+	if err != nil {
+		log.Printf("Failed to delete object s3://%s/%s %v", bucket, key, err)
+		return fmt.Errorf("could not delete object %v", err)
+	}
+	return nil
+}
+
+// refuse fails a mutating operation in read-only mode and audits the refusal
+func (s S3Repository) refuse(entry audit.Entry) error {
+	if !s.ReadOnly {
+		return nil
+	}
+	err := fmt.Errorf("could not %s s3://%s/%s: %w", entry.Operation, entry.Bucket, entry.Key, ErrReadOnly)
+	s.Audit.Refused(entry, err)
+	return err
+}
+
+// readerSize returns how many bytes are left in r, or 0 if it can't tell
+func readerSize(r io.Reader) int64 {
+	seeker, ok := r.(io.Seeker)
+	if !ok {
+		return 0
+	}
+	curr, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0
+	}
+	end, err := seeker.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0
+	}
+	if _, err := seeker.Seek(curr, io.SeekStart); err != nil {
+		return 0
+	}
+	return end - curr
+}
+
+// copySource URL-encodes bucket/key for CopySource while keeping the slashes
+func copySource(bucket, key string) string {
+	return bucket + "/" + escapeKey(key)
+}
+
+// escapeKey URL-encodes every segment of key, keeping the slashes
+func escapeKey(key string) string {
+	parts := strings.Split(key, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return strings.Join(parts, "/")
+}
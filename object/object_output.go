@@ -1,14 +1,51 @@
 package object
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
 
 func FormatObject(object Object) string {
 	return fmt.Sprintf(
-		"Key: %s\nLast Modified: %s\nSize: %d KB\n %s\nContent:\n\n%s",
+		"Key: %s\nLast Modified: %s\nSize: %d KB\nStorage Class: %s\nEncryption: %s\n %s\nContent:\n\n%s",
 		object.Key,
 		object.LastModified.Format("2006-01-02"),
 		object.Size,
+		StorageClass(object),
+		encryptionOfObject(object),
 		"---",
 		object.Content,
 	)
 }
+
+// StorageClass names the class of an object and the state of its restore,
+// S3 leaves the class out for STANDARD objects
+func StorageClass(object Object) string {
+	class := string(object.StorageClass)
+	if class == "" {
+		class = string(types.StorageClassStandard)
+	}
+	if object.Restore != nil {
+		class += ", " + object.Restore.String()
+	}
+	return class
+}
+
+// FormatListing formats an object as one line of a listing, like `aws s3 ls`
+func FormatListing(object Object) string {
+	return fmt.Sprintf("%s %10d %s", object.LastModified.Format(DDMMYYYYhhmmss), object.Size, object.Key)
+}
+
+func encryptionOfObject(object Object) string {
+	if object.Encryption == nil {
+		return "unknown"
+	}
+	switch {
+	case object.Encryption.Mode == "" && object.Encryption.ClientKey == "":
+		return "none"
+	case object.Encryption.Mode == "":
+		return "client-side AES-GCM key " + object.Encryption.ClientKey
+	}
+	return object.Encryption.String()
+}
@@ -0,0 +1,151 @@
+package object
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Wondrous27/s3-tui/audit"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// MaxPresignExpiry is the longest SigV4 allows a presigned request to live
+const MaxPresignExpiry = 7 * 24 * time.Hour
+
+// ResponseOverrides replace headers of the response to a presigned GET
+type ResponseOverrides struct {
+	ContentDisposition string
+	ContentType        string
+}
+
+// PresignedPost is a browser upload form: POST the fields and a `file` field
+// as multipart/form-data to URL.
+type PresignedPost struct {
+	URL    string            `json:"url"`
+	Fields map[string]string `json:"fields"`
+}
+
+func (s S3Repository) PresignGet(bucket, key string, expires time.Duration, overrides ResponseOverrides) (string, error) {
+	input := &s3.GetObjectInput{Bucket: &bucket, Key: &key}
+	if overrides.ContentDisposition != "" {
+		input.ResponseContentDisposition = &overrides.ContentDisposition
+	}
+	if overrides.ContentType != "" {
+		input.ResponseContentType = &overrides.ContentType
+	}
+	req, err := s3.NewPresignClient(s.Client).PresignGetObject(context.TODO(), input, s3.WithPresignExpires(expires))
+	if err != nil {
+		return "", fmt.Errorf("could not presign GET: %w", err)
+	}
+	return req.URL, nil
+}
+
+// PresignPut returns a URL anyone can upload key with until it expires, so it
+// counts as a mutation for read-only mode and the audit log.
+func (s S3Repository) PresignPut(bucket, key string, expires time.Duration) (string, error) {
+	entry := audit.Entry{Operation: "presign-put", Bucket: bucket, Key: key}
+	if err := s.refuse(entry); err != nil {
+		return "", err
+	}
+	req, err := s3.NewPresignClient(s.Client).PresignPutObject(context.TODO(),
+		&s3.PutObjectInput{Bucket: &bucket, Key: &key}, s3.WithPresignExpires(expires))
+	s.Audit.Record(entry, err)
+	if err != nil {
+		return "", fmt.Errorf("could not presign PUT: %w", err)
+	}
+	return req.URL, nil
+}
+
+// PresignPost signs a POST policy that allows uploading exactly key
+func (s S3Repository) PresignPost(bucket, key string, expires time.Duration) (*PresignedPost, error) {
+	entry := audit.Entry{Operation: "presign-post", Bucket: bucket, Key: key}
+	if err := s.refuse(entry); err != nil {
+		return nil, err
+	}
+	post, err := s.presignPost(bucket, key, expires)
+	s.Audit.Record(entry, err)
+	if err != nil {
+		return nil, fmt.Errorf("could not presign POST: %w", err)
+	}
+	return post, nil
+}
+
+func (s S3Repository) presignPost(bucket, key string, expires time.Duration) (*PresignedPost, error) {
+	opts := s.Client.Options()
+	creds, err := opts.Credentials.Retrieve(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+	fields, err := signPostPolicy(bucket, key, creds, opts.Region, time.Now().UTC(), expires)
+	if err != nil {
+		return nil, err
+	}
+	return &PresignedPost{URL: s.BucketURL(bucket), Fields: fields}, nil
+}
+
+// signPostPolicy returns the form fields of a POST policy for key, signed
+// with SigV4 at now
+func signPostPolicy(bucket, key string, creds aws.Credentials, region string, now time.Time, expires time.Duration) (map[string]string, error) {
+	date := now.Format("20060102")
+	credential := fmt.Sprintf("%s/%s/%s/s3/aws4_request", creds.AccessKeyID, date, region)
+	fields := map[string]string{
+		"key":              key,
+		"x-amz-algorithm":  "AWS4-HMAC-SHA256",
+		"x-amz-credential": credential,
+		"x-amz-date":       now.Format("20060102T150405Z"),
+	}
+	if creds.SessionToken != "" {
+		fields["x-amz-security-token"] = creds.SessionToken
+	}
+
+	conditions := []any{map[string]string{"bucket": bucket}}
+	for name, value := range fields {
+		conditions = append(conditions, map[string]string{name: value})
+	}
+	policy, err := json.Marshal(map[string]any{
+		"expiration": now.Add(expires).Format("2006-01-02T15:04:05.000Z"),
+		"conditions": conditions,
+	})
+	if err != nil {
+		return nil, err
+	}
+	fields["policy"] = base64.StdEncoding.EncodeToString(policy)
+	fields["x-amz-signature"] = hex.EncodeToString(hmacSHA256(signingKey(creds.SecretAccessKey, date, region, "s3"), fields["policy"]))
+	return fields, nil
+}
+
+// signingKey derives the SigV4 key of a secret for a day, region and service
+func signingKey(secret, date, region, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+// BucketURL returns the HTTPS URL of a bucket, virtual-hosted on AWS and path
+// style on a custom endpoint.
+func (s S3Repository) BucketURL(bucket string) string {
+	opts := s.Client.Options()
+	if opts.BaseEndpoint != nil {
+		return strings.TrimSuffix(*opts.BaseEndpoint, "/") + "/" + bucket + "/"
+	}
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/", bucket, opts.Region)
+}
+
+// ObjectURL returns the HTTPS URL of an object, see BucketURL
+func (s S3Repository) ObjectURL(bucket, key string) string {
+	return s.BucketURL(bucket) + escapeKey(key)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
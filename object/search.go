@@ -0,0 +1,89 @@
+package object
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// searchWorkers bounds how many buckets SearchKeys lists at once
+const searchWorkers = 8
+
+// KeySearchResult is a page of the keys of a bucket matching a search
+type KeySearchResult struct {
+	Bucket string
+	Region string
+	// Objects are the matches of the page, there may be none
+	Objects []Object
+	// Done is set on the last result of a bucket, after its last page or
+	// with the error that stopped its listing
+	Done bool
+	Err  error
+}
+
+// SearchKeys lists every bucket in the region bucketRegion returns for it,
+// several at once, and calls found with the keys of every page that match.
+// found is called from the workers, so it has to be safe for concurrent use.
+// Cancelling ctx stops the listings at the next page.
+func (s S3Repository) SearchKeys(ctx context.Context, buckets []string, bucketRegion func(string) (string, error), match func(key string) bool, found func(KeySearchResult)) {
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for w := 0; w < min(searchWorkers, len(buckets)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for bucket := range jobs {
+				err := s.searchBucket(ctx, bucket, bucketRegion, match, found)
+				if err != nil {
+					found(KeySearchResult{Bucket: bucket, Done: true, Err: err})
+				}
+			}
+		}()
+	}
+
+feed:
+	for _, bucket := range buckets {
+		select {
+		case jobs <- bucket:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+func (s S3Repository) searchBucket(ctx context.Context, bucket string, bucketRegion func(string) (string, error), match func(key string) bool, found func(KeySearchResult)) error {
+	region, err := bucketRegion(bucket)
+	if err != nil {
+		return err
+	}
+	paginator := s3.NewListObjectsV2Paginator(s.Client, &s3.ListObjectsV2Input{Bucket: &bucket})
+	inRegion := func(o *s3.Options) { o.Region = region }
+	for paginator.HasMorePages() {
+		out, err := paginator.NextPage(ctx, inRegion)
+		if err != nil {
+			return fmt.Errorf("could not list %s in %s: %w", bucket, region, err)
+		}
+		result := KeySearchResult{Bucket: bucket, Region: region}
+		for _, obj := range out.Contents {
+			if match(aws.ToString(obj.Key)) {
+				result.Objects = append(result.Objects, Object{
+					Key:          aws.ToString(obj.Key),
+					LastModified: aws.ToTime(obj.LastModified),
+					Size:         aws.ToInt64(obj.Size),
+					ETag:         aws.ToString(obj.ETag),
+					StorageClass: obj.StorageClass,
+				})
+			}
+		}
+		if len(result.Objects) > 0 {
+			found(result)
+		}
+	}
+	found(KeySearchResult{Bucket: bucket, Region: region, Done: true})
+	return nil
+}
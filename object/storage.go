@@ -0,0 +1,158 @@
+package object
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/Wondrous27/s3-tui/audit"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// ErrArchived is returned when reading an object that has to be restored
+// from Glacier or Deep Archive first
+var ErrArchived = errors.New("the object is archived and has to be restored before it can be read")
+
+// StorageClasses are the classes an object can be copied into
+var StorageClasses = []types.StorageClass{
+	types.StorageClassStandard,
+	types.StorageClassIntelligentTiering,
+	types.StorageClassStandardIa,
+	types.StorageClassOnezoneIa,
+	types.StorageClassGlacierIr,
+	types.StorageClassGlacier,
+	types.StorageClassDeepArchive,
+}
+
+// RestoreTiers are the retrieval tiers of a restore, fastest first
+var RestoreTiers = []types.Tier{types.TierExpedited, types.TierStandard, types.TierBulk}
+
+// RestoreStatus is the state of a restore from the x-amz-restore header
+type RestoreStatus struct {
+	Ongoing bool `json:"ongoing"`
+	// Expiry is when the restored copy is removed again, once it is done
+	Expiry time.Time `json:"expiry,omitempty"`
+}
+
+var (
+	restoreOngoing = regexp.MustCompile(`ongoing-request="(true|false)"`)
+	restoreExpiry  = regexp.MustCompile(`expiry-date="([^"]+)"`)
+)
+
+// ParseRestore parses an x-amz-restore header, nil when there is none
+func ParseRestore(header string) *RestoreStatus {
+	ongoing := restoreOngoing.FindStringSubmatch(header)
+	if ongoing == nil {
+		return nil
+	}
+	status := &RestoreStatus{Ongoing: ongoing[1] == "true"}
+	if expiry := restoreExpiry.FindStringSubmatch(header); expiry != nil {
+		status.Expiry, _ = time.Parse(http.TimeFormat, expiry[1])
+	}
+	return status
+}
+
+func (r *RestoreStatus) String() string {
+	switch {
+	case r == nil:
+		return "not restored"
+	case r.Ongoing:
+		return "restore in progress"
+	default:
+		return fmt.Sprintf("restored until %s", r.Expiry.Local().Format(DDMMYYYYhhmmss))
+	}
+}
+
+// IsArchived reports whether objects of class have to be restored before
+// they can be read
+func IsArchived(class types.StorageClass) bool {
+	return class == types.StorageClassGlacier || class == types.StorageClassDeepArchive
+}
+
+// HeadObject returns the metadata of an object without its content
+func (s S3Repository) HeadObject(bucket, key string) (*Object, error) {
+	enc := s.DefaultEncryption(bucket, key)
+	input := &s3.HeadObjectInput{Bucket: &bucket, Key: &key}
+	input.SSECustomerAlgorithm, input.SSECustomerKey, input.SSECustomerKeyMD5 = enc.customerKey()
+	out, err := s.Client.HeadObject(context.TODO(), input)
+	if err != nil {
+		return nil, fmt.Errorf("could not head object: %w", err)
+	}
+	encryption := encryptionOf(out.ServerSideEncryption, out.SSEKMSKeyId, out.SSECustomerAlgorithm, enc.CustomerKey)
+	if metadataValue(out.Metadata, envelopeMeta) != "" {
+		encryption.ClientKey = metadataValue(out.Metadata, envelopeKeyMeta)
+	}
+	return &Object{
+		Key:          key,
+		LastModified: aws.ToTime(out.LastModified),
+		Size:         aws.ToInt64(out.ContentLength),
+		ETag:         aws.ToString(out.ETag),
+		StorageClass: types.ObjectStorageClass(out.StorageClass),
+		Restore:      ParseRestore(aws.ToString(out.Restore)),
+		Encryption:   &encryption,
+	}, nil
+}
+
+// ChangeStorageClass copies an object onto itself in another storage class,
+// keeping its metadata, tags and encryption
+func (s S3Repository) ChangeStorageClass(bucket, key string, class types.StorageClass) error {
+	source := copySource(bucket, key)
+	entry := audit.Entry{Operation: "change-storage-class", Bucket: bucket, Key: key, Source: source}
+	if err := s.refuse(entry); err != nil {
+		return err
+	}
+	head, err := s.HeadObject(bucket, key)
+	if err != nil {
+		return err
+	}
+	enc := *head.Encryption
+	input := &s3.CopyObjectInput{
+		Bucket:            &bucket,
+		Key:               &key,
+		CopySource:        &source,
+		StorageClass:      class,
+		MetadataDirective: types.MetadataDirectiveCopy,
+	}
+	input.ServerSideEncryption, input.SSEKMSKeyId = enc.serverSide()
+	input.SSECustomerAlgorithm, input.SSECustomerKey, input.SSECustomerKeyMD5 = enc.customerKey()
+	input.CopySourceSSECustomerAlgorithm, input.CopySourceSSECustomerKey, input.CopySourceSSECustomerKeyMD5 = enc.customerKey()
+	out, err := s.Client.CopyObject(context.TODO(), input)
+	if err == nil {
+		entry.VersionID = aws.ToString(out.VersionId)
+	}
+	s.Audit.Record(entry, err)
+	if err != nil {
+		return fmt.Errorf("could not change the storage class of %s to %s: %w", key, class, err)
+	}
+	return nil
+}
+
+// RestoreObject starts restoring a temporary copy of an archived object for
+// days, retrieved at tier
+func (s S3Repository) RestoreObject(bucket, key string, tier types.Tier, days int32) error {
+	if days <= 0 {
+		return fmt.Errorf("a restore needs a positive number of days")
+	}
+	entry := audit.Entry{Operation: "restore-archive", Bucket: bucket, Key: key}
+	if err := s.refuse(entry); err != nil {
+		return err
+	}
+	_, err := s.Client.RestoreObject(context.TODO(), &s3.RestoreObjectInput{
+		Bucket: &bucket,
+		Key:    &key,
+		RestoreRequest: &types.RestoreRequest{
+			Days:                 &days,
+			GlacierJobParameters: &types.GlacierJobParameters{Tier: tier},
+		},
+	})
+	s.Audit.Record(entry, err)
+	if err != nil {
+		return fmt.Errorf("could not restore %s: %w", key, err)
+	}
+	return nil
+}
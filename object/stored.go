@@ -0,0 +1,80 @@
+package object
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// Headers are what an object keeps besides its content and encryption, so
+// it can be written back the way it was
+type Headers struct {
+	ContentType        string             `json:"content_type,omitempty"`
+	ContentEncoding    string             `json:"content_encoding,omitempty"`
+	ContentDisposition string             `json:"content_disposition,omitempty"`
+	ContentLanguage    string             `json:"content_language,omitempty"`
+	CacheControl       string             `json:"cache_control,omitempty"`
+	StorageClass       types.StorageClass `json:"storage_class,omitempty"`
+	// Metadata is the user metadata, which holds the envelope of objects
+	// encrypted client-side
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+func (h Headers) apply(input *s3.PutObjectInput) {
+	input.ContentType = optionalString(h.ContentType)
+	input.ContentEncoding = optionalString(h.ContentEncoding)
+	input.ContentDisposition = optionalString(h.ContentDisposition)
+	input.ContentLanguage = optionalString(h.ContentLanguage)
+	input.CacheControl = optionalString(h.CacheControl)
+	input.StorageClass = h.StorageClass
+	input.Metadata = h.Metadata
+}
+
+// GetStoredObject copies the content of an object to w as S3 stores it, still
+// sealed when it is encrypted client-side, and returns the object with the
+// headers that PutStoredObject needs to write it back.
+func (s S3Repository) GetStoredObject(bucket, key string, w io.Writer) (*Object, Headers, error) {
+	enc := s.DefaultEncryption(bucket, key)
+	result, err := s.getObject(bucket, key, enc)
+	if err != nil {
+		return nil, Headers{}, err
+	}
+	defer result.Body.Close()
+	size, err := io.Copy(w, result.Body)
+	if err != nil {
+		return nil, Headers{}, fmt.Errorf("could not read object %s: %w", key, err)
+	}
+	encryption := encryptionOf(result.ServerSideEncryption, result.SSEKMSKeyId, result.SSECustomerAlgorithm, enc.CustomerKey)
+	if metadataValue(result.Metadata, envelopeMeta) != "" {
+		encryption.ClientKey = metadataValue(result.Metadata, envelopeKeyMeta)
+	}
+	obj := &Object{
+		Key:          key,
+		LastModified: aws.ToTime(result.LastModified),
+		Size:         size,
+		ETag:         aws.ToString(result.ETag),
+		StorageClass: types.ObjectStorageClass(result.StorageClass),
+		Encryption:   &encryption,
+	}
+	headers := Headers{
+		ContentType:        aws.ToString(result.ContentType),
+		ContentEncoding:    aws.ToString(result.ContentEncoding),
+		ContentDisposition: aws.ToString(result.ContentDisposition),
+		ContentLanguage:    aws.ToString(result.ContentLanguage),
+		CacheControl:       aws.ToString(result.CacheControl),
+		StorageClass:       types.StorageClass(result.StorageClass),
+		Metadata:           result.Metadata,
+	}
+	return obj, headers, nil
+}
+
+// PutStoredObject writes back content read with GetStoredObject, with its
+// headers and encrypted with enc like PutEncryptedObject does. An envelope
+// is kept as it is, enc.ClientKey only has to name the key it was sealed
+// with.
+func (s S3Repository) PutStoredObject(r io.Reader, bucket, key string, enc Encryption, headers Headers) error {
+	return s.putObject(r, bucket, key, enc, &headers)
+}
@@ -0,0 +1,403 @@
+package object
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// syncWorkers is how many transfers ApplySync makes at once by default
+const syncWorkers = 8
+
+// SyncDirection is which side of a sync is copied to the other
+type SyncDirection int
+
+const (
+	// SyncUp copies a local directory to a prefix
+	SyncUp SyncDirection = iota
+	// SyncDown copies a prefix to a local directory
+	SyncDown
+)
+
+func (d SyncDirection) String() string {
+	if d == SyncDown {
+		return "S3 → local"
+	}
+	return "local → S3"
+}
+
+// SyncAction is what a sync does to a file or an object
+type SyncAction string
+
+const (
+	SyncUpload   SyncAction = "upload"
+	SyncDownload SyncAction = "download"
+	SyncDelete   SyncAction = "delete"
+)
+
+// SyncOp is a step of a sync plan. A deletion removes Key when syncing up
+// and Path when syncing down.
+type SyncOp struct {
+	Action SyncAction `json:"action"`
+	Key    string     `json:"key"`
+	Path   string     `json:"path"`
+	Size   int64      `json:"size"`
+	// Reason says why the step is needed
+	Reason string `json:"reason"`
+	// modified is when the source was last modified, given to downloaded
+	// files so the next sync finds them unchanged
+	modified time.Time
+}
+
+// SyncPlan is what syncing a local directory and a prefix would do, to be
+// reviewed before it is applied
+type SyncPlan struct {
+	Direction SyncDirection `json:"-"`
+	Bucket    string        `json:"bucket"`
+	Prefix    string        `json:"prefix"`
+	Dir       string        `json:"dir"`
+	Ops       []SyncOp      `json:"ops"`
+	// Skipped are the objects left out of a download, whose keys lead
+	// outside of Dir
+	Skipped []SyncOp `json:"skipped"`
+	// Unchanged counts the files that are the same on both sides
+	Unchanged int `json:"unchanged"`
+}
+
+// Count returns how many steps of the plan do action, and their size
+func (p SyncPlan) Count(action SyncAction) (int, int64) {
+	var n int
+	var size int64
+	for _, op := range p.Ops {
+		if op.Action == action {
+			n++
+			size += op.Size
+		}
+	}
+	return n, size
+}
+
+// SyncOptions change what a sync plans
+type SyncOptions struct {
+	// Delete removes what exists only at the destination
+	Delete bool
+	// Exclude leaves keys out of the sync, such as the ones of the trash
+	Exclude func(key string) bool
+}
+
+// localFile is a regular file below the synced directory
+type localFile struct {
+	path     string
+	size     int64
+	modified time.Time
+}
+
+// PlanSync compares a local directory and a prefix, and plans the steps that
+// make the destination match the source. Files are copied when their sizes
+// differ, or when their MD5 differs from the ETag and the source was modified
+// last. ETags that are not an MD5, of multipart uploads or some encryptions,
+// leave the decision to the modification times.
+func (s S3Repository) PlanSync(direction SyncDirection, bucket, prefix, dir string, opts SyncOptions) (*SyncPlan, error) {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	plan := &SyncPlan{Direction: direction, Bucket: bucket, Prefix: prefix, Dir: dir}
+
+	files, err := listLocal(dir)
+	if errors.Is(err, fs.ErrNotExist) && direction == SyncDown {
+		// The directory is created by the first download
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	listed, err := s.ListPrefix(bucket, prefix)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.planSteps(plan, files, listed, opts); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// planSteps compares the local files and the objects listed under the prefix
+// of plan, and adds the steps of the sync to it
+func (s S3Repository) planSteps(plan *SyncPlan, files map[string]localFile, listed []Object, opts SyncOptions) error {
+	bucket, prefix, dir := plan.Bucket, plan.Prefix, plan.Dir
+	var err error
+	objects := map[string]Object{}
+	for _, obj := range listed {
+		if strings.HasSuffix(obj.Key, "/") || opts.Exclude != nil && opts.Exclude(obj.Key) {
+			continue
+		}
+		objects[strings.TrimPrefix(obj.Key, prefix)] = obj
+	}
+
+	var deletions []SyncOp
+	if plan.Direction == SyncUp {
+		for _, name := range sortedNames(files) {
+			file := files[name]
+			op := SyncOp{Action: SyncUpload, Key: prefix + name, Path: file.path, Size: file.size}
+			obj, ok := objects[name]
+			if ok {
+				if op.Reason, err = s.syncReason(bucket, obj, file, file.modified.After(obj.LastModified)); err != nil {
+					return err
+				}
+			} else {
+				op.Reason = "new"
+			}
+			if op.Reason == "" {
+				plan.Unchanged++
+				continue
+			}
+			plan.Ops = append(plan.Ops, op)
+		}
+		for _, name := range sortedNames(objects) {
+			if _, ok := files[name]; !ok && opts.Delete {
+				obj := objects[name]
+				deletions = append(deletions, SyncOp{Action: SyncDelete, Key: obj.Key, Size: obj.Size, Reason: "not in " + dir})
+			}
+		}
+	} else {
+		for _, name := range sortedNames(objects) {
+			obj := objects[name]
+			path, ok := LocalPath(dir, name)
+			op := SyncOp{Action: SyncDownload, Key: obj.Key, Path: path, Size: obj.Size, modified: obj.LastModified}
+			if !ok {
+				op.Reason = "the key leads outside of " + dir
+				plan.Skipped = append(plan.Skipped, op)
+				continue
+			}
+			file, ok := files[name]
+			if ok {
+				if op.Reason, err = s.syncReason(bucket, obj, file, obj.LastModified.After(file.modified)); err != nil {
+					return err
+				}
+			} else {
+				op.Reason = "new"
+			}
+			if op.Reason == "" {
+				plan.Unchanged++
+				continue
+			}
+			plan.Ops = append(plan.Ops, op)
+		}
+		for _, name := range sortedNames(files) {
+			if _, ok := objects[name]; !ok && opts.Delete {
+				file := files[name]
+				deletions = append(deletions, SyncOp{Action: SyncDelete, Path: file.path, Size: file.size, Reason: "not in " + URI(bucket, prefix)})
+			}
+		}
+	}
+	plan.Ops = append(plan.Ops, deletions...)
+	return nil
+}
+
+// syncReason says why a file and an object that both exist have to be
+// synced, or returns an empty reason when they are the same. sourceNewer
+// tells whether the source side was modified last.
+func (s S3Repository) syncReason(bucket string, obj Object, file localFile, sourceNewer bool) (string, error) {
+	size := file.size
+	if s.clientEncryptionRule(bucket, obj.Key) != nil {
+		size += envelopeOverhead
+	}
+	if size != obj.Size {
+		return "size differs", nil
+	}
+	if etag := strings.Trim(obj.ETag, `"`); len(etag) == 32 && !strings.Contains(etag, "-") && s.clientEncryptionRule(bucket, obj.Key) == nil {
+		sum, err := fileMD5(file.path)
+		if err != nil {
+			return "", err
+		}
+		if sum == etag {
+			return "", nil
+		}
+	}
+	// S3 keeps modification times to the second
+	if sourceNewer && !file.modified.Truncate(time.Second).Equal(obj.LastModified.Truncate(time.Second)) {
+		return "modified since", nil
+	}
+	return "", nil
+}
+
+// LocalPath is where a slash separated name relative to a prefix is written
+// below dir. ok is false when the name leads outside of dir, such as
+// a/../../.bashrc or an absolute path.
+func LocalPath(dir, name string) (path string, ok bool) {
+	rel := filepath.FromSlash(name)
+	if !filepath.IsLocal(rel) {
+		return "", false
+	}
+	return filepath.Join(dir, rel), true
+}
+
+// listLocal returns the regular files below dir by their slash separated
+// path relative to it
+func listLocal(dir string) (map[string]localFile, error) {
+	files := map[string]localFile{}
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = localFile{path: p, size: info.Size(), modified: info.ModTime()}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not list %s: %w", dir, err)
+	}
+	return files, nil
+}
+
+func fileMD5(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("could not open %s: %w", path, err)
+	}
+	defer file.Close()
+	h := md5.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", fmt.Errorf("could not read %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func sortedNames[T any](m map[string]T) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SyncResult is the outcome of a step of a plan
+type SyncResult struct {
+	Op  SyncOp `json:"op"`
+	Err error  `json:"-"`
+}
+
+// SyncReport sums up an applied plan
+type SyncReport struct {
+	Uploaded   int           `json:"uploaded"`
+	Downloaded int           `json:"downloaded"`
+	Deleted    int           `json:"deleted"`
+	Bytes      int64         `json:"bytes"`
+	Failed     []SyncResult  `json:"-"`
+	Elapsed    time.Duration `json:"elapsed_ns"`
+}
+
+// ApplySync runs the steps of a plan, workers transfers at once, and the
+// deletions once every transfer is done. remove deletes an object, nil
+// deletes it permanently. progress is called from the workers after every
+// step, and may be nil. A step that fails does not stop the others.
+func (s S3Repository) ApplySync(ctx context.Context, plan *SyncPlan, workers int, remove func(bucket, key string) error, progress func(SyncResult)) SyncReport {
+	start := time.Now()
+	if workers < 1 {
+		workers = syncWorkers
+	}
+	if remove == nil {
+		remove = s.DeleteObject
+	}
+	var transfers, deletions []SyncOp
+	for _, op := range plan.Ops {
+		if op.Action == SyncDelete {
+			deletions = append(deletions, op)
+		} else {
+			transfers = append(transfers, op)
+		}
+	}
+
+	var mu sync.Mutex
+	var report SyncReport
+	run := func(ops []SyncOp) {
+		jobs := make(chan SyncOp)
+		var wg sync.WaitGroup
+		for w := 0; w < min(workers, len(ops)); w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for op := range jobs {
+					result := SyncResult{Op: op, Err: s.applySyncOp(plan.Bucket, op, remove)}
+					mu.Lock()
+					switch {
+					case result.Err != nil:
+						report.Failed = append(report.Failed, result)
+					case op.Action == SyncUpload:
+						report.Uploaded++
+						report.Bytes += op.Size
+					case op.Action == SyncDownload:
+						report.Downloaded++
+						report.Bytes += op.Size
+					case op.Action == SyncDelete:
+						report.Deleted++
+					}
+					mu.Unlock()
+					if progress != nil {
+						progress(result)
+					}
+				}
+			}()
+		}
+
+	feed:
+		for _, op := range ops {
+			select {
+			case jobs <- op:
+			case <-ctx.Done():
+				break feed
+			}
+		}
+		close(jobs)
+		wg.Wait()
+	}
+	run(transfers)
+	if ctx.Err() == nil {
+		run(deletions)
+	}
+	report.Elapsed = time.Since(start)
+	return report
+}
+
+func (s S3Repository) applySyncOp(bucket string, op SyncOp, remove func(bucket, key string) error) error {
+	switch {
+	case op.Action == SyncUpload:
+		file, err := os.Open(op.Path)
+		if err != nil {
+			return fmt.Errorf("could not open %s: %w", op.Path, err)
+		}
+		defer file.Close()
+		return s.PutObject(file, bucket, op.Key)
+
+	case op.Action == SyncDownload:
+		if err := s.DownloadFile(bucket, op.Key, op.Path); err != nil {
+			return err
+		}
+		return os.Chtimes(op.Path, op.modified, op.modified)
+
+	case op.Key != "":
+		return remove(bucket, op.Key)
+	}
+	if err := os.Remove(op.Path); err != nil {
+		return fmt.Errorf("could not delete %s: %w", op.Path, err)
+	}
+	return nil
+}
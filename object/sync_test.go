@@ -0,0 +1,225 @@
+package object
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestLocalPath(t *testing.T) {
+	dir := filepath.Join("home", "me", "backup")
+	tests := []struct {
+		name string
+		want string
+		ok   bool
+	}{
+		{name: "a.txt", want: filepath.Join(dir, "a.txt"), ok: true},
+		{name: "t/c/d.md", want: filepath.Join(dir, "t", "c", "d.md"), ok: true},
+		{name: "t/../a.txt", want: filepath.Join(dir, "a.txt"), ok: true},
+		{name: "../a.txt"},
+		{name: "t/../../a.txt"},
+		{name: "/etc/passwd"},
+		{name: ".."},
+		{name: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := LocalPath(dir, tt.name)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("LocalPath(%q) = %q, %v, want %q, %v", tt.name, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestSyncReason(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(path, []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	modified := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	file := localFile{path: path, size: 5, modified: modified}
+	sum := md5.Sum([]byte("hello"))
+	etag := `"` + hex.EncodeToString(sum[:]) + `"`
+	other := `"` + hex.EncodeToString(make([]byte, 16)) + `"`
+	encrypted := S3Repository{ClientEncryptionRules: []ClientEncryptionRule{{Prefix: "secret/", Key: make([]byte, 32)}}}
+
+	tests := []struct {
+		name        string
+		s           S3Repository
+		obj         Object
+		sourceNewer bool
+		want        string
+	}{
+		{name: "size differs", obj: Object{Key: "a.txt", Size: 6, ETag: etag}, want: "size differs"},
+		{name: "same content", obj: Object{Key: "a.txt", Size: 5, ETag: etag}, sourceNewer: true},
+		{
+			name:        "content differs, source newer",
+			obj:         Object{Key: "a.txt", Size: 5, ETag: other, LastModified: modified.Add(-time.Hour)},
+			sourceNewer: true, want: "modified since",
+		},
+		{name: "content differs, destination newer", obj: Object{Key: "a.txt", Size: 5, ETag: other, LastModified: modified.Add(time.Hour)}},
+		{
+			name:        "multipart, source newer",
+			obj:         Object{Key: "a.txt", Size: 5, ETag: `"abc-2"`, LastModified: modified.Add(-time.Hour)},
+			sourceNewer: true, want: "modified since",
+		},
+		{
+			name:        "multipart, same second",
+			obj:         Object{Key: "a.txt", Size: 5, ETag: `"abc-2"`, LastModified: modified.Add(time.Millisecond)},
+			sourceNewer: true,
+		},
+		{
+			name: "client-side encrypted, same size",
+			s:    encrypted,
+			obj:  Object{Key: "secret/a.txt", Size: 5 + envelopeOverhead, ETag: other, LastModified: modified},
+			// the ETag is of the ciphertext and is not compared
+			sourceNewer: true,
+		},
+		{
+			name: "client-side encrypted, size differs",
+			s:    encrypted,
+			obj:  Object{Key: "secret/a.txt", Size: 5, ETag: etag},
+			want: "size differs",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.s.syncReason("bucket", tt.obj, file, tt.sourceNewer)
+			if err != nil {
+				t.Fatalf("syncReason: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("syncReason = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanSteps(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{"a.txt": "hello", "b.txt": "changed", "sub/c.txt": "local only"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, err := listLocal(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := md5.Sum([]byte("hello"))
+	old := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	listed := []Object{
+		{Key: "p/", LastModified: old},
+		{Key: "p/a.txt", Size: 5, ETag: hex.EncodeToString(sum[:]), LastModified: old},
+		{Key: "p/b.txt", Size: 3, LastModified: old},
+		{Key: "p/new.txt", Size: 4, LastModified: old},
+		{Key: "p/.trash/a.txt", Size: 5, LastModified: old},
+		{Key: "p/../evil", Size: 1, LastModified: old},
+	}
+	exclude := func(key string) bool { return key == "p/.trash/a.txt" }
+	local := func(name string) string { return filepath.Join(dir, filepath.FromSlash(name)) }
+
+	tests := []struct {
+		name      string
+		direction SyncDirection
+		opts      SyncOptions
+		// ops and skipped are "action key path (reason)"
+		ops       []string
+		skipped   []string
+		unchanged int
+	}{
+		{
+			name:      "up",
+			direction: SyncUp,
+			opts:      SyncOptions{Exclude: exclude},
+			ops: []string{
+				fmt.Sprintf("upload p/b.txt %s (size differs)", local("b.txt")),
+				fmt.Sprintf("upload p/sub/c.txt %s (new)", local("sub/c.txt")),
+			},
+			unchanged: 1,
+		},
+		{
+			name:      "up with deletions",
+			direction: SyncUp,
+			opts:      SyncOptions{Exclude: exclude, Delete: true},
+			ops: []string{
+				fmt.Sprintf("upload p/b.txt %s (size differs)", local("b.txt")),
+				fmt.Sprintf("upload p/sub/c.txt %s (new)", local("sub/c.txt")),
+				fmt.Sprintf("delete p/../evil  (not in %s)", dir),
+				fmt.Sprintf("delete p/new.txt  (not in %s)", dir),
+			},
+			unchanged: 1,
+		},
+		{
+			name:      "down",
+			direction: SyncDown,
+			opts:      SyncOptions{Exclude: exclude},
+			ops: []string{
+				fmt.Sprintf("download p/b.txt %s (size differs)", local("b.txt")),
+				fmt.Sprintf("download p/new.txt %s (new)", local("new.txt")),
+			},
+			skipped:   []string{fmt.Sprintf("download p/../evil  (the key leads outside of %s)", dir)},
+			unchanged: 1,
+		},
+		{
+			name:      "down with deletions",
+			direction: SyncDown,
+			opts:      SyncOptions{Exclude: exclude, Delete: true},
+			ops: []string{
+				fmt.Sprintf("download p/b.txt %s (size differs)", local("b.txt")),
+				fmt.Sprintf("download p/new.txt %s (new)", local("new.txt")),
+				fmt.Sprintf("delete  %s (not in s3://bucket/p/)", local("sub/c.txt")),
+			},
+			skipped:   []string{fmt.Sprintf("download p/../evil  (the key leads outside of %s)", dir)},
+			unchanged: 1,
+		},
+		{
+			name:      "excluded keys are kept",
+			direction: SyncUp,
+			opts:      SyncOptions{Delete: true},
+			ops: []string{
+				fmt.Sprintf("upload p/b.txt %s (size differs)", local("b.txt")),
+				fmt.Sprintf("upload p/sub/c.txt %s (new)", local("sub/c.txt")),
+				fmt.Sprintf("delete p/../evil  (not in %s)", dir),
+				fmt.Sprintf("delete p/.trash/a.txt  (not in %s)", dir),
+				fmt.Sprintf("delete p/new.txt  (not in %s)", dir),
+			},
+			unchanged: 1,
+		},
+	}
+	format := func(ops []SyncOp) []string {
+		var lines []string
+		for _, op := range ops {
+			lines = append(lines, fmt.Sprintf("%s %s %s (%s)", op.Action, op.Key, op.Path, op.Reason))
+		}
+		return lines
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := &SyncPlan{Direction: tt.direction, Bucket: "bucket", Prefix: "p/", Dir: dir}
+			if err := (S3Repository{}).planSteps(plan, files, listed, tt.opts); err != nil {
+				t.Fatalf("planSteps: %v", err)
+			}
+			if got := format(plan.Ops); !slices.Equal(got, tt.ops) {
+				t.Errorf("ops:\n%q\nwant:\n%q", got, tt.ops)
+			}
+			if got := format(plan.Skipped); !slices.Equal(got, tt.skipped) {
+				t.Errorf("skipped:\n%q\nwant:\n%q", got, tt.skipped)
+			}
+			if plan.Unchanged != tt.unchanged {
+				t.Errorf("unchanged %d, want %d", plan.Unchanged, tt.unchanged)
+			}
+		})
+	}
+}
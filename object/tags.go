@@ -0,0 +1,179 @@
+package object
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Wondrous27/s3-tui/audit"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// tagWorkers bounds how many tag requests FilterByTags makes at once
+const tagWorkers = 16
+
+func (s S3Repository) GetObjectTags(bucket, key string) (map[string]string, error) {
+	out, err := s.Client.GetObjectTagging(context.TODO(), &s3.GetObjectTaggingInput{Bucket: &bucket, Key: &key})
+	if err != nil {
+		return nil, fmt.Errorf("could not get the tags of %s: %w", key, err)
+	}
+	tags := make(map[string]string, len(out.TagSet))
+	for _, tag := range out.TagSet {
+		tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	return tags, nil
+}
+
+// PutObjectTags replaces the tags of an object, no tags removes them all
+func (s S3Repository) PutObjectTags(bucket, key string, tags map[string]string) error {
+	entry := audit.Entry{Operation: "put-tags", Bucket: bucket, Key: key}
+	if len(tags) == 0 {
+		entry.Operation = "delete-tags"
+	}
+	if err := s.refuse(entry); err != nil {
+		return err
+	}
+	var err error
+	if len(tags) == 0 {
+		_, err = s.Client.DeleteObjectTagging(context.TODO(), &s3.DeleteObjectTaggingInput{Bucket: &bucket, Key: &key})
+	} else {
+		tagSet := make([]types.Tag, 0, len(tags))
+		for k, v := range tags {
+			tagSet = append(tagSet, types.Tag{Key: aws.String(k), Value: aws.String(v)})
+		}
+		sort.Slice(tagSet, func(i, j int) bool { return *tagSet[i].Key < *tagSet[j].Key })
+		_, err = s.Client.PutObjectTagging(context.TODO(), &s3.PutObjectTaggingInput{
+			Bucket:  &bucket,
+			Key:     &key,
+			Tagging: &types.Tagging{TagSet: tagSet},
+		})
+	}
+	s.Audit.Record(entry, err)
+	if err != nil {
+		return fmt.Errorf("could not %s s3://%s/%s: %w", entry.Operation, bucket, key, err)
+	}
+	return nil
+}
+
+// TagFilter matches objects whose tags satisfy every one of its terms
+type TagFilter []tagTerm
+
+type tagTerm struct {
+	key string
+	// value is a path.Match pattern, "*" when the term only names a key
+	value string
+	// negate matches objects the term does not match, for key!=value
+	negate bool
+}
+
+// ParseTagFilter parses space or comma separated key=value terms. A value
+// may be a glob pattern, a lone key matches any value and key!=value
+// excludes a value.
+func ParseTagFilter(expr string) (TagFilter, error) {
+	fields := strings.FieldsFunc(expr, func(r rune) bool { return r == ',' || r == ' ' })
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("the filter needs at least one key=value term")
+	}
+	filter := make(TagFilter, 0, len(fields))
+	for _, field := range fields {
+		term := tagTerm{key: field, value: "*"}
+		if k, v, ok := strings.Cut(field, "!="); ok {
+			term = tagTerm{key: k, value: v, negate: true}
+		} else if k, v, ok := strings.Cut(field, "="); ok {
+			term = tagTerm{key: k, value: v}
+		}
+		if term.key == "" {
+			return nil, fmt.Errorf("%q has no tag key", field)
+		}
+		if _, err := path.Match(term.value, ""); err != nil {
+			return nil, fmt.Errorf("%q has a bad pattern: %w", field, err)
+		}
+		filter = append(filter, term)
+	}
+	return filter, nil
+}
+
+// Matches reports whether tags satisfy every term of f
+func (f TagFilter) Matches(tags map[string]string) bool {
+	for _, term := range f {
+		value, ok := tags[term.key]
+		matched := false
+		if ok {
+			matched, _ = path.Match(term.value, value)
+		}
+		if matched == term.negate {
+			return false
+		}
+	}
+	return true
+}
+
+func (f TagFilter) String() string {
+	terms := make([]string, len(f))
+	for i, term := range f {
+		op := "="
+		if term.negate {
+			op = "!="
+		}
+		terms[i] = term.key + op + term.value
+	}
+	return strings.Join(terms, " ")
+}
+
+// FilterByTags returns the keys whose tags match filter, in their original
+// order. The tags are fetched concurrently by a bounded pool of workers and
+// the first error stops the search.
+func (s S3Repository) FilterByTags(bucket string, keys []string, filter TagFilter) ([]string, error) {
+	matched := make([]bool, len(keys))
+	jobs := make(chan int)
+	errs := make(chan error, 1)
+	done := make(chan struct{})
+	var once sync.Once
+	var wg sync.WaitGroup
+	for w := 0; w < min(tagWorkers, len(keys)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				tags, err := s.GetObjectTags(bucket, keys[i])
+				if err != nil {
+					once.Do(func() {
+						errs <- err
+						close(done)
+					})
+					continue
+				}
+				matched[i] = filter.Matches(tags)
+			}
+		}()
+	}
+
+feed:
+	for i := range keys {
+		select {
+		case jobs <- i:
+		case <-done:
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	select {
+	case err := <-errs:
+		return nil, err
+	default:
+	}
+	var result []string
+	for i, key := range keys {
+		if matched[i] {
+			result = append(result, key)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,80 @@
+package object
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTagFilter(t *testing.T) {
+	tests := []struct {
+		expr string
+		// want is the filter as String prints it, or part of the error
+		want    string
+		wantErr bool
+	}{
+		{expr: "env=prod", want: "env=prod"},
+		{expr: "env=prod team", want: "env=prod team=*"},
+		{expr: "env=prod,team=data", want: "env=prod team=data"},
+		{expr: " env=prod , , team=data ", want: "env=prod team=data"},
+		{expr: "env!=dev", want: "env!=dev"},
+		{expr: "env=pr*", want: "env=pr*"},
+		{expr: "env=", want: "env="},
+		{expr: "", want: "at least one key=value term", wantErr: true},
+		{expr: " , ", want: "at least one key=value term", wantErr: true},
+		{expr: "=prod", want: `"=prod" has no tag key`, wantErr: true},
+		{expr: "!=prod", want: `"!=prod" has no tag key`, wantErr: true},
+		{expr: "env=[prod", want: `"env=[prod" has a bad pattern`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			filter, err := ParseTagFilter(tt.expr)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), tt.want) {
+					t.Fatalf("ParseTagFilter(%q) = %v, want an error containing %q", tt.expr, err, tt.want)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseTagFilter(%q): %v", tt.expr, err)
+			}
+			if got := filter.String(); got != tt.want {
+				t.Errorf("ParseTagFilter(%q) = %q, want %q", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTagFilterMatches(t *testing.T) {
+	tags := map[string]string{"env": "prod", "team": "data", "empty": ""}
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{expr: "env=prod", want: true},
+		{expr: "env=dev", want: false},
+		{expr: "env=pr*", want: true},
+		{expr: "env=PROD", want: false},
+		{expr: "team", want: true},
+		{expr: "owner", want: false},
+		{expr: "empty", want: true},
+		{expr: "empty=", want: true},
+		{expr: "env=prod team=data", want: true},
+		{expr: "env=prod team=web", want: false},
+		{expr: "env!=dev", want: true},
+		{expr: "env!=prod", want: false},
+		{expr: "owner!=alice", want: true},
+		{expr: "owner!=*", want: true},
+		{expr: "env!=*", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			filter, err := ParseTagFilter(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseTagFilter(%q): %v", tt.expr, err)
+			}
+			if got := filter.Matches(tags); got != tt.want {
+				t.Errorf("%q matches %v = %v, want %v", tt.expr, tags, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package object
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseURI splits an s3://bucket/key URI into its bucket and key. The key is
+// empty for a bare bucket and keeps its trailing slash for prefixes.
+func ParseURI(uri string) (bucket, key string, err error) {
+	rest, ok := strings.CutPrefix(uri, "s3://")
+	if !ok {
+		return "", "", fmt.Errorf("%s is not an s3:// URI", uri)
+	}
+	bucket, key, _ = strings.Cut(rest, "/")
+	if bucket == "" {
+		return "", "", fmt.Errorf("%s has no bucket", uri)
+	}
+	return bucket, key, nil
+}
+
+// URI formats bucket and key as an s3:// URI, the inverse of ParseURI
+func URI(bucket, key string) string {
+	return "s3://" + bucket + "/" + key
+}
+
+// ARN returns the Amazon Resource Name of an object or prefix, in the
+// partition of the client's region.
+func (s S3Repository) ARN(bucket, key string) string {
+	partition := "aws"
+	switch region := s.Client.Options().Region; {
+	case strings.HasPrefix(region, "cn-"):
+		partition = "aws-cn"
+	case strings.HasPrefix(region, "us-gov-"):
+		partition = "aws-us-gov"
+	}
+	return fmt.Sprintf("arn:%s:s3:::%s/%s", partition, bucket, key)
+}
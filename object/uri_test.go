@@ -0,0 +1,35 @@
+package object
+
+import "testing"
+
+func TestParseURI(t *testing.T) {
+	tests := []struct {
+		uri     string
+		bucket  string
+		key     string
+		wantErr bool
+	}{
+		{uri: "s3://bucket", bucket: "bucket"},
+		{uri: "s3://bucket/", bucket: "bucket"},
+		{uri: "s3://bucket/key.txt", bucket: "bucket", key: "key.txt"},
+		{uri: "s3://bucket/logs/", bucket: "bucket", key: "logs/"},
+		{uri: "s3://bucket/a/b/c.json", bucket: "bucket", key: "a/b/c.json"},
+		{uri: "s3://bucket//double", bucket: "bucket", key: "/double"},
+		{uri: "s3://", wantErr: true},
+		{uri: "s3:///key", wantErr: true},
+		{uri: "bucket/key", wantErr: true},
+		{uri: "S3://bucket/key", wantErr: true},
+		{uri: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			bucket, key, err := ParseURI(tt.uri)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseURI(%q) error = %v, want error %v", tt.uri, err, tt.wantErr)
+			}
+			if bucket != tt.bucket || key != tt.key {
+				t.Errorf("ParseURI(%q) = %q, %q, want %q, %q", tt.uri, bucket, key, tt.bucket, tt.key)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package object
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// Upload is an incomplete multipart upload and the parts it holds so far
+type Upload struct {
+	Key       string
+	UploadID  string
+	Initiated time.Time
+	Parts     int
+	// Size is the total size of the uploaded parts, which are billed until
+	// the upload is completed or aborted
+	Size int64
+}
+
+// UsageReport is everything stored under a prefix: the current objects, the
+// noncurrent versions kept by versioning and the parts of incomplete uploads
+type UsageReport struct {
+	Objects  []Object
+	Versions []Object
+	Uploads  []Upload
+}
+
+// ListNoncurrentVersions returns the versions under prefix that are not the
+// current one. Delete markers are left out as they store nothing.
+func (s S3Repository) ListNoncurrentVersions(bucketName, prefix string, progress func(int)) ([]Object, error) {
+	paginator := s3.NewListObjectVersionsPaginator(s.Client, &s3.ListObjectVersionsInput{
+		Bucket: &bucketName,
+		Prefix: &prefix,
+	})
+	var versions []Object
+	for paginator.HasMorePages() {
+		out, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			return nil, fmt.Errorf("could not list versions: %w", err)
+		}
+		for _, version := range out.Versions {
+			if aws.ToBool(version.IsLatest) {
+				continue
+			}
+			versions = append(versions, Object{
+				Key:          aws.ToString(version.Key),
+				LastModified: aws.ToTime(version.LastModified),
+				Size:         aws.ToInt64(version.Size),
+				ETag:         aws.ToString(version.ETag),
+				StorageClass: types.ObjectStorageClass(version.StorageClass),
+			})
+		}
+		if progress != nil {
+			progress(len(out.Versions) + len(out.DeleteMarkers))
+		}
+	}
+	return versions, nil
+}
+
+// ListUploads returns the incomplete multipart uploads under prefix with the
+// size of their parts
+func (s S3Repository) ListUploads(bucketName, prefix string) ([]Upload, error) {
+	var uploads []Upload
+	input := &s3.ListMultipartUploadsInput{Bucket: &bucketName, Prefix: &prefix}
+	for {
+		out, err := s.Client.ListMultipartUploads(context.TODO(), input)
+		if err != nil {
+			return nil, fmt.Errorf("could not list multipart uploads: %w", err)
+		}
+		for _, u := range out.Uploads {
+			upload := Upload{
+				Key:       aws.ToString(u.Key),
+				UploadID:  aws.ToString(u.UploadId),
+				Initiated: aws.ToTime(u.Initiated),
+			}
+			parts := s3.NewListPartsPaginator(s.Client, &s3.ListPartsInput{
+				Bucket:   &bucketName,
+				Key:      u.Key,
+				UploadId: u.UploadId,
+			})
+			for parts.HasMorePages() {
+				page, err := parts.NextPage(context.TODO())
+				if err != nil {
+					return nil, fmt.Errorf("could not list the parts of %s: %w", upload.Key, err)
+				}
+				for _, part := range page.Parts {
+					upload.Parts++
+					upload.Size += aws.ToInt64(part.Size)
+				}
+			}
+			uploads = append(uploads, upload)
+		}
+		if !aws.ToBool(out.IsTruncated) {
+			return uploads, nil
+		}
+		input.KeyMarker, input.UploadIdMarker = out.NextKeyMarker, out.NextUploadIdMarker
+	}
+}
+
+// Usage walks everything stored under prefix. progress is called with the
+// number of keys listed by every page, and may be nil.
+func (s S3Repository) Usage(bucketName, prefix string, progress func(int)) (*UsageReport, error) {
+	var report UsageReport
+	var err error
+	if report.Objects, err = s.listPrefix(bucketName, prefix, progress); err != nil {
+		return nil, err
+	}
+	if report.Versions, err = s.ListNoncurrentVersions(bucketName, prefix, progress); err != nil {
+		return nil, err
+	}
+	if report.Uploads, err = s.ListUploads(bucketName, prefix); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
@@ -0,0 +1,266 @@
+package trash
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Wondrous27/s3-tui/object"
+)
+
+// idFormat names trashed items after their deletion time, so they sort
+// chronologically and the time survives in a bucket prefix.
+const idFormat = "20060102T150405.000000000Z"
+
+// ErrNothingToUndo is returned by Undo when no deletion is left to restore
+var ErrNothingToUndo = errors.New("nothing to undo")
+
+// Item is an object that was moved to the trash
+type Item struct {
+	ID        string    `json:"id"`
+	Bucket    string    `json:"bucket"`
+	Key       string    `json:"key"`
+	Size      int64     `json:"size"`
+	DeletedAt time.Time `json:"deleted_at"`
+	// Location is the trashed copy, a local file or a key in Bucket
+	Location string `json:"location"`
+	// Encryption and Headers are how a local copy was stored, to restore it
+	// the same way. The copy is kept sealed when it is encrypted client-side.
+	Encryption *object.Encryption `json:"encryption,omitempty"`
+	Headers    *object.Headers    `json:"headers,omitempty"`
+}
+
+// Trash turns deletions into moves, either into a local directory or under a
+// prefix of the object's own bucket, and keeps an undo stack of them.
+type Trash struct {
+	Or *object.S3Repository
+	// Dir keeps trashed objects on the local disk
+	Dir string
+	// Prefix keeps trashed objects in their bucket, used when Dir is empty
+	Prefix string
+
+	mu   sync.Mutex
+	undo []Item
+}
+
+// Hides reports whether key is inside the trash prefix and should be left
+// out of listings.
+func (t *Trash) Hides(key string) bool {
+	return t != nil && t.Dir == "" && strings.HasPrefix(key, t.prefix())
+}
+
+// Delete copies the object to the trash before deleting it
+func (t *Trash) Delete(bucket, key string) (Item, error) {
+	if t.Or.ReadOnly {
+		return Item{}, fmt.Errorf("could not trash s3://%s/%s: %w", bucket, key, object.ErrReadOnly)
+	}
+	now := time.Now().UTC()
+	item := Item{ID: now.Format(idFormat), Bucket: bucket, Key: key, DeletedAt: now}
+
+	if t.Dir != "" {
+		item.Location = filepath.Join(t.Dir, item.ID+".data")
+		if err := t.writeLocal(&item); err != nil {
+			return Item{}, err
+		}
+	} else {
+		item.Location = t.prefix() + path.Join(item.ID, key)
+		if err := t.Or.CopyObject(bucket, key, bucket, item.Location); err != nil {
+			return Item{}, err
+		}
+	}
+
+	if err := t.Or.DeleteObject(bucket, key); err != nil {
+		return Item{}, err
+	}
+	t.mu.Lock()
+	t.undo = append(t.undo, item)
+	t.mu.Unlock()
+	return item, nil
+}
+
+// Undo restores the most recent deletion of this session
+func (t *Trash) Undo() (Item, error) {
+	t.mu.Lock()
+	if len(t.undo) == 0 {
+		t.mu.Unlock()
+		return Item{}, ErrNothingToUndo
+	}
+	item := t.undo[len(t.undo)-1]
+	t.undo = t.undo[:len(t.undo)-1]
+	t.mu.Unlock()
+
+	if err := t.Restore(item); err != nil {
+		t.mu.Lock()
+		t.undo = append(t.undo, item)
+		t.mu.Unlock()
+		return Item{}, err
+	}
+	return item, nil
+}
+
+// Restore puts a trashed object back at its original key and removes it from
+// the trash.
+func (t *Trash) Restore(item Item) error {
+	if t.Dir != "" {
+		file, err := os.Open(item.Location)
+		if err != nil {
+			return fmt.Errorf("could not open trashed copy: %w", err)
+		}
+		if item.Encryption != nil && item.Headers != nil {
+			enc := *item.Encryption
+			// The customer key of SSE-C is never written, it comes from the
+			// rules like when the object was read
+			enc.CustomerKey = t.Or.DefaultEncryption(item.Bucket, item.Key).CustomerKey
+			err = t.Or.PutStoredObject(file, item.Bucket, item.Key, enc, *item.Headers)
+		} else {
+			err = t.Or.PutObject(file, item.Bucket, item.Key)
+		}
+		file.Close()
+		if err != nil {
+			return err
+		}
+	} else if err := t.Or.CopyObject(item.Bucket, item.Location, item.Bucket, item.Key); err != nil {
+		return err
+	}
+	return t.Purge(item)
+}
+
+// Purge permanently deletes a trashed object
+func (t *Trash) Purge(item Item) error {
+	t.forget(item)
+	if t.Dir != "" {
+		if err := os.Remove(item.Location); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("could not purge %s: %w", item.Location, err)
+		}
+		if err := os.Remove(metaPath(item.Location)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("could not purge %s: %w", item.Location, err)
+		}
+		return nil
+	}
+	return t.Or.DeleteObject(item.Bucket, item.Location)
+}
+
+// List returns the trashed objects of bucket, most recent first
+func (t *Trash) List(bucket string) ([]Item, error) {
+	var items []Item
+	var err error
+	if t.Dir != "" {
+		items, err = t.listLocal(bucket)
+	} else {
+		items, err = t.listPrefix(bucket)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].DeletedAt.After(items[j].DeletedAt)
+	})
+	return items, nil
+}
+
+func (t *Trash) listLocal(bucket string) ([]Item, error) {
+	matches, err := filepath.Glob(filepath.Join(t.Dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var items []Item
+	for _, match := range matches {
+		data, err := os.ReadFile(match)
+		if err != nil {
+			return nil, fmt.Errorf("could not read trash index: %w", err)
+		}
+		var item Item
+		if err := json.Unmarshal(data, &item); err != nil {
+			return nil, fmt.Errorf("could not parse %s: %w", match, err)
+		}
+		if item.Bucket == bucket {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+func (t *Trash) listPrefix(bucket string) ([]Item, error) {
+	objects, err := t.Or.ListPrefix(bucket, t.prefix())
+	if err != nil {
+		return nil, err
+	}
+	var items []Item
+	for _, obj := range objects {
+		// Keys look like <prefix>/<id>/<original key>
+		id, key, ok := strings.Cut(strings.TrimPrefix(obj.Key, t.prefix()), "/")
+		if !ok {
+			continue
+		}
+		deletedAt, err := time.Parse(idFormat, id)
+		if err != nil {
+			continue
+		}
+		items = append(items, Item{
+			ID:        id,
+			Bucket:    bucket,
+			Key:       key,
+			Size:      obj.Size,
+			DeletedAt: deletedAt,
+			Location:  obj.Key,
+		})
+	}
+	return items, nil
+}
+
+// writeLocal copies the object of item to its local location as it is
+// stored, and writes the index that restores it
+func (t *Trash) writeLocal(item *Item) error {
+	if err := os.MkdirAll(t.Dir, 0o700); err != nil {
+		return fmt.Errorf("could not create trash directory: %w", err)
+	}
+	file, err := os.OpenFile(item.Location, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("could not write trashed copy: %w", err)
+	}
+	obj, headers, err := t.Or.GetStoredObject(item.Bucket, item.Key, file)
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("could not write trashed copy: %w", closeErr)
+	}
+	if err != nil {
+		os.Remove(item.Location)
+		return err
+	}
+	item.Size, item.Encryption, item.Headers = obj.Size, obj.Encryption, &headers
+	meta, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(metaPath(item.Location), meta, 0o600); err != nil {
+		return fmt.Errorf("could not write trash index: %w", err)
+	}
+	return nil
+}
+
+// forget drops item from the undo stack once it left the trash another way
+func (t *Trash) forget(item Item) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, it := range t.undo {
+		if it.ID == item.ID && it.Bucket == item.Bucket {
+			t.undo = append(t.undo[:i], t.undo[i+1:]...)
+			return
+		}
+	}
+}
+
+func (t *Trash) prefix() string {
+	return strings.TrimSuffix(t.Prefix, "/") + "/"
+}
+
+func metaPath(location string) string {
+	return strings.TrimSuffix(location, ".data") + ".json"
+}
@@ -0,0 +1,126 @@
+package tree
+
+import (
+	"path"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// NameFilter matches the names of nodes against a glob or a regular
+// expression. Both match anywhere in the name, and ignore case unless the
+// pattern has an upper case letter.
+type NameFilter struct {
+	Pattern string
+	Regex   bool
+	re      *regexp.Regexp
+}
+
+// ParseNameFilter compiles pattern, as a regular expression when regex is set
+// and as a glob otherwise
+func ParseNameFilter(pattern string, regex bool) (NameFilter, error) {
+	f := NameFilter{Pattern: pattern, Regex: regex}
+	if !regex {
+		// Check the glob once so Matches can ignore the error
+		_, err := path.Match(pattern, "")
+		return f, err
+	}
+	expr := pattern
+	if !hasUpper(pattern) {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return f, err
+	}
+	f.re = re
+	return f, nil
+}
+
+// ParseKeyFilter compiles a filter for whole keys. Unlike in names, the
+// stars of a glob match slashes too, so "2024*.csv" finds the CSV files of
+// every directory below 2024.
+func ParseKeyFilter(pattern string, regex bool) (NameFilter, error) {
+	if regex {
+		return ParseNameFilter(pattern, true)
+	}
+	f := NameFilter{Pattern: pattern}
+	expr := globRegexp(pattern)
+	if !hasUpper(pattern) {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return f, err
+	}
+	f.re = re
+	return f, nil
+}
+
+// globRegexp translates a glob to an unanchored regular expression
+func globRegexp(glob string) string {
+	var sb strings.Builder
+	for i := 0; i < len(glob); i++ {
+		switch glob[i] {
+		case '*':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteString(".")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				sb.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + class + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				sb.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+			}
+		default:
+			sb.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	return sb.String()
+}
+
+// Matches reports whether name matches the filter, an empty one matches
+// every name
+func (f NameFilter) Matches(name string) bool {
+	switch {
+	case f.Pattern == "":
+		return true
+	case f.re != nil:
+		return f.re.MatchString(name)
+	}
+	pattern := f.Pattern
+	if !hasUpper(pattern) {
+		pattern, name = strings.ToLower(pattern), strings.ToLower(name)
+	}
+	ok, _ := path.Match("*"+pattern+"*", name)
+	return ok
+}
+
+// Filter returns the nodes whose names match
+func (f NameFilter) Filter(nodes []*Node) []*Node {
+	if f.Pattern == "" {
+		return nodes
+	}
+	var kept []*Node
+	for _, n := range nodes {
+		if f.Matches(n.Name) {
+			kept = append(kept, n)
+		}
+	}
+	return kept
+}
+
+func hasUpper(s string) bool {
+	return strings.IndexFunc(s, unicode.IsUpper) >= 0
+}
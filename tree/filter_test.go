@@ -0,0 +1,138 @@
+package tree
+
+import "testing"
+
+func TestParseNameFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		regex   bool
+		wantErr bool
+		// matches and misses are names the filter keeps and drops
+		matches []string
+		misses  []string
+	}{
+		{name: "empty", pattern: "", matches: []string{"", "anything"}},
+		{name: "substring", pattern: "log", matches: []string{"log", "app.log", "logs", "Catalog"}, misses: []string{"lg"}},
+		{name: "glob", pattern: "*.csv", matches: []string{"a.csv", "a.csv.gz", "A.CSV"}, misses: []string{"acsv"}},
+		{name: "question mark", pattern: "v?.txt", matches: []string{"v1.txt", "old-v2.txt"}, misses: []string{"v10.txt"}},
+		{name: "class", pattern: "[ab].md", matches: []string{"a.md", "b.md"}, misses: []string{"c.md"}},
+		{name: "upper case is exact", pattern: "README", matches: []string{"README.md"}, misses: []string{"readme.md"}},
+		{name: "bad glob", pattern: "[a", wantErr: true},
+		{name: "regex", pattern: `^\d+\.json$`, regex: true, matches: []string{"12.json", "12.JSON"}, misses: []string{"a12.json", "12.json.gz"}},
+		{name: "regex unanchored", pattern: "b.d", regex: true, matches: []string{"abcde"}, misses: []string{"bd"}},
+		{name: "regex upper case is exact", pattern: "Log", regex: true, matches: []string{"Logs"}, misses: []string{"logs"}},
+		{name: "bad regex", pattern: "(", regex: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ParseNameFilter(tt.pattern, tt.regex)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseNameFilter(%q) accepted the pattern", tt.pattern)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseNameFilter(%q): %v", tt.pattern, err)
+			}
+			for _, name := range tt.matches {
+				if !f.Matches(name) {
+					t.Errorf("%q does not match %q", tt.pattern, name)
+				}
+			}
+			for _, name := range tt.misses {
+				if f.Matches(name) {
+					t.Errorf("%q matches %q", tt.pattern, name)
+				}
+			}
+		})
+	}
+}
+
+func TestNameFilterFilter(t *testing.T) {
+	nodes := []*Node{{Name: "a.csv"}, {Name: "dir", IsDir: true}, {Name: "b.csv"}}
+	f, err := ParseNameFilter("*.csv", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept := f.Filter(nodes)
+	if len(kept) != 2 || kept[0].Name != "a.csv" || kept[1].Name != "b.csv" {
+		t.Errorf("Filter kept %d nodes, want a.csv and b.csv", len(kept))
+	}
+	if kept := (NameFilter{}).Filter(nodes); len(kept) != len(nodes) {
+		t.Errorf("the empty filter kept %d of %d nodes", len(kept), len(nodes))
+	}
+}
+
+func TestGlobRegexp(t *testing.T) {
+	tests := []struct {
+		glob string
+		want string
+	}{
+		{glob: "", want: ""},
+		{glob: "abc", want: "abc"},
+		{glob: "*.csv", want: `.*\.csv`},
+		{glob: "v?", want: "v."},
+		{glob: "[ab]x", want: "[ab]x"},
+		{glob: "[!ab]x", want: "[^ab]x"},
+		{glob: "[ab", want: `\[ab`},
+		{glob: `a\*b`, want: `a\*b`},
+		{glob: `a\`, want: "a"},
+		{glob: "(a|b)+", want: `\(a\|b\)\+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.glob, func(t *testing.T) {
+			if got := globRegexp(tt.glob); got != tt.want {
+				t.Errorf("globRegexp(%q) = %q, want %q", tt.glob, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseKeyFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		regex   bool
+		wantErr bool
+		// matches and misses are keys the filter keeps and drops
+		matches []string
+		misses  []string
+	}{
+		{name: "star crosses slashes", pattern: "2024*.csv", matches: []string{"logs/2024/01/a.csv", "2024.csv"}, misses: []string{"2023/a.csv"}},
+		{name: "question mark crosses slashes", pattern: "a?b", matches: []string{"a/b"}},
+		{name: "ignores case", pattern: "*.csv", matches: []string{"dir/A.CSV"}},
+		{name: "upper case is exact", pattern: "Logs/", matches: []string{"Logs/a"}, misses: []string{"logs/a"}},
+		{name: "negated class", pattern: "v[!0-9]", matches: []string{"va"}, misses: []string{"v1"}},
+		{name: "escaped star", pattern: `a\*b`, matches: []string{"x/a*b"}, misses: []string{"axxb"}},
+		{name: "meta characters are literal", pattern: "a+b", matches: []string{"a+b"}, misses: []string{"aab"}},
+		{name: "bad class", pattern: "[z-a]", wantErr: true},
+		{name: "regex", pattern: `^logs/.*\.gz$`, regex: true, matches: []string{"logs/2024/a.gz"}, misses: []string{"old/logs/a.gz"}},
+		{name: "bad regex", pattern: "(", regex: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ParseKeyFilter(tt.pattern, tt.regex)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseKeyFilter(%q) accepted the pattern", tt.pattern)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseKeyFilter(%q): %v", tt.pattern, err)
+			}
+			for _, key := range tt.matches {
+				if !f.Matches(key) {
+					t.Errorf("%q does not match %q", tt.pattern, key)
+				}
+			}
+			for _, key := range tt.misses {
+				if f.Matches(key) {
+					t.Errorf("%q matches %q", tt.pattern, key)
+				}
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package tree
 
 import (
+	"fmt"
 	"sort"
 	"strings"
 	"time"
@@ -13,14 +14,20 @@ type Node struct {
 	Children     []*Node
 	Content      []byte
 	LastModified *time.Time
+	// StorageClass is the class of an object, empty for directories
+	StorageClass string
+	// Size and Count are the bytes of a file and 1, or the totals of every
+	// file below a directory, once added with InsertSized
+	Size  int64
+	Count int
 }
 
 type FileTree struct {
 	Root *Node
 }
 
-func (ft *FileTree) Insert(file string) {
-	ft.Root.Insert(file)
+func (ft *FileTree) Insert(file string) *Node {
+	return ft.Root.Insert(file)
 }
 
 func (n *Node) insertHelper(newNode *Node) *Node {
@@ -33,7 +40,9 @@ func (n *Node) insertHelper(newNode *Node) *Node {
 	return newNode
 }
 
-func (n *Node) Insert(file string) {
+// Insert adds the directories and the file of a key below n and returns the
+// file's node.
+func (n *Node) Insert(file string) *Node {
 	parts := strings.Split(file, "/")
 	curr := n
 	for i, part := range parts {
@@ -41,6 +50,61 @@ func (n *Node) Insert(file string) {
 		newNode := &Node{Name: part, IsDir: isDir, Parent: curr}
 		curr = curr.insertHelper(newNode)
 	}
+	return curr
+}
+
+// InsertSized inserts a file of size bytes and adds it to the totals of
+// every directory above it.
+func (n *Node) InsertSized(file string, size int64) *Node {
+	leaf := n.Insert(file)
+	for curr := leaf; ; curr = curr.Parent {
+		curr.Size += size
+		curr.Count++
+		if curr.Parent == curr || curr.Parent == nil {
+			return leaf
+		}
+	}
+}
+
+// Path rebuilds the object key, or the directory prefix without its trailing
+// slash, from the names between n and the root.
+func (n *Node) Path() string {
+	if n.Parent == n {
+		return ""
+	}
+	curr := n
+	path := []string{curr.Name}
+	for curr.Parent.Name != "" {
+		curr = curr.Parent
+		path = append(path, curr.Name)
+	}
+	var p string
+	for i := len(path) - 1; i >= 0; i-- {
+		p += path[i] + "/"
+	}
+	return p[:len(p)-1]
+}
+
+// Find returns the node at path below n, or nil if there is none.
+func (n *Node) Find(path string) *Node {
+	curr := n
+	for _, part := range strings.Split(strings.Trim(path, "/"), "/") {
+		if part == "" {
+			continue
+		}
+		var next *Node
+		for _, child := range curr.Children {
+			if child.Name == part {
+				next = child
+				break
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		curr = next
+	}
+	return curr
 }
 
 func NewFileTree(input []string) *FileTree {
@@ -54,22 +118,145 @@ func NewFileTree(input []string) *FileTree {
 	return ft
 }
 
+// SetLastModified sets when a file was last modified, and makes it the last
+// modification of every directory above it that has no newer one.
+func (n *Node) SetLastModified(t time.Time) {
+	for curr := n; ; curr = curr.Parent {
+		if curr.LastModified == nil || curr.LastModified.Before(t) {
+			curr.LastModified = &t
+		}
+		if curr.Parent == curr || curr.Parent == nil {
+			return
+		}
+	}
+}
+
+// SortField is what the children of a node are sorted by
+type SortField int
+
+const (
+	SortByName SortField = iota
+	SortBySize
+	SortByModified
+	// SortByNatural compares the runs of digits in names as numbers, so
+	// part-2 comes before part-10
+	SortByNatural
+)
+
+var sortFieldNames = []string{"name", "size", "modified", "natural"}
+
+func (f SortField) String() string { return sortFieldNames[f] }
+
+// SortFields are the fields in the order they are cycled through
+var SortFields = []SortField{SortByName, SortBySize, SortByModified, SortByNatural}
+
+// SortOrder is how the children of every node are sorted
+type SortOrder struct {
+	Field      SortField
+	Descending bool
+	// DirsFirst puts directories before files whatever the field
+	DirsFirst bool
+}
+
+// DefaultSort is directories first, then files, each by name
+var DefaultSort = SortOrder{Field: SortByName, DirsFirst: true}
+
+func (o SortOrder) String() string {
+	direction := "ascending"
+	if o.Descending {
+		direction = "descending"
+	}
+	if o.DirsFirst {
+		return fmt.Sprintf("%s %s, directories first", o.Field, direction)
+	}
+	return fmt.Sprintf("%s %s", o.Field, direction)
+}
+
 func (ft *FileTree) Sort() {
 	ft.Root.Sort()
 }
 
 func (n *Node) Sort() {
-	sortByDir(n.Children)
+	n.SortBy(DefaultSort)
+}
+
+// SortBy sorts the children of n and of every node below it
+func (n *Node) SortBy(order SortOrder) {
+	sortNodes(n.Children, order)
 	for _, child := range n.Children {
-		child.Sort()
+		child.SortBy(order)
 	}
 }
 
-func sortByDir(n []*Node) {
+func sortNodes(n []*Node, order SortOrder) {
 	sort.SliceStable(n, func(i, j int) bool {
-		if n[i].IsDir == n[j].IsDir {
-			return n[i].Name < n[j].Name
+		if order.DirsFirst && n[i].IsDir != n[j].IsDir {
+			return n[i].IsDir
+		}
+		a, b := n[i], n[j]
+		if order.Descending {
+			a, b = b, a
+		}
+		switch order.Field {
+		case SortBySize:
+			if a.Size != b.Size {
+				return a.Size < b.Size
+			}
+		case SortByModified:
+			if at, bt := modified(a), modified(b); !at.Equal(bt) {
+				return at.Before(bt)
+			}
+		case SortByNatural:
+			if a.Name != b.Name {
+				return naturalLess(a.Name, b.Name)
+			}
 		}
-		return n[i].IsDir
+		return a.Name < b.Name
 	})
 }
+
+func modified(n *Node) time.Time {
+	if n.LastModified == nil {
+		return time.Time{}
+	}
+	return *n.LastModified
+}
+
+// naturalLess compares names chunk by chunk, runs of digits by their value
+// and the rest as text
+func naturalLess(a, b string) bool {
+	for a != "" && b != "" {
+		ca, ra := chunk(a)
+		cb, rb := chunk(b)
+		if ca != cb {
+			da, db := isDigit(ca[0]), isDigit(cb[0])
+			if da && db {
+				na, nb := strings.TrimLeft(ca, "0"), strings.TrimLeft(cb, "0")
+				if len(na) != len(nb) {
+					return len(na) < len(nb)
+				}
+				if na != nb {
+					return na < nb
+				}
+				return len(ca) < len(cb)
+			}
+			return ca < cb
+		}
+		a, b = ra, rb
+	}
+	return len(a) < len(b)
+}
+
+// chunk splits s after its leading run of digits, or of anything else
+func chunk(s string) (string, string) {
+	digits := isDigit(s[0])
+	i := 1
+	for i < len(s) && isDigit(s[i]) == digits {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
@@ -0,0 +1,109 @@
+package tree
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestNaturalLess(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{a: "file2", b: "file10", want: true},
+		{a: "file10", b: "file2", want: false},
+		{a: "file2", b: "file2", want: false},
+		{a: "a", b: "b", want: true},
+		{a: "file", b: "file1", want: true},
+		{a: "file1", b: "file", want: false},
+		{a: "", b: "a", want: true},
+		{a: "9", b: "a", want: true},
+		{a: "v1.9", b: "v1.10", want: true},
+		{a: "v1.10.2", b: "v1.10.10", want: true},
+		{a: "2", b: "02", want: true},
+		{a: "02", b: "2", want: false},
+		{a: "007", b: "10", want: true},
+		{a: "x99999999999999999999999", b: "x100000000000000000000000", want: true},
+		{a: "img12b", b: "img12a", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+" "+tt.b, func(t *testing.T) {
+			if got := naturalLess(tt.a, tt.b); got != tt.want {
+				t.Errorf("naturalLess(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSortNodes(t *testing.T) {
+	day := func(d int) *time.Time {
+		modified := time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC)
+		return &modified
+	}
+	nodes := func() []*Node {
+		return []*Node{
+			{Name: "file10", Size: 10, LastModified: day(3)},
+			{Name: "dir2", IsDir: true, Size: 50},
+			{Name: "file2", Size: 30, LastModified: day(1)},
+			{Name: "File1", Size: 10, LastModified: day(2)},
+			{Name: "dir10", IsDir: true, Size: 5},
+		}
+	}
+	tests := []struct {
+		name  string
+		order SortOrder
+		want  []string
+	}{
+		{
+			name:  "default",
+			order: DefaultSort,
+			want:  []string{"dir10", "dir2", "File1", "file10", "file2"},
+		},
+		{
+			name:  "by name descending",
+			order: SortOrder{Field: SortByName, Descending: true},
+			want:  []string{"file2", "file10", "dir2", "dir10", "File1"},
+		},
+		{
+			name:  "by name descending, directories first",
+			order: SortOrder{Field: SortByName, Descending: true, DirsFirst: true},
+			want:  []string{"dir2", "dir10", "file2", "file10", "File1"},
+		},
+		{
+			name:  "natural",
+			order: SortOrder{Field: SortByNatural, DirsFirst: true},
+			want:  []string{"dir2", "dir10", "File1", "file2", "file10"},
+		},
+		{
+			// ties are broken by name
+			name:  "by size",
+			order: SortOrder{Field: SortBySize},
+			want:  []string{"dir10", "File1", "file10", "file2", "dir2"},
+		},
+		{
+			name:  "by size descending",
+			order: SortOrder{Field: SortBySize, Descending: true},
+			want:  []string{"dir2", "file2", "file10", "File1", "dir10"},
+		},
+		{
+			// directories have no modification time and come first
+			name:  "by modified",
+			order: SortOrder{Field: SortByModified},
+			want:  []string{"dir10", "dir2", "file2", "File1", "file10"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := nodes()
+			sortNodes(n, tt.order)
+			var got []string
+			for _, node := range n {
+				got = append(got, node.Name)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("sorted %v by %s, want %v", got, tt.order, tt.want)
+			}
+		})
+	}
+}
@@ -1,15 +1,16 @@
 package tui
 
 import (
+	"errors"
 	"fmt"
 
+	"github.com/Wondrous27/s3-tui/auth"
 	"github.com/Wondrous27/s3-tui/bucket"
 	"github.com/Wondrous27/s3-tui/tui/constants"
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
-	"github.com/charmbracelet/lipgloss"
 )
 
 type mode int
@@ -35,6 +36,8 @@ type Model struct {
 	input    textinput.Model
 	quitting bool
 	isSure   bool
+	error    string
+	expires  sessionExpiresMsg
 }
 
 /* Implement tea.Model for Model */
@@ -55,7 +58,7 @@ func (m Model) View() string {
 		return constants.DocStyle.Render(m.list.View() + "\n" + m.input.View())
 	}
 
-	return constants.DocStyle.Render(m.list.View() + "\n")
+	return constants.DocStyle.Render(m.list.View() + "\n" + sessionStatus(m.expires) + "\n" + constants.ErrStyle(m.error))
 }
 
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -67,6 +70,12 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		top, right, bottom, left := constants.DocStyle.GetMargin()
 		m.list.SetSize(msg.Width-left-right, msg.Height-top-bottom-1)
 
+	case sessionExpiresMsg:
+		m.expires = msg
+
+	case errMsg:
+		m.error = msg.Error()
+
 	case CreatedBucketMsg:
 		m.setupBuckets()
 
@@ -104,13 +113,11 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					return m, tea.Quit
 
 				case key.Matches(msg, constants.Keymap.Enter):
-					bucket := m.list.SelectedItem().(bucket.Bucket)
 					m.mode = nav
-					if m.isSure {
-						return m, deleteBucketCommand(bucket.Name)
-					} else {
-						return m, nil
+					if activeBucket, ok := m.list.SelectedItem().(bucket.Bucket); ok && m.isSure {
+						return m, deleteBucketCommand(activeBucket.Name)
 					}
+					return m, nil
 
 				case key.Matches(msg, constants.Keymap.Next), key.Matches(msg, constants.Keymap.Prev):
 					m.isSure = !m.isSure
@@ -119,21 +126,57 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 			switch {
 			case key.Matches(msg, constants.Keymap.Delete):
-				m.mode = del
+				if _, ok := m.list.SelectedItem().(bucket.Bucket); ok {
+					m.mode = del
+				}
 
 			case key.Matches(msg, constants.Keymap.Create):
 				m.input.Focus()
 				cmd = textinput.Blink
 				cmds = append(cmds, cmd)
 
+			case key.Matches(msg, constants.Keymap.Region):
+				return InitRegion(InitBuckets)
+
+			case key.Matches(msg, constants.Keymap.History):
+				return InitHistory(InitBuckets)
+
+			case key.Matches(msg, constants.Keymap.Details):
+				if activeBucket, ok := m.list.SelectedItem().(bucket.Bucket); ok {
+					return InitDetails(activeBucket.Name, InitBuckets)
+				}
+
+			case key.Matches(msg, constants.Keymap.Policy):
+				if activeBucket, ok := m.list.SelectedItem().(bucket.Bucket); ok {
+					return InitPolicy(activeBucket.Name, InitBuckets)
+				}
+
+			case key.Matches(msg, constants.Keymap.Lifecycle):
+				if activeBucket, ok := m.list.SelectedItem().(bucket.Bucket); ok {
+					return InitLifecycle(activeBucket.Name, InitBuckets)
+				}
+
+			case key.Matches(msg, constants.Keymap.Find):
+				var buckets []string
+				for _, item := range m.list.Items() {
+					buckets = append(buckets, item.(bucket.Bucket).Name)
+				}
+				return InitKeySearch(buckets, InitBuckets)
+
+			case key.Matches(msg, constants.Keymap.Usage):
+				if activeBucket, ok := m.list.SelectedItem().(bucket.Bucket); ok {
+					return InitUsage(activeBucket.Name, "", InitBuckets)
+				}
+
 			case key.Matches(msg, constants.Keymap.Quit):
 				m.quitting = true
 				return m, tea.Quit
 
 			case key.Matches(msg, constants.Keymap.Enter), key.Matches(msg, constants.Keymap.Next):
-				activeBucket := m.list.SelectedItem().(bucket.Bucket)
-				tree := InitTree(activeBucket.Name)
-				return tree.Update(constants.WindowSize)
+				if activeBucket, ok := m.list.SelectedItem().(bucket.Bucket); ok {
+					return openTree(activeBucket.Name, "", m)
+				}
+				return m, nil
 			}
 		}
 	}
@@ -151,7 +194,13 @@ func InitBuckets() (tea.Model, tea.Cmd) {
 	input.CharLimit = 250
 	input.Width = 50
 
+	if constants.Session.Config.Region == "" {
+		return InitRegion(InitBuckets)
+	}
 	items, err := constants.Br.GetAllBuckets()
+	if errors.Is(err, auth.ErrMFATokenRequired) {
+		return InitMFA(InitBuckets)
+	}
 	if err != nil {
 		return nil, func() tea.Msg {
 			return errMsg{error: err}
@@ -170,10 +219,17 @@ func InitBuckets() (tea.Model, tea.Cmd) {
 			constants.Keymap.Create,
 			constants.Keymap.Rename,
 			constants.Keymap.Delete,
+			constants.Keymap.Details,
+			constants.Keymap.Policy,
+			constants.Keymap.Lifecycle,
+			constants.Keymap.Usage,
+			constants.Keymap.Find,
+			constants.Keymap.Region,
+			constants.Keymap.History,
 			constants.Keymap.Back,
 		}
 	}
-	return m, nil
+	return m, sessionExpiresCmd()
 }
 
 func (m *Model) setupBuckets() tea.Msg {
@@ -186,33 +242,10 @@ func (m *Model) setupBuckets() tea.Msg {
 }
 
 func (m Model) DisplayConfirmation() string {
-	buttonStyle := map[bool]lipgloss.Style{
-		true:  constants.ActiveButtonStyle,
-		false: constants.ButtonStyle,
+	activeBucket, ok := m.list.SelectedItem().(bucket.Bucket)
+	if !ok {
+		return constants.DocStyle.Render(m.list.View())
 	}
-	okb := buttonStyle[m.isSure]
-	cb := buttonStyle[!m.isSure]
-
-	okButton := okb.Render("Yes")
-	cancelButton := cb.Render("No")
-
-	activeBucket := m.list.SelectedItem().(bucket.Bucket)
 	msg := fmt.Sprintf("Are you sure you want to delete %s?", activeBucket.Name)
-	question := lipgloss.NewStyle().Width(60).Align(lipgloss.Center).Render(msg)
-	buttons := lipgloss.JoinHorizontal(lipgloss.Top, okButton, cancelButton)
-	ui := lipgloss.JoinVertical(lipgloss.Center, question, buttons)
-
-	const (
-		width       = 96
-		columnWidth = 30
-	)
-
-	dialog := lipgloss.Place(width, 9,
-		lipgloss.Center, lipgloss.Center,
-		constants.DialogBoxStyle.Render(ui),
-		lipgloss.WithWhitespaceChars(""),
-		lipgloss.WithWhitespaceForeground(constants.Subtle),
-	)
-
-	return dialog
+	return confirmationDialog(msg, m.isSure)
 }
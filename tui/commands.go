@@ -5,14 +5,18 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	"strings"
 
+	"github.com/Wondrous27/s3-tui/object"
 	"github.com/Wondrous27/s3-tui/tui/constants"
 	"github.com/Wondrous27/s3-tui/utils"
 	tea "github.com/charmbracelet/bubbletea"
 )
 
+// openEditorCmd edits data in $EDITOR. The file is read back and removed as
+// soon as the editor exits, as it may hold decrypted content.
 func openEditorCmd(data, extension string) tea.Cmd {
-	file, err := utils.CreateTempFile(data, extension)
+	path, err := utils.CreateTempFile(data, extension)
 	if err != nil {
 		return func() tea.Msg {
 			return errMsg{error: err}
@@ -22,18 +26,33 @@ func openEditorCmd(data, extension string) tea.Cmd {
 	if editor == "" {
 		editor = "vim"
 	}
-	c := exec.Command(editor, file.Name())
+	c := exec.Command(editor, path)
 	return tea.ExecProcess(c, func(err error) tea.Msg {
-		return editorFinishedMsg{err, file}
+		defer func() {
+			if err := utils.RemoveTempFile(path); err != nil {
+				log.Printf("[openEditorCmd] %v", err)
+			}
+		}()
+		if err != nil {
+			return editorFinishedMsg{err: err}
+		}
+		content, err := os.ReadFile(path)
+		return editorFinishedMsg{err: err, content: string(content)}
 	})
 }
 
-func (m Object) updateObjectCmd(fileName string) tea.Cmd {
+func (m Object) updateObjectCmd(content string) tea.Cmd {
 	return func() tea.Msg {
-		file, _ := os.Open(fileName)
+		file := strings.NewReader(content)
 		key := m.object.Key
 		bucket := m.activeBucketName
-		err := constants.Or.PutObject(file, bucket, key)
+		// Edits keep the encryption of the object, unless the rules ask for
+		// another one
+		enc := constants.Or.DefaultEncryption(bucket, key)
+		if m.object.Encryption != nil && constants.Or.CheckEncryption(bucket, key, *m.object.Encryption) == nil {
+			enc = *m.object.Encryption
+		}
+		err := constants.Or.PutEncryptedObject(file, bucket, key, enc)
 		if err != nil {
 			return errMsg{fmt.Errorf("[updateObjectCmd]: cannot put object %v", err)}
 		}
@@ -41,12 +60,11 @@ func (m Object) updateObjectCmd(fileName string) tea.Cmd {
 	}
 }
 
-func (f Tree) createObjectCommand(fileName, s3Key string) tea.Cmd {
+func (f Tree) createObjectCommand(content, s3Key string, enc object.Encryption) tea.Cmd {
 	return func() tea.Msg {
-		file, _ := os.Open(fileName)
 		bucket := f.BucketName
-		err := constants.Or.PutObject(file, bucket, s3Key)
-		log.Printf("putting object with fileName %s, bucket %s, key %s", fileName, bucket, s3Key)
+		err := constants.Or.PutEncryptedObject(strings.NewReader(content), bucket, s3Key, enc)
+		log.Printf("putting object into bucket %s, key %s", bucket, s3Key)
 		if err != nil {
 			return errMsg{fmt.Errorf("[createObjectCommand] cannot put object %v", err)}
 		}
@@ -54,6 +72,20 @@ func (f Tree) createObjectCommand(fileName, s3Key string) tea.Cmd {
 	}
 }
 
+func (f Tree) uploadCmd(fileName, s3Key string, enc object.Encryption) tea.Cmd {
+	return func() tea.Msg {
+		file, err := os.Open(fileName)
+		if err != nil {
+			return errMsg{fmt.Errorf("[uploadCmd] %v", err)}
+		}
+		defer file.Close()
+		if err := constants.Or.PutEncryptedObject(file, f.BucketName, s3Key, enc); err != nil {
+			return errMsg{fmt.Errorf("[uploadCmd] cannot put object %v", err)}
+		}
+		return f.setupTree(f.BucketName)
+	}
+}
+
 func createBucketCommand(bucketName string) tea.Cmd {
 	return func() tea.Msg {
 		err := constants.Br.CreateBucket(bucketName)
@@ -74,3 +106,50 @@ func deleteBucketCommand(bucketName string) tea.Cmd {
 		return DeletedBucketMsg{err: nil, bucketName: bucketName}
 	}
 }
+
+// deleteQuestion asks to confirm a deletion, mentioning whether it can be undone
+func deleteQuestion(key string) string {
+	if constants.Trash != nil {
+		return fmt.Sprintf("Move %s to the trash?", key)
+	}
+	return fmt.Sprintf("Are you sure you want to permanently delete %s?", key)
+}
+
+// deleteObject moves the object to the trash when it is enabled
+func deleteObject(bucket, key string) error {
+	if constants.Trash != nil {
+		_, err := constants.Trash.Delete(bucket, key)
+		return err
+	}
+	return constants.Or.DeleteObject(bucket, key)
+}
+
+func (f Tree) deleteObjectCmd(key string) tea.Cmd {
+	return func() tea.Msg {
+		if err := deleteObject(f.BucketName, key); err != nil {
+			return errMsg{fmt.Errorf("[deleteObjectCmd] cannot delete %s %v", key, err)}
+		}
+		return f.setupTree(f.BucketName)
+	}
+}
+
+func (f Tree) undoDeleteCmd() tea.Cmd {
+	return func() tea.Msg {
+		if constants.Trash == nil {
+			return errMsg{fmt.Errorf("the trash is not enabled in the config")}
+		}
+		if _, err := constants.Trash.Undo(); err != nil {
+			return errMsg{fmt.Errorf("[undoDeleteCmd] %v", err)}
+		}
+		return f.setupTree(f.BucketName)
+	}
+}
+
+func (m Object) deleteObjectCmd() tea.Cmd {
+	return func() tea.Msg {
+		if err := deleteObject(m.activeBucketName, m.object.Key); err != nil {
+			return errMsg{fmt.Errorf("[deleteObjectCmd] cannot delete %s %v", m.object.Key, err)}
+		}
+		return deletedObjectMsg{}
+	}
+}
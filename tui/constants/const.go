@@ -4,8 +4,11 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/Wondrous27/s3-tui/auth"
 	"github.com/Wondrous27/s3-tui/bucket"
 	"github.com/Wondrous27/s3-tui/object"
+	"github.com/Wondrous27/s3-tui/trash"
+	"github.com/Wondrous27/s3-tui/tree"
 	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/glamour"
@@ -17,12 +20,20 @@ import (
 var (
 	// P the current tea program
 	P *tea.Program
+	// Session the aws session the repositories were created from
+	Session *auth.Session
 	// Br the bucket repository for the tui
 	Br *bucket.S3Repository
 	// Or the object repository for the tui
 	Or *object.S3Repository
+	// Trash receives deleted objects, nil when deletions are permanent
+	Trash *trash.Trash
+	// Grep bounds the searches through the content of objects
+	Grep object.GrepOptions
 	// WindowSize store the size of the terminal window
 	WindowSize tea.WindowSizeMsg
+	// TreeSort is how the tree is sorted, kept for the whole session
+	TreeSort = tree.DefaultSort
 )
 
 /* STYLING */
@@ -76,17 +87,54 @@ var (
 var Subtle = lipgloss.AdaptiveColor{Light: "#D9DCCF", Dark: "#383838"}
 
 type keymap struct {
-	Create key.Binding
-	Edit   key.Binding
-	Enter  key.Binding
-	Rename key.Binding
-	Delete key.Binding
-	Back   key.Binding
-	Quit   key.Binding
-	Next   key.Binding
-	Prev   key.Binding
-	Up     key.Binding
-	Down   key.Binding
+	Create  key.Binding
+	Upload  key.Binding
+	Edit    key.Binding
+	Enter   key.Binding
+	Rename  key.Binding
+	Delete  key.Binding
+	Region  key.Binding
+	History key.Binding
+	Undo    key.Binding
+	Trash   key.Binding
+	Restore key.Binding
+	Presign key.Binding
+	Copy    key.Binding
+	Tags    key.Binding
+	// StorageClass changes the storage class of an object
+	StorageClass key.Binding
+	TagFilter    key.Binding
+	Details      key.Binding
+	Policy       key.Binding
+	Lifecycle    key.Binding
+	Format       key.Binding
+	Simulate     key.Binding
+	// Versioning enables or suspends the versioning of a bucket
+	Versioning key.Binding
+	// Usage shows what is stored under a bucket or prefix
+	Usage key.Binding
+	Sort  key.Binding
+	// Search filters the current directory of the tree by name
+	Search key.Binding
+	// Find fuzzy searches every key of the bucket
+	Find key.Binding
+	// Grep searches the content of the objects under a prefix
+	Grep key.Binding
+	// Sync makes a local directory and a prefix match
+	Sync key.Binding
+	// Reverse and DirsFirst change the order the tree is sorted in
+	Reverse   key.Binding
+	DirsFirst key.Binding
+	// NextField and PrevField move between the inputs of a form
+	NextField key.Binding
+	PrevField key.Binding
+	NextTab   key.Binding
+	Back      key.Binding
+	Quit      key.Binding
+	Next      key.Binding
+	Prev      key.Binding
+	Up        key.Binding
+	Down      key.Binding
 }
 
 // Keymap reusable key mappings shared across models
@@ -95,6 +143,10 @@ var Keymap = keymap{
 		key.WithKeys("c"),
 		key.WithHelp("c", "create"),
 	),
+	Upload: key.NewBinding(
+		key.WithKeys("U"),
+		key.WithHelp("U", "upload"),
+	),
 	Enter: key.NewBinding(
 		key.WithKeys("enter"),
 		key.WithHelp("enter", "select"),
@@ -107,6 +159,114 @@ var Keymap = keymap{
 		key.WithKeys("d"),
 		key.WithHelp("d", "delete"),
 	),
+	Region: key.NewBinding(
+		key.WithKeys("R"),
+		key.WithHelp("R", "region"),
+	),
+	History: key.NewBinding(
+		key.WithKeys("H"),
+		key.WithHelp("H", "history"),
+	),
+	Undo: key.NewBinding(
+		key.WithKeys("u"),
+		key.WithHelp("u", "undo delete"),
+	),
+	Trash: key.NewBinding(
+		key.WithKeys("T"),
+		key.WithHelp("T", "trash"),
+	),
+	Restore: key.NewBinding(
+		key.WithKeys("r"),
+		key.WithHelp("r", "restore"),
+	),
+	Presign: key.NewBinding(
+		key.WithKeys("p"),
+		key.WithHelp("p", "presign"),
+	),
+	Copy: key.NewBinding(
+		key.WithKeys("y"),
+		key.WithHelp("y", "copy path"),
+	),
+	Tags: key.NewBinding(
+		key.WithKeys("t"),
+		key.WithHelp("t", "tags"),
+	),
+	TagFilter: key.NewBinding(
+		key.WithKeys("F"),
+		key.WithHelp("F", "filter by tags"),
+	),
+	StorageClass: key.NewBinding(
+		key.WithKeys("S"),
+		key.WithHelp("S", "storage class"),
+	),
+	Details: key.NewBinding(
+		key.WithKeys("i"),
+		key.WithHelp("i", "details"),
+	),
+	Policy: key.NewBinding(
+		key.WithKeys("P"),
+		key.WithHelp("P", "policy"),
+	),
+	Lifecycle: key.NewBinding(
+		key.WithKeys("L"),
+		key.WithHelp("L", "lifecycle"),
+	),
+	Format: key.NewBinding(
+		key.WithKeys("f"),
+		key.WithHelp("f", "yaml/json"),
+	),
+	Simulate: key.NewBinding(
+		key.WithKeys("s"),
+		key.WithHelp("s", "simulate"),
+	),
+	Versioning: key.NewBinding(
+		key.WithKeys("v"),
+		key.WithHelp("v", "toggle versioning"),
+	),
+	Usage: key.NewBinding(
+		key.WithKeys("D"),
+		key.WithHelp("D", "disk usage"),
+	),
+	Sort: key.NewBinding(
+		key.WithKeys("s"),
+		key.WithHelp("s", "sort"),
+	),
+	Search: key.NewBinding(
+		key.WithKeys("/"),
+		key.WithHelp("/", "filter"),
+	),
+	Find: key.NewBinding(
+		key.WithKeys("ctrl+p"),
+		key.WithHelp("ctrl+p", "find"),
+	),
+	Grep: key.NewBinding(
+		key.WithKeys("G"),
+		key.WithHelp("G", "grep"),
+	),
+	Sync: key.NewBinding(
+		key.WithKeys("ctrl+s"),
+		key.WithHelp("ctrl+s", "sync"),
+	),
+	Reverse: key.NewBinding(
+		key.WithKeys("o"),
+		key.WithHelp("o", "reverse"),
+	),
+	DirsFirst: key.NewBinding(
+		key.WithKeys("g"),
+		key.WithHelp("g", "dirs first"),
+	),
+	NextField: key.NewBinding(
+		key.WithKeys("tab"),
+		key.WithHelp("tab", "next field"),
+	),
+	PrevField: key.NewBinding(
+		key.WithKeys("shift+tab"),
+		key.WithHelp("shift+tab", "previous field"),
+	),
+	NextTab: key.NewBinding(
+		key.WithKeys("tab"),
+		key.WithHelp("tab/h/l", "switch tab"),
+	),
 	Back: key.NewBinding(
 		key.WithKeys("esc"),
 		key.WithHelp("esc", "back"),
@@ -117,6 +277,7 @@ var Keymap = keymap{
 	),
 	Edit: key.NewBinding(
 		key.WithKeys("e"),
+		key.WithHelp("e", "edit"),
 	),
 	Next: key.NewBinding(
 		key.WithKeys("l"),
@@ -132,6 +293,50 @@ var Keymap = keymap{
 	),
 }
 
+// SetReadOnly disables the bindings of every action that modifies S3, which
+// also hides them from the help. Edit and Delete also save or remove tags,
+// lifecycle rules, policies and bucket settings, and purge the trash.
+func (k *keymap) SetReadOnly(readOnly bool) {
+	k.Create.SetEnabled(!readOnly)
+	k.Upload.SetEnabled(!readOnly)
+	k.Edit.SetEnabled(!readOnly)
+	k.Rename.SetEnabled(!readOnly)
+	k.Delete.SetEnabled(!readOnly)
+	k.Undo.SetEnabled(!readOnly)
+	k.Restore.SetEnabled(!readOnly)
+	k.Versioning.SetEnabled(!readOnly)
+	k.StorageClass.SetEnabled(!readOnly)
+	k.Sync.SetEnabled(!readOnly)
+}
+
+// ShortHelp renders the help line for the enabled bindings, after the hint
+// for the navigation keys every view shares. It wraps to the window so no
+// line is wider than the terminal.
+func ShortHelp(bindings ...key.Binding) string {
+	parts := []string{"↑/↓ h/j/k/l: navigate"}
+	for _, b := range bindings {
+		if b.Enabled() {
+			parts = append(parts, fmt.Sprintf("%s: %s", b.Help().Key, b.Help().Desc))
+		}
+	}
+	width := WindowSize.Width - 6
+	var lines []string
+	line := ""
+	for _, part := range parts {
+		switch {
+		case line == "":
+			line = part
+		case width > 0 && lipgloss.Width(line+" • "+part) > width:
+			lines = append(lines, line)
+			line = part
+		default:
+			line += " • " + part
+		}
+	}
+	lines = append(lines, line)
+	return "\n" + HelpStyle(" "+strings.Join(lines, "\n ")) + "\n"
+}
+
 func strptr(s string) *string {
 	return &s
 }
@@ -0,0 +1,119 @@
+package tui
+
+import (
+	"fmt"
+
+	"github.com/Wondrous27/s3-tui/object"
+	"github.com/Wondrous27/s3-tui/tui/constants"
+	"github.com/Wondrous27/s3-tui/utils"
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type copyFormat struct {
+	name  string
+	value string
+}
+
+type copiedMsg struct {
+	value string
+	err   error
+}
+
+// CopyMenu copies the location of an object or prefix to the clipboard in
+// the format picked from a menu.
+type CopyMenu struct {
+	formats  []copyFormat
+	cursor   int
+	copied   string
+	error    string
+	next     func() (tea.Model, tea.Cmd)
+	quitting bool
+}
+
+// InitCopyMenu offers the formats of key, a prefix when isDir is set
+func InitCopyMenu(bucketName, key string, isDir bool, next func() (tea.Model, tea.Cmd)) (tea.Model, tea.Cmd) {
+	if isDir && key != "" {
+		key += "/"
+	}
+	return CopyMenu{
+		formats: []copyFormat{
+			{"S3 URI", object.URI(bucketName, key)},
+			{"ARN", constants.Or.ARN(bucketName, key)},
+			{"HTTPS URL", constants.Or.ObjectURL(bucketName, key)},
+			{"Key", key},
+		},
+		next: next,
+	}, nil
+}
+
+func (m CopyMenu) Init() tea.Cmd {
+	return nil
+}
+
+func (m CopyMenu) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case copiedMsg:
+		m.copied, m.error = "", ""
+		if msg.err != nil {
+			m.error = msg.err.Error()
+			return m, nil
+		}
+		m.copied = msg.value
+		return m, nil
+
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, constants.Keymap.Quit):
+			m.quitting = true
+			return m, tea.Quit
+
+		case key.Matches(msg, constants.Keymap.Back), key.Matches(msg, constants.Keymap.Prev):
+			return m.next()
+
+		case key.Matches(msg, constants.Keymap.Up):
+			m.cursor = (m.cursor - 1 + len(m.formats)) % len(m.formats)
+
+		case key.Matches(msg, constants.Keymap.Down):
+			m.cursor = (m.cursor + 1) % len(m.formats)
+
+		case key.Matches(msg, constants.Keymap.Enter), key.Matches(msg, constants.Keymap.Next):
+			return m, copyCmd(m.formats[m.cursor].value)
+		}
+	}
+	return m, nil
+}
+
+func (m CopyMenu) View() string {
+	if m.quitting {
+		return ""
+	}
+	rows := []string{"\n", "Copy to the clipboard", ""}
+	for i, format := range m.formats {
+		cursor := "  "
+		name := fmt.Sprintf("%-10s", format.name)
+		if i == m.cursor {
+			cursor = "> "
+			name = constants.SelectedStyle(name)
+		}
+		rows = append(rows, cursor+name+" "+format.value)
+	}
+	if m.copied != "" {
+		rows = append(rows, "", constants.AlertStyle("copied "+m.copied))
+	}
+	rows = append(rows,
+		constants.ShortHelp(constants.Keymap.Enter, constants.Keymap.Back, constants.Keymap.Quit),
+		constants.ErrStyle(m.error),
+	)
+	return constants.DocStyle.Render(lipgloss.JoinVertical(lipgloss.Left, rows...))
+}
+
+func copyCmd(value string) tea.Cmd {
+	return func() tea.Msg {
+		if err := utils.CopyToClipboard(value); err != nil {
+			return copiedMsg{err: fmt.Errorf("could not copy to the clipboard: %v", err)}
+		}
+		return copiedMsg{value: value}
+	}
+}
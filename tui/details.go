@@ -0,0 +1,457 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Wondrous27/s3-tui/bucket"
+	"github.com/Wondrous27/s3-tui/tui/constants"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// detailTab is one bucket configuration of the detail screen, edited as YAML
+type detailTab struct {
+	name string
+	get  func(bucketName string) (any, error)
+	// put saves an edited document, an empty one removes the setting. mfa
+	// is the MFA device serial and code when needsMFA asked for them.
+	put func(bucketName, data, mfa string) error
+	// template is offered for editing when the setting is not configured
+	template any
+	// confirm, when set, asks before saving a change from current to data,
+	// unless it returns no question
+	confirm func(bucketName, current, data string) string
+	// needsMFA reports whether saving data needs an MFA code
+	needsMFA func(current, data string) bool
+}
+
+// settingTab adapts the typed repository methods of a setting to a tab
+func settingTab[T any](name string, get func(string) (T, error), put func(string, T) error, template T) detailTab {
+	return detailTab{
+		name: name,
+		get:  func(bucketName string) (any, error) { return get(bucketName) },
+		put: func(bucketName, data, _ string) error {
+			v, err := decodeSetting[T](data)
+			if err != nil {
+				return err
+			}
+			return put(bucketName, v)
+		},
+		template: template,
+	}
+}
+
+// decodeSetting reads a tab document, an empty one is the zero value
+func decodeSetting[T any](data string) (T, error) {
+	var v T
+	if err := bucket.DecodeDocument(data, "yaml", &v); err != nil && !errors.Is(err, io.EOF) {
+		return v, err
+	}
+	return v, nil
+}
+
+// versioningTab asks before changing versioning, and for an MFA code when
+// MFA delete is or becomes enabled.
+func versioningTab() detailTab {
+	tab := settingTab("Versioning", constants.Br.GetBucketVersioning, nil, &bucket.Versioning{
+		Status: "Enabled",
+	})
+	tab.put = func(bucketName, data, mfa string) error {
+		v, err := decodeSetting[*bucket.Versioning](data)
+		if err != nil {
+			return err
+		}
+		return constants.Br.PutBucketVersioning(bucketName, v, mfa)
+	}
+	tab.confirm = func(bucketName, _, data string) string {
+		v, err := decodeSetting[*bucket.Versioning](data)
+		if err != nil || v == nil {
+			return fmt.Sprintf("Are you sure you want to change the versioning of %s?", bucketName)
+		}
+		msg := fmt.Sprintf("Are you sure you want to set the versioning of %s to %s?", bucketName, v.Status)
+		if v.Status == "Enabled" {
+			msg += "\nOnce enabled, versioning can only be suspended, not turned off."
+		}
+		return msg
+	}
+	tab.needsMFA = func(current, data string) bool {
+		before, _ := decodeSetting[*bucket.Versioning](current)
+		after, _ := decodeSetting[*bucket.Versioning](data)
+		if before == nil {
+			before = &bucket.Versioning{}
+		}
+		if after == nil {
+			after = &bucket.Versioning{}
+		}
+		return before.MFADelete == "Enabled" || after.MFADelete != "" && after.MFADelete != before.MFADelete
+	}
+	return tab
+}
+
+// objectLockTab asks before changing the default retention, which applies
+// to every new object.
+func objectLockTab() detailTab {
+	tab := settingTab("Object lock", constants.Br.GetObjectLock, constants.Br.PutObjectLock, &bucket.ObjectLock{
+		Enabled: true,
+		Mode:    "GOVERNANCE",
+		Days:    30,
+	})
+	tab.confirm = func(bucketName, _, data string) string {
+		lock, err := decodeSetting[*bucket.ObjectLock](data)
+		if err != nil || lock == nil {
+			return fmt.Sprintf("Are you sure you want to change the object lock of %s?", bucketName)
+		}
+		if lock.Mode == "" {
+			return fmt.Sprintf("Are you sure you want to remove the default retention of %s?", bucketName)
+		}
+		period := fmt.Sprintf("%d days", lock.Days)
+		if lock.Years > 0 {
+			period = fmt.Sprintf("%d years", lock.Years)
+		}
+		msg := fmt.Sprintf("Are you sure you want to lock every new object of %s in %s mode for %s?", bucketName, lock.Mode, period)
+		if lock.Mode == "COMPLIANCE" {
+			msg += "\nNo one, not even the root user, can delete them before then."
+		}
+		return msg
+	}
+	return tab
+}
+
+func detailTabs() []detailTab {
+	return []detailTab{
+		settingTab("CORS", constants.Br.GetBucketCors, constants.Br.PutBucketCors, []bucket.CORSRule{{
+			AllowedOrigins: []string{"https://example.com"},
+			AllowedMethods: []string{"GET", "HEAD"},
+			AllowedHeaders: []string{"*"},
+			MaxAgeSeconds:  3000,
+		}}),
+		settingTab("Website", constants.Br.GetBucketWebsite, constants.Br.PutBucketWebsite, &bucket.Website{
+			IndexDocument: "index.html",
+			ErrorDocument: "error.html",
+		}),
+		settingTab("Tags", constants.Br.GetBucketTags, constants.Br.PutBucketTags, map[string]string{"team": ""}),
+		versioningTab(),
+		objectLockTab(),
+		confirmRemoval(settingTab("Encryption", constants.Br.GetBucketEncryption, constants.Br.PutBucketEncryption, &bucket.Encryption{
+			SSEAlgorithm: "AES256",
+		}), "default encryption"),
+		confirmRemoval(settingTab("Public access block", constants.Br.GetPublicAccessBlock, constants.Br.PutPublicAccessBlock, &bucket.PublicAccessBlock{
+			BlockPublicAcls:       true,
+			IgnorePublicAcls:      true,
+			BlockPublicPolicy:     true,
+			RestrictPublicBuckets: true,
+		}), "public access block"),
+	}
+}
+
+// confirmRemoval asks before an empty document removes a setting that
+// protects the bucket
+func confirmRemoval(tab detailTab, what string) detailTab {
+	tab.confirm = func(bucketName, current, data string) string {
+		if v, err := decodeSetting[any](data); err != nil || v != nil || current == "" {
+			return ""
+		}
+		return fmt.Sprintf("Are you sure you want to remove the %s of %s?", what, bucketName)
+	}
+	return tab
+}
+
+type loadedDetailMsg struct {
+	tab     int
+	content string
+	err     error
+}
+
+// Details shows the configurations of a bucket in tabs, each editable in
+// $EDITOR.
+type Details struct {
+	bucketName string
+	tabs       []detailTab
+	tab        int
+	// contents are the YAML documents of the tabs, empty when not configured
+	contents []string
+	errors   []string
+	// drafts keep edits that failed to save, so they are not lost
+	drafts map[int]string
+	// offered is the document given to the editor, saved only once changed
+	offered string
+	// pending is the document waiting for confirmation, and the MFA code it
+	// needs
+	pending  string
+	mfa      textinput.Model
+	mode     mode
+	isSure   bool
+	viewport viewport.Model
+	next     func() (tea.Model, tea.Cmd)
+	quitting bool
+}
+
+func InitDetails(bucketName string, next func() (tea.Model, tea.Cmd)) (tea.Model, tea.Cmd) {
+	mfa := textinput.New()
+	mfa.Prompt = "MFA serial and code: "
+	mfa.Placeholder = "arn:aws:iam::123456789012:mfa/root 123456"
+
+	m := Details{
+		bucketName: bucketName,
+		tabs:       detailTabs(),
+		drafts:     map[int]string{},
+		mfa:        mfa,
+		next:       next,
+	}
+	m.contents = make([]string, len(m.tabs))
+	m.errors = make([]string, len(m.tabs))
+	top, right, bottom, left := constants.DocStyle.GetMargin()
+	m.viewport = viewport.New(constants.WindowSize.Width-left-right, constants.WindowSize.Height-top-bottom-10)
+
+	cmds := make([]tea.Cmd, 0, len(m.tabs))
+	for i := range m.tabs {
+		m.errors[i] = "loading..."
+		cmds = append(cmds, m.loadTabCmd(i))
+	}
+	return m, tea.Batch(cmds...)
+}
+
+func (m Details) Init() tea.Cmd {
+	return nil
+}
+
+func (m Details) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		constants.WindowSize = msg
+		top, right, bottom, left := constants.DocStyle.GetMargin()
+		m.viewport = viewport.New(msg.Width-left-right, msg.Height-top-bottom-10)
+		m.setViewportContent()
+
+	case loadedDetailMsg:
+		m.errors[msg.tab] = ""
+		if msg.err != nil {
+			m.errors[msg.tab] = msg.err.Error()
+		} else {
+			m.contents[msg.tab] = msg.content
+			delete(m.drafts, msg.tab)
+		}
+		m.setViewportContent()
+		return m, nil
+
+	case editorFinishedMsg:
+		if msg.err != nil {
+			m.errors[m.tab] = msg.err.Error()
+			return m, nil
+		}
+		if msg.content == m.offered {
+			return m, nil
+		}
+		m.drafts[m.tab] = msg.content
+		return m.save(msg.content)
+
+	case tea.KeyMsg:
+		if m.mfa.Focused() {
+			switch {
+			case key.Matches(msg, constants.Keymap.Back):
+				m.mfa.Blur()
+				return m, nil
+
+			case key.Matches(msg, constants.Keymap.Enter):
+				if len(strings.Fields(m.mfa.Value())) != 2 {
+					m.errors[m.tab] = "enter the MFA device serial and a code, separated by a space"
+					return m, nil
+				}
+				m.mfa.Blur()
+				m.errors[m.tab] = ""
+				m.isSure = false
+				m.mode = del
+				return m, nil
+			}
+			m.mfa, cmd = m.mfa.Update(msg)
+			return m, cmd
+		}
+
+		if m.mode == del {
+			switch {
+			case key.Matches(msg, constants.Keymap.Quit):
+				m.quitting = true
+				return m, tea.Quit
+
+			case key.Matches(msg, constants.Keymap.Enter):
+				m.mode = nav
+				if m.isSure {
+					return m, m.putTabCmd(m.tab, m.pending, strings.TrimSpace(m.mfa.Value()))
+				}
+
+			case key.Matches(msg, constants.Keymap.Next), key.Matches(msg, constants.Keymap.Prev):
+				m.isSure = !m.isSure
+			}
+			return m, nil
+		}
+
+		switch {
+		case key.Matches(msg, constants.Keymap.Quit):
+			m.quitting = true
+			return m, tea.Quit
+
+		case key.Matches(msg, constants.Keymap.Back):
+			return m.next()
+
+		case key.Matches(msg, constants.Keymap.NextTab), key.Matches(msg, constants.Keymap.Next):
+			m.tab = (m.tab + 1) % len(m.tabs)
+			m.setViewportContent()
+			return m, nil
+
+		case key.Matches(msg, constants.Keymap.PrevField), key.Matches(msg, constants.Keymap.Prev):
+			m.tab = (m.tab - 1 + len(m.tabs)) % len(m.tabs)
+			m.setViewportContent()
+			return m, nil
+
+		case key.Matches(msg, constants.Keymap.Edit):
+			content, ok := m.drafts[m.tab]
+			if !ok {
+				content = m.contents[m.tab]
+			}
+			if content == "" {
+				var err error
+				if content, err = bucket.EncodeDocument(m.tabs[m.tab].template, "yaml"); err != nil {
+					m.errors[m.tab] = err.Error()
+					return m, nil
+				}
+			}
+			m.offered = content
+			return m, openEditorCmd(content, ".yaml")
+
+		case key.Matches(msg, constants.Keymap.Versioning):
+			if m.tabs[m.tab].name != "Versioning" {
+				return m, nil
+			}
+			v, err := decodeSetting[*bucket.Versioning](m.contents[m.tab])
+			if err != nil {
+				m.errors[m.tab] = err.Error()
+				return m, nil
+			}
+			toggled := bucket.Versioning{Status: "Enabled"}
+			if v != nil {
+				toggled.MFADelete = v.MFADelete
+				if v.Status == "Enabled" {
+					toggled.Status = "Suspended"
+				}
+			}
+			data, err := bucket.EncodeDocument(toggled, "yaml")
+			if err != nil {
+				m.errors[m.tab] = err.Error()
+				return m, nil
+			}
+			return m.save(data)
+		}
+	}
+	m.viewport, cmd = m.viewport.Update(msg)
+	return m, cmd
+}
+
+// save puts data on the current tab, after asking for an MFA code and a
+// confirmation when the tab needs them.
+func (m Details) save(data string) (tea.Model, tea.Cmd) {
+	tab := m.tabs[m.tab]
+	if tab.confirm == nil || tab.confirm(m.bucketName, m.contents[m.tab], data) == "" {
+		return m, m.putTabCmd(m.tab, data, "")
+	}
+	m.pending = data
+	if tab.needsMFA != nil && tab.needsMFA(m.contents[m.tab], data) {
+		if m.mfa.Value() == "" && constants.Session != nil && constants.Session.MFASerial != "" {
+			m.mfa.SetValue(constants.Session.MFASerial + " ")
+		}
+		m.mfa.CursorEnd()
+		return m, m.mfa.Focus()
+	}
+	m.mfa.SetValue("")
+	m.isSure = false
+	m.mode = del
+	return m, nil
+}
+
+func (m *Details) setViewportContent() {
+	content := m.contents[m.tab]
+	if content == "" {
+		m.viewport.SetContent(constants.AlertStyle("Not configured, press e to set it up."))
+		return
+	}
+	str, err := renderFile("setting.yaml", content)
+	if err != nil {
+		m.errors[m.tab] = "could not render the setting"
+		return
+	}
+	str, _ = constants.FormatLineNumber(str, true)
+	m.viewport.SetContent(str)
+}
+
+func (m Details) View() string {
+	if m.quitting {
+		return ""
+	}
+	if m.mode == del {
+		return confirmationDialog(m.tabs[m.tab].confirm(m.bucketName, m.contents[m.tab], m.pending), m.isSure)
+	}
+	names := make([]string, 0, len(m.tabs))
+	for i, tab := range m.tabs {
+		name := " " + tab.name + " "
+		if i == m.tab {
+			name = constants.SelectedStyle(name)
+		}
+		names = append(names, name)
+	}
+	prompt := ""
+	if m.mfa.Focused() {
+		prompt = m.mfa.View()
+	}
+	bindings := []key.Binding{constants.Keymap.NextTab, constants.Keymap.Back, constants.Keymap.Edit}
+	if m.tabs[m.tab].name == "Versioning" {
+		bindings = append(bindings, constants.Keymap.Versioning)
+	}
+	bindings = append(bindings, constants.Keymap.Quit)
+	return constants.DocStyle.Render(lipgloss.JoinVertical(
+		lipgloss.Left,
+		"\n",
+		fmt.Sprintf("details of %s", m.bucketName),
+		"",
+		strings.Join(names, "│"),
+		"",
+		m.viewport.View(),
+		prompt,
+		constants.ShortHelp(bindings...),
+		constants.ErrStyle(m.errors[m.tab]),
+	))
+}
+
+// loadTabCmd fetches the setting of a tab, the tabs load concurrently
+func (m Details) loadTabCmd(tab int) tea.Cmd {
+	return func() tea.Msg {
+		v, err := m.tabs[tab].get(m.bucketName)
+		if err != nil {
+			return loadedDetailMsg{tab: tab, err: fmt.Errorf("[loadTabCmd] %v", err)}
+		}
+		content, err := bucket.EncodeDocument(v, "yaml")
+		if err != nil {
+			return loadedDetailMsg{tab: tab, err: err}
+		}
+		// Unset settings encode as empty YAML values
+		switch strings.TrimSpace(content) {
+		case "null", "[]", "{}":
+			content = ""
+		}
+		return loadedDetailMsg{tab: tab, content: content}
+	}
+}
+
+func (m Details) putTabCmd(tab int, data, mfa string) tea.Cmd {
+	return func() tea.Msg {
+		if err := m.tabs[tab].put(m.bucketName, data, mfa); err != nil {
+			return loadedDetailMsg{tab: tab, err: fmt.Errorf("not saved, press e to fix it: %v", err)}
+		}
+		return m.loadTabCmd(tab)()
+	}
+}
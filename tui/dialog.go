@@ -0,0 +1,37 @@
+package tui
+
+import (
+	"github.com/Wondrous27/s3-tui/tui/constants"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// confirmationDialog renders a Yes/No question with the answer selected by
+// isSure highlighted. Models toggle isSure with h/l and act on enter.
+func confirmationDialog(msg string, isSure bool) string {
+	buttonStyle := map[bool]lipgloss.Style{
+		true:  constants.ActiveButtonStyle,
+		false: constants.ButtonStyle,
+	}
+	okb := buttonStyle[isSure]
+	cb := buttonStyle[!isSure]
+
+	okButton := okb.Render("Yes")
+	cancelButton := cb.Render("No")
+
+	question := lipgloss.NewStyle().Width(60).Align(lipgloss.Center).Render(msg)
+	buttons := lipgloss.JoinHorizontal(lipgloss.Top, okButton, cancelButton)
+	ui := lipgloss.JoinVertical(lipgloss.Center, question, buttons)
+
+	const (
+		width = 96
+	)
+
+	dialog := lipgloss.Place(width, 9,
+		lipgloss.Center, lipgloss.Center,
+		constants.DialogBoxStyle.Render(ui),
+		lipgloss.WithWhitespaceChars(""),
+		lipgloss.WithWhitespaceForeground(constants.Subtle),
+	)
+
+	return dialog
+}
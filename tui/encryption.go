@@ -0,0 +1,283 @@
+package tui
+
+import (
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/Wondrous27/s3-tui/object"
+	"github.com/Wondrous27/s3-tui/tui/constants"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Fields of the encryption form, in tab order
+const (
+	encryptionModeField = iota
+	encryptionKeyField
+)
+
+type kmsKeysMsg struct {
+	keys []string
+	err  error
+}
+
+// EncryptionForm picks the server-side encryption of an object about to be
+// written: the bucket default, SSE-S3, SSE-KMS with a key from KMS or SSE-C
+// with a key read from a file.
+type EncryptionForm struct {
+	bucketName string
+	key        string
+	mode       int
+	// kmsKeys are offered for SSE-KMS, the first is the AWS managed key
+	// unless the encryption rules limit them
+	kmsKeys []string
+	kmsKey  int
+	// keyFile asks for the SSE-C key file
+	keyFile textinput.Model
+	// clientKey is the key of the client-side encryption the rules ask for,
+	// which cannot be changed here
+	clientKey string
+	focus     int
+	error     string
+	done      func(object.Encryption) (tea.Model, tea.Cmd)
+	next      func() (tea.Model, tea.Cmd)
+	quitting  bool
+}
+
+// InitEncryption starts from the encryption the rules ask for at key, and
+// calls done with the one picked
+func InitEncryption(bucketName, key string, done func(object.Encryption) (tea.Model, tea.Cmd), next func() (tea.Model, tea.Cmd)) (tea.Model, tea.Cmd) {
+	keyFile := textinput.New()
+	keyFile.Prompt = "$ "
+	keyFile.Placeholder = "path to a 256 bit key, raw or base64"
+	keyFile.Width = 50
+
+	m := EncryptionForm{bucketName: bucketName, key: key, keyFile: keyFile, done: done, next: next}
+	enc := constants.Or.DefaultEncryption(bucketName, key)
+	m.mode = max(slices.Index(object.EncryptionModes, enc.Mode), 0)
+	m.clientKey = enc.ClientKey
+
+	if allowed := constants.Or.AllowedKMSKeys(bucketName, key); len(allowed) > 0 {
+		m.kmsKeys = allowed
+		return m, nil
+	}
+	m.kmsKeys = []string{""}
+	return m, listKMSKeysCmd
+}
+
+func (m EncryptionForm) Init() tea.Cmd {
+	return nil
+}
+
+func (m EncryptionForm) modeName() string {
+	return object.EncryptionModes[m.mode]
+}
+
+// fields returns how many form fields the selected mode has
+func (m EncryptionForm) fields() int {
+	switch m.modeName() {
+	case object.SSEKMS, object.SSEC:
+		return encryptionKeyField + 1
+	}
+	return encryptionModeField + 1
+}
+
+func (m *EncryptionForm) setFocus(focus int) tea.Cmd {
+	m.focus = (focus + m.fields()) % m.fields()
+	if m.focus == encryptionKeyField && m.modeName() == object.SSEC {
+		return m.keyFile.Focus()
+	}
+	m.keyFile.Blur()
+	return nil
+}
+
+func (m EncryptionForm) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	switch msg := msg.(type) {
+	case kmsKeysMsg:
+		if msg.err != nil {
+			m.error = msg.err.Error()
+			return m, nil
+		}
+		m.kmsKeys = append([]string{""}, msg.keys...)
+		return m, nil
+
+	case tea.KeyMsg:
+		switch {
+		case msg.Type == tea.KeyCtrlC:
+			m.quitting = true
+			return m, tea.Quit
+
+		case key.Matches(msg, constants.Keymap.Back):
+			return m.next()
+
+		case key.Matches(msg, constants.Keymap.NextField):
+			return m, m.setFocus(m.focus + 1)
+
+		case key.Matches(msg, constants.Keymap.PrevField):
+			return m, m.setFocus(m.focus - 1)
+
+		case key.Matches(msg, constants.Keymap.Enter):
+			enc := object.Encryption{Mode: m.modeName(), ClientKey: m.clientKey}
+			switch enc.Mode {
+			case object.SSEKMS:
+				enc.KMSKeyID = m.kmsKeys[m.kmsKey]
+			case object.SSEC:
+				customerKey, err := object.ReadCustomerKey(m.keyFile.Value())
+				if err != nil {
+					m.error = err.Error()
+					return m, nil
+				}
+				enc.CustomerKey = customerKey
+			}
+			if err := constants.Or.CheckEncryption(m.bucketName, m.key, enc); err != nil {
+				m.error = err.Error()
+				return m, nil
+			}
+			return m.done(enc)
+		}
+
+		if m.focus == encryptionModeField || m.modeName() == object.SSEKMS {
+			step := 0
+			switch {
+			case key.Matches(msg, constants.Keymap.Next), msg.Type == tea.KeyRight:
+				step = 1
+			case key.Matches(msg, constants.Keymap.Prev), msg.Type == tea.KeyLeft:
+				step = -1
+			}
+			if m.focus == encryptionModeField {
+				m.mode = (m.mode + step + len(object.EncryptionModes)) % len(object.EncryptionModes)
+			} else {
+				m.kmsKey = (m.kmsKey + step + len(m.kmsKeys)) % len(m.kmsKeys)
+			}
+			m.error = ""
+			return m, nil
+		}
+	}
+	m.keyFile, cmd = m.keyFile.Update(msg)
+	return m, cmd
+}
+
+func (m EncryptionForm) View() string {
+	if m.quitting {
+		return ""
+	}
+	label := func(field int, name string) string {
+		name = fmt.Sprintf("%-16s", name)
+		if field == m.focus {
+			return constants.SelectedStyle(name)
+		}
+		return name
+	}
+	mode := m.modeName()
+	if mode == "" {
+		mode = "bucket default"
+	}
+	rows := []string{
+		"\n",
+		fmt.Sprintf("Encryption of s3://%s/%s", m.bucketName, m.key),
+		"",
+		label(encryptionModeField, "Mode") + " < " + mode + " >",
+	}
+	switch m.modeName() {
+	case object.SSEKMS:
+		kmsKey := m.kmsKeys[m.kmsKey]
+		if kmsKey == "" {
+			kmsKey = "aws/s3 (AWS managed)"
+		}
+		rows = append(rows, label(encryptionKeyField, "KMS key")+" < "+kmsKey+" >")
+	case object.SSEC:
+		rows = append(rows, label(encryptionKeyField, "Customer key")+" "+m.keyFile.View())
+	}
+	if m.clientKey != "" {
+		rows = append(rows, fmt.Sprintf("%-16s", "Client-side")+" AES-GCM envelope, key "+m.clientKey)
+	}
+	rows = append(rows,
+		constants.HelpStyle("\n tab: next field • ←/→ h/l: change • enter: continue • esc: back\n"),
+		constants.ErrStyle(m.error),
+	)
+	return constants.DocStyle.Render(lipgloss.JoinVertical(lipgloss.Left, rows...))
+}
+
+func listKMSKeysCmd() tea.Msg {
+	keys, err := constants.Or.ListKMSKeys()
+	if err != nil {
+		log.Printf("[listKMSKeysCmd] %v", err)
+		return kmsKeysMsg{err: fmt.Errorf("could not list the KMS keys, only the AWS managed key is offered")}
+	}
+	return kmsKeysMsg{keys: keys}
+}
+
+// CustomerKey asks for the key file of an object encrypted with SSE-C and
+// opens the object with it
+type CustomerKey struct {
+	bucketName string
+	key        string
+	keyFile    textinput.Model
+	error      string
+	quitting   bool
+}
+
+func InitCustomerKey(bucketName, key string) (tea.Model, tea.Cmd) {
+	keyFile := textinput.New()
+	keyFile.Prompt = "$ "
+	keyFile.Placeholder = "path to a 256 bit key, raw or base64"
+	keyFile.Width = 50
+	cmd := keyFile.Focus()
+	return CustomerKey{bucketName: bucketName, key: key, keyFile: keyFile}, cmd
+}
+
+func (m CustomerKey) Init() tea.Cmd {
+	return nil
+}
+
+func (m CustomerKey) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	if msg, ok := msg.(errMsg); ok {
+		m.error = msg.Error()
+		return m, nil
+	}
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch {
+		case msg.Type == tea.KeyCtrlC:
+			m.quitting = true
+			return m, tea.Quit
+
+		case key.Matches(msg, constants.Keymap.Back):
+			return openTree(m.bucketName, m.key, m)
+
+		case key.Matches(msg, constants.Keymap.Enter):
+			customerKey, err := object.ReadCustomerKey(m.keyFile.Value())
+			if err != nil {
+				m.error = err.Error()
+				return m, nil
+			}
+			obj, err := constants.Or.GetEncryptedObject(m.bucketName, m.key, object.Encryption{Mode: object.SSEC, CustomerKey: customerKey})
+			if err != nil {
+				m.error = err.Error()
+				return m, nil
+			}
+			return newObjectView(m.bucketName, obj)
+		}
+	}
+	m.keyFile, cmd = m.keyFile.Update(msg)
+	return m, cmd
+}
+
+func (m CustomerKey) View() string {
+	if m.quitting {
+		return ""
+	}
+	return constants.DocStyle.Render(lipgloss.JoinVertical(
+		lipgloss.Left,
+		"\n",
+		fmt.Sprintf("s3://%s/%s is encrypted with a customer key (SSE-C)", m.bucketName, m.key),
+		"",
+		"Customer key file "+m.keyFile.View(),
+		constants.HelpStyle("\n enter: open • esc: back\n"),
+		constants.ErrStyle(m.error),
+	))
+}
@@ -0,0 +1,236 @@
+package tui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Wondrous27/s3-tui/tree"
+	"github.com/Wondrous27/s3-tui/tui/constants"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/sahilm/fuzzy"
+)
+
+// matchStyle highlights the characters of a key the query matched
+var matchStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("212")).Render
+
+type finderKeysMsg struct {
+	keys []string
+	err  error
+}
+
+// Finder fuzzy searches every key of a bucket, and the directories above
+// them, and opens the one picked.
+type Finder struct {
+	bucketName string
+	query      textinput.Model
+	// keys are the candidates, directories end with a slash
+	keys     []string
+	matches  fuzzy.Matches
+	cursor   int
+	loading  bool
+	error    string
+	next     func() (tea.Model, tea.Cmd)
+	quitting bool
+}
+
+// InitFinder searches the keys of bucketName, listing them first when keys
+// is nil
+func InitFinder(bucketName string, keys []string, next func() (tea.Model, tea.Cmd)) (tea.Model, tea.Cmd) {
+	query := textinput.New()
+	query.Prompt = "find: "
+	query.Placeholder = "part of a key"
+	query.Width = 50
+	cmd := query.Focus()
+
+	m := Finder{bucketName: bucketName, query: query, next: next}
+	if keys == nil {
+		m.loading = true
+		return m, tea.Batch(cmd, m.listKeysCmd())
+	}
+	m.setKeys(keys)
+	return m, cmd
+}
+
+func (m Finder) Init() tea.Cmd {
+	return nil
+}
+
+// treeKeys returns the keys of every object below root
+func treeKeys(root *tree.Node) []string {
+	var keys []string
+	var walk func(*tree.Node)
+	walk = func(n *tree.Node) {
+		for _, child := range n.Children {
+			if child.IsDir {
+				walk(child)
+			} else {
+				keys = append(keys, child.Path())
+			}
+		}
+	}
+	walk(root)
+	return keys
+}
+
+func (m Finder) listKeysCmd() tea.Cmd {
+	bucketName := m.bucketName
+	return func() tea.Msg {
+		objects, err := constants.Or.ListPrefix(bucketName, "")
+		if err != nil {
+			return finderKeysMsg{err: fmt.Errorf("[listKeysCmd] %v", err)}
+		}
+		keys := make([]string, 0, len(objects))
+		for _, obj := range visibleObjects(objects) {
+			keys = append(keys, obj.Key)
+		}
+		return finderKeysMsg{keys: keys}
+	}
+}
+
+// setKeys adds the directories of keys to the candidates
+func (m *Finder) setKeys(keys []string) {
+	dirs := map[string]bool{}
+	for _, key := range keys {
+		for i, c := range key {
+			if c == '/' && !dirs[key[:i+1]] {
+				dirs[key[:i+1]] = true
+				m.keys = append(m.keys, key[:i+1])
+			}
+		}
+	}
+	m.keys = append(m.keys, keys...)
+	slices.Sort(m.keys)
+	m.search()
+}
+
+// search matches the query against the candidates, best matches first
+func (m *Finder) search() {
+	m.cursor = 0
+	if m.query.Value() == "" {
+		m.matches = nil
+		return
+	}
+	m.matches = fuzzy.Find(m.query.Value(), m.keys)
+}
+
+// height is how many results fit above the query
+func (m Finder) height() int {
+	return max(constants.WindowSize.Height-8, 3)
+}
+
+func (m Finder) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		constants.WindowSize = msg
+
+	case errMsg:
+		m.error = msg.Error()
+
+	case finderKeysMsg:
+		m.loading = false
+		if msg.err != nil {
+			m.error = msg.err.Error()
+			return m, nil
+		}
+		m.setKeys(msg.keys)
+		return m, nil
+
+	case tea.KeyMsg:
+		switch {
+		case msg.Type == tea.KeyCtrlC:
+			m.quitting = true
+			return m, tea.Quit
+
+		case key.Matches(msg, constants.Keymap.Back):
+			return m.next()
+
+		case msg.Type == tea.KeyUp, msg.Type == tea.KeyCtrlP:
+			if len(m.matches) > 0 {
+				m.cursor = max(m.cursor-1, 0)
+			}
+			return m, nil
+
+		case msg.Type == tea.KeyDown, msg.Type == tea.KeyCtrlN:
+			if len(m.matches) > 0 {
+				m.cursor = min(m.cursor+1, min(len(m.matches), m.height())-1)
+			}
+			return m, nil
+
+		case key.Matches(msg, constants.Keymap.Enter):
+			if len(m.matches) == 0 {
+				return m, nil
+			}
+			picked := m.matches[m.cursor].Str
+			if strings.HasSuffix(picked, "/") {
+				return openTree(m.bucketName, strings.TrimSuffix(picked, "/"), m)
+			}
+			return InitObject(m.bucketName, picked)
+		}
+	}
+	previous := m.query.Value()
+	m.query, cmd = m.query.Update(msg)
+	if m.query.Value() != previous {
+		m.search()
+	}
+	return m, cmd
+}
+
+func (m Finder) View() string {
+	if m.quitting {
+		return ""
+	}
+	rows := []string{"\n", fmt.Sprintf("Find in s3://%s", m.bucketName), ""}
+	switch {
+	case m.loading:
+		rows = append(rows, constants.AlertStyle("listing the keys..."))
+	case m.query.Value() == "":
+		rows = append(rows, constants.HelpStyle(fmt.Sprintf("%d keys and directories", len(m.keys))))
+	case len(m.matches) == 0:
+		rows = append(rows, "no keys match")
+	default:
+		rows = append(rows, constants.HelpStyle(fmt.Sprintf("%d of %d match", len(m.matches), len(m.keys))))
+	}
+	for i, match := range m.matches {
+		if i == m.height() {
+			break
+		}
+		cursor := "  "
+		if i == m.cursor {
+			cursor = "> "
+		}
+		rows = append(rows, cursor+highlightMatch(match, i == m.cursor))
+	}
+	rows = append(rows,
+		"",
+		m.query.View(),
+		constants.HelpStyle("\n ↑/↓ ctrl+p/ctrl+n: move • enter: open • esc: back\n"),
+		constants.ErrStyle(m.error),
+	)
+	return constants.DocStyle.Render(lipgloss.JoinVertical(lipgloss.Left, rows...))
+}
+
+// highlightMatch renders a key with the characters the query matched
+// highlighted
+func highlightMatch(match fuzzy.Match, selected bool) string {
+	var sb strings.Builder
+	matched := 0
+	for i, c := range match.Str {
+		s := string(c)
+		switch {
+		case matched < len(match.MatchedIndexes) && match.MatchedIndexes[matched] == i:
+			s = matchStyle(s)
+			matched++
+		case selected:
+			s = constants.SelectedStyle(s)
+		case strings.HasSuffix(match.Str, "/"):
+			s = constants.DirStyle(s)
+		}
+		sb.WriteString(s)
+	}
+	return sb.String()
+}
@@ -0,0 +1,311 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/Wondrous27/s3-tui/object"
+	"github.com/Wondrous27/s3-tui/tui/constants"
+	"github.com/Wondrous27/s3-tui/utils"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// grepMaxMatches bounds the matches kept, the others are only counted
+const grepMaxMatches = 10000
+
+// grepProgressMsg redraws the results after an object was searched
+type grepProgressMsg struct{}
+
+// grepState is filled by the search while it runs, shared by the copies of
+// the model so the results keep coming while a match is opened
+type grepState struct {
+	mu       sync.Mutex
+	listing  bool
+	done     bool
+	total    int
+	searched int
+	skipped  int
+	failed   int
+	matched  int
+	matches  []object.GrepMatch
+	// lastErr is the last object that could not be read
+	lastErr error
+	cancel  context.CancelFunc
+}
+
+func (s *grepState) add(result object.GrepResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.searched++
+	switch {
+	case result.Err != nil:
+		s.failed++
+		s.lastErr = result.Err
+	case result.Skipped != "":
+		s.skipped++
+	}
+	s.matched += len(result.Matches)
+	if room := grepMaxMatches - len(s.matches); room > 0 {
+		s.matches = append(s.matches, result.Matches[:min(room, len(result.Matches))]...)
+	}
+}
+
+// Grep searches the content of every object under a prefix for a regular
+// expression, and lists the matching lines as they are found.
+type Grep struct {
+	bucketName string
+	prefix     string
+	pattern    textinput.Model
+	re         *regexp.Regexp
+	state      *grepState
+	cursor     int
+	offset     int
+	error      string
+	next       func() (tea.Model, tea.Cmd)
+	quitting   bool
+}
+
+// InitGrep asks for the pattern to search the objects under the directory
+// prefix for, the whole bucket when it is empty
+func InitGrep(bucketName, prefix string, next func() (tea.Model, tea.Cmd)) (tea.Model, tea.Cmd) {
+	pattern := textinput.New()
+	pattern.Prompt = "grep: "
+	pattern.Placeholder = "regular expression"
+	pattern.Width = 50
+	cmd := pattern.Focus()
+	return Grep{bucketName: bucketName, prefix: strings.Trim(prefix, "/"), pattern: pattern, next: next}, cmd
+}
+
+func (m Grep) Init() tea.Cmd {
+	return nil
+}
+
+// location is the prefix searched as an S3 URI
+func (m Grep) location() string {
+	if m.prefix == "" {
+		return "s3://" + m.bucketName + "/"
+	}
+	return "s3://" + m.bucketName + "/" + m.prefix + "/"
+}
+
+// grepCmd lists the objects under the prefix and searches them, sending a
+// message after every object
+func (m Grep) grepCmd(ctx context.Context) tea.Cmd {
+	bucketName, prefix, re, state := m.bucketName, m.prefix, m.re, m.state
+	if prefix != "" {
+		prefix += "/"
+	}
+	return func() tea.Msg {
+		objects, err := constants.Or.ListPrefix(bucketName, prefix)
+		state.mu.Lock()
+		state.listing = false
+		if err != nil {
+			state.done = true
+			state.mu.Unlock()
+			return errMsg{fmt.Errorf("[grepCmd] %v", err)}
+		}
+		objects = visibleObjects(objects)
+		state.total = len(objects)
+		state.mu.Unlock()
+
+		constants.Or.Grep(ctx, bucketName, objects, re, constants.Grep, func(result object.GrepResult) {
+			state.add(result)
+			constants.P.Send(grepProgressMsg{})
+		})
+		state.mu.Lock()
+		state.done = true
+		state.mu.Unlock()
+		return grepProgressMsg{}
+	}
+}
+
+// stop cancels the search if it is running
+func (m Grep) stop() {
+	if m.state != nil {
+		m.state.cancel()
+	}
+}
+
+// height is how many matches fit between the header and the help
+func (m Grep) height() int {
+	return max(constants.WindowSize.Height-10, 3)
+}
+
+func (m *Grep) moveCursor(cursor int) {
+	m.state.mu.Lock()
+	n := len(m.state.matches)
+	m.state.mu.Unlock()
+	if n == 0 {
+		m.cursor, m.offset = 0, 0
+		return
+	}
+	m.cursor = min(max(cursor, 0), n-1)
+	switch {
+	case m.cursor < m.offset:
+		m.offset = m.cursor
+	case m.cursor >= m.offset+m.height():
+		m.offset = m.cursor - m.height() + 1
+	}
+}
+
+func (m Grep) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		constants.WindowSize = msg
+		return m, nil
+
+	case errMsg:
+		m.error = msg.Error()
+		return m, nil
+
+	case grepProgressMsg:
+		return m, nil
+
+	case tea.KeyMsg:
+		if m.state == nil {
+			return m.updatePattern(msg)
+		}
+		switch {
+		case msg.Type == tea.KeyCtrlC, key.Matches(msg, constants.Keymap.Quit):
+			m.stop()
+			m.quitting = true
+			return m, tea.Quit
+
+		case key.Matches(msg, constants.Keymap.Back):
+			m.stop()
+			return m.next()
+
+		case key.Matches(msg, constants.Keymap.Up):
+			m.moveCursor(m.cursor - 1)
+
+		case key.Matches(msg, constants.Keymap.Down):
+			m.moveCursor(m.cursor + 1)
+
+		case key.Matches(msg, constants.Keymap.Enter), key.Matches(msg, constants.Keymap.Next):
+			m.state.mu.Lock()
+			if m.cursor >= len(m.state.matches) {
+				m.state.mu.Unlock()
+				return m, nil
+			}
+			match := m.state.matches[m.cursor]
+			m.state.mu.Unlock()
+			return InitObjectAt(m.bucketName, match.Key, match.Line, func() (tea.Model, tea.Cmd) { return m, nil })
+		}
+	}
+	return m, nil
+}
+
+// updatePattern edits the pattern until it is searched for
+func (m Grep) updatePattern(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case msg.Type == tea.KeyCtrlC:
+		m.quitting = true
+		return m, tea.Quit
+
+	case key.Matches(msg, constants.Keymap.Back):
+		return m.next()
+
+	case key.Matches(msg, constants.Keymap.Enter):
+		if m.pattern.Value() == "" {
+			return m, nil
+		}
+		re, err := regexp.Compile(m.pattern.Value())
+		if err != nil {
+			m.error = fmt.Sprintf("invalid regular expression: %v", err)
+			return m, nil
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		m.re, m.error = re, ""
+		m.state = &grepState{listing: true, cancel: cancel}
+		m.pattern.Blur()
+		return m, m.grepCmd(ctx)
+	}
+	var cmd tea.Cmd
+	m.pattern, cmd = m.pattern.Update(msg)
+	return m, cmd
+}
+
+func (m Grep) View() string {
+	if m.quitting {
+		return ""
+	}
+	rows := []string{"\n", fmt.Sprintf("Grep %s", m.location()), ""}
+	if m.state == nil {
+		rows = append(rows,
+			m.pattern.View(),
+			constants.HelpStyle(fmt.Sprintf("\n up to %d objects are read at once, the ones over %s are skipped", constants.Grep.Workers, utils.HumanSize(constants.Grep.MaxSize))),
+			constants.HelpStyle("\n enter: search • esc: back\n"),
+			constants.ErrStyle(m.error),
+		)
+		return constants.DocStyle.Render(lipgloss.JoinVertical(lipgloss.Left, rows...))
+	}
+
+	m.state.mu.Lock()
+	defer m.state.mu.Unlock()
+	s := m.state
+	status := fmt.Sprintf("searched %d of %d objects • %d matches", s.searched, s.total, s.matched)
+	if s.skipped > 0 {
+		status += fmt.Sprintf(" • %d skipped", s.skipped)
+	}
+	if s.failed > 0 {
+		status += fmt.Sprintf(" • %d could not be read", s.failed)
+	}
+	switch {
+	case s.listing:
+		status = constants.AlertStyle("listing the objects...")
+	case !s.done:
+		status = constants.AlertStyle(status + "...")
+	default:
+		status = constants.HelpStyle(status)
+	}
+	rows = append(rows, "/"+m.re.String()+"/", status, "")
+	if s.matched > len(s.matches) {
+		rows = append(rows, constants.AlertStyle(fmt.Sprintf("only the first %d matches are listed", len(s.matches))))
+	}
+
+	width := max(constants.WindowSize.Width-8, 20)
+	for i := m.offset; i < len(s.matches) && i < m.offset+m.height(); i++ {
+		match := s.matches[i]
+		cursor := "  "
+		location := fmt.Sprintf("%s:%d:", match.Key, match.Line)
+		text := strings.TrimSpace(match.Text)
+		if room := width - lipgloss.Width(location) - 1; lipgloss.Width(text) > room {
+			text = truncate(text, max(room, 0))
+		}
+		if i == m.cursor {
+			cursor = "> "
+			location = constants.SelectedStyle(location)
+		} else {
+			location = constants.DirStyle(location)
+		}
+		rows = append(rows, cursor+location+" "+text)
+	}
+
+	errLine := m.error
+	if errLine == "" && s.lastErr != nil {
+		errLine = s.lastErr.Error()
+	}
+	rows = append(rows,
+		constants.ShortHelp(constants.Keymap.Enter, constants.Keymap.Back, constants.Keymap.Quit),
+		constants.ErrStyle(errLine),
+	)
+	return constants.DocStyle.Render(lipgloss.JoinVertical(lipgloss.Left, rows...))
+}
+
+// truncate cuts s to width columns, ending it with an ellipsis
+func truncate(s string, width int) string {
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	}
+	if width < 1 {
+		return ""
+	}
+	return string(runes[:width-1]) + "…"
+}
@@ -0,0 +1,113 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Wondrous27/s3-tui/audit"
+	"github.com/Wondrous27/s3-tui/bucket"
+	"github.com/Wondrous27/s3-tui/tui/constants"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type historyItem struct{ audit.Entry }
+
+// Implement the `Item` interface
+func (h historyItem) Title() string {
+	target := "s3://" + h.Bucket
+	if h.Key != "" {
+		target += "/" + h.Key
+	}
+	return fmt.Sprintf("%s %s", h.Operation, target)
+}
+
+func (h historyItem) Description() string {
+	who := h.Profile
+	if h.Account != "" {
+		who += " (" + h.Account + ")"
+	}
+	parts := []string{h.Time.Local().Format(bucket.DDMMYYYYhhmmss), who, h.Result}
+	if h.Size != 0 {
+		parts = append(parts, fmt.Sprintf("%d bytes", h.Size))
+	}
+	if h.VersionID != "" {
+		parts = append(parts, "version "+h.VersionID)
+	}
+	if h.Error != "" {
+		parts = append(parts, h.Error)
+	}
+	return strings.Join(parts, " • ")
+}
+
+func (h historyItem) FilterValue() string { return h.Title() + " " + h.Profile + " " + h.Result }
+
+// History lists the audit log, newest first
+type History struct {
+	list     list.Model
+	next     func() (tea.Model, tea.Cmd)
+	error    string
+	quitting bool
+}
+
+func InitHistory(next func() (tea.Model, tea.Cmd)) (tea.Model, tea.Cmd) {
+	entries, err := constants.Or.Audit.Read()
+	items := make([]list.Item, 0, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		items = append(items, historyItem{entries[i]})
+	}
+
+	m := History{list: list.New(items, list.NewDefaultDelegate(), 8, 8), next: next}
+	if constants.WindowSize.Height != 0 {
+		top, right, bottom, left := constants.DocStyle.GetMargin()
+		m.list.SetSize(constants.WindowSize.Width-left-right, constants.WindowSize.Height-top-bottom-1)
+	}
+	m.list.Title = "history"
+	if err != nil {
+		m.error = err.Error()
+	}
+	m.list.AdditionalShortHelpKeys = func() []key.Binding {
+		return []key.Binding{constants.Keymap.Back}
+	}
+	return m, nil
+}
+
+func (m History) Init() tea.Cmd {
+	return nil
+}
+
+func (m History) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		constants.WindowSize = msg
+		top, right, bottom, left := constants.DocStyle.GetMargin()
+		m.list.SetSize(msg.Width-left-right, msg.Height-top-bottom-1)
+
+	case tea.KeyMsg:
+		if m.list.FilterState() == list.Filtering {
+			break
+		}
+		switch {
+		case key.Matches(msg, constants.Keymap.Quit):
+			m.quitting = true
+			return m, tea.Quit
+
+		case key.Matches(msg, constants.Keymap.Back):
+			if m.list.FilterState() == list.FilterApplied {
+				break
+			}
+			return m.next()
+		}
+	}
+	m.list, cmd = m.list.Update(msg)
+	return m, cmd
+}
+
+func (m History) View() string {
+	if m.quitting {
+		return ""
+	}
+	return constants.DocStyle.Render(m.list.View() + "\n" + constants.ErrStyle(m.error))
+}
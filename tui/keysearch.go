@@ -0,0 +1,428 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/Wondrous27/s3-tui/object"
+	"github.com/Wondrous27/s3-tui/tree"
+	"github.com/Wondrous27/s3-tui/tui/constants"
+	"github.com/Wondrous27/s3-tui/utils"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Fields of the key search form, in tab order
+const (
+	keySearchModeField = iota
+	keySearchPatternField
+	keySearchBucketsField
+)
+
+var keySearchModes = []string{"glob", "regex"}
+
+// keySearchMaxKeys bounds the keys kept, the others are only counted
+const keySearchMaxKeys = 10000
+
+// keySearchProgressMsg redraws the results after a page was listed
+type keySearchProgressMsg struct{}
+
+// keyGroup is what was found in a bucket
+type keyGroup struct {
+	bucket  string
+	region  string
+	objects []object.Object
+	done    bool
+	err     error
+}
+
+// keySearchState is filled by the search while it runs, shared by the copies
+// of the model so the results keep coming while a key is opened
+type keySearchState struct {
+	mu       sync.Mutex
+	groups   []*keyGroup
+	total    int
+	searched int
+	matched  int
+	kept     int
+	finished bool
+	cancel   context.CancelFunc
+}
+
+func (s *keySearchState) add(result object.KeySearchResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var group *keyGroup
+	for _, g := range s.groups {
+		if g.bucket == result.Bucket {
+			group = g
+		}
+	}
+	if group == nil {
+		group = &keyGroup{bucket: result.Bucket}
+		s.groups = append(s.groups, group)
+	}
+	group.region = result.Region
+	s.matched += len(result.Objects)
+	if room := keySearchMaxKeys - s.kept; room > 0 {
+		kept := result.Objects[:min(room, len(result.Objects))]
+		group.objects = append(group.objects, kept...)
+		s.kept += len(kept)
+	}
+	if result.Done {
+		group.done, group.err = true, result.Err
+		s.searched++
+	}
+}
+
+// keySearchRow is a line of the results, a bucket or one of its keys
+type keySearchRow struct {
+	group *keyGroup
+	// object is the index of the key in the group, -1 on the bucket line
+	object int
+}
+
+// KeySearch finds the keys matching a glob or a regular expression across
+// the buckets of the list, and streams them grouped by bucket.
+type KeySearch struct {
+	buckets  []string
+	mode     int
+	focus    int
+	pattern  textinput.Model
+	include  textinput.Model
+	filter   tree.NameFilter
+	state    *keySearchState
+	selected struct{ bucket, key string }
+	error    string
+	next     func() (tea.Model, tea.Cmd)
+	quitting bool
+}
+
+// InitKeySearch asks for the pattern to search buckets for
+func InitKeySearch(buckets []string, next func() (tea.Model, tea.Cmd)) (tea.Model, tea.Cmd) {
+	pattern := textinput.New()
+	pattern.Prompt = "$ "
+	pattern.Placeholder = "invoice-2024*.pdf"
+	pattern.Width = 50
+
+	include := textinput.New()
+	include.Prompt = "$ "
+	include.Placeholder = "every bucket, or a glob of their names"
+	include.Width = 50
+
+	m := KeySearch{buckets: buckets, pattern: pattern, include: include, next: next}
+	return m, m.setFocus(keySearchPatternField)
+}
+
+func (m KeySearch) Init() tea.Cmd {
+	return nil
+}
+
+func (m *KeySearch) setFocus(focus int) tea.Cmd {
+	m.focus = (focus + keySearchBucketsField + 1) % (keySearchBucketsField + 1)
+	m.pattern.Blur()
+	m.include.Blur()
+	switch m.focus {
+	case keySearchPatternField:
+		return m.pattern.Focus()
+	case keySearchBucketsField:
+		return m.include.Focus()
+	}
+	return nil
+}
+
+// start compiles the form and searches the buckets it includes
+func (m KeySearch) start() (tea.Model, tea.Cmd) {
+	if m.pattern.Value() == "" {
+		m.error = "a pattern is required"
+		return m, nil
+	}
+	filter, err := tree.ParseKeyFilter(m.pattern.Value(), keySearchModes[m.mode] == "regex")
+	if err != nil {
+		m.error = fmt.Sprintf("invalid pattern: %v", err)
+		return m, nil
+	}
+	include, err := tree.ParseNameFilter(m.include.Value(), false)
+	if err != nil {
+		m.error = fmt.Sprintf("invalid bucket glob: %v", err)
+		return m, nil
+	}
+	var buckets []string
+	for _, bucket := range m.buckets {
+		if include.Matches(bucket) {
+			buckets = append(buckets, bucket)
+		}
+	}
+	if len(buckets) == 0 {
+		m.error = "no bucket matches " + m.include.Value()
+		return m, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.filter, m.error = filter, ""
+	m.state = &keySearchState{total: len(buckets), cancel: cancel}
+	m.pattern.Blur()
+	m.include.Blur()
+	return m, m.searchCmd(ctx, buckets)
+}
+
+// searchCmd lists the buckets, sending a message after every page
+func (m KeySearch) searchCmd(ctx context.Context, buckets []string) tea.Cmd {
+	filter, state := m.filter, m.state
+	return func() tea.Msg {
+		match := func(key string) bool {
+			return !constants.Trash.Hides(key) && filter.Matches(key)
+		}
+		constants.Or.SearchKeys(ctx, buckets, constants.Br.GetBucketRegion, match, func(result object.KeySearchResult) {
+			state.add(result)
+			constants.P.Send(keySearchProgressMsg{})
+		})
+		state.mu.Lock()
+		state.finished = true
+		state.mu.Unlock()
+		return keySearchProgressMsg{}
+	}
+}
+
+// rows lists the buckets with matches or errors, each followed by its keys.
+// The caller holds the lock of the state.
+func (m KeySearch) rows() []keySearchRow {
+	var rows []keySearchRow
+	for _, g := range m.state.groups {
+		if len(g.objects) == 0 && g.err == nil {
+			continue
+		}
+		rows = append(rows, keySearchRow{group: g, object: -1})
+		for i := range g.objects {
+			rows = append(rows, keySearchRow{group: g, object: i})
+		}
+	}
+	return rows
+}
+
+// cursor returns the row of the selected key, the first key when it is not
+// listed
+func (m KeySearch) cursor(rows []keySearchRow) int {
+	first := -1
+	for i, row := range rows {
+		if row.object < 0 {
+			continue
+		}
+		if first < 0 {
+			first = i
+		}
+		if row.group.bucket == m.selected.bucket && row.group.objects[row.object].Key == m.selected.key {
+			return i
+		}
+	}
+	return first
+}
+
+// move selects the key step keys away from the selected one
+func (m *KeySearch) move(step int) {
+	m.state.mu.Lock()
+	defer m.state.mu.Unlock()
+	rows := m.rows()
+	cursor := m.cursor(rows)
+	if cursor < 0 {
+		return
+	}
+	for i := cursor + step; i >= 0 && i < len(rows); i += step {
+		if row := rows[i]; row.object >= 0 {
+			m.selected.bucket, m.selected.key = row.group.bucket, row.group.objects[row.object].Key
+			return
+		}
+	}
+}
+
+// stop cancels the search if it is running
+func (m KeySearch) stop() {
+	if m.state != nil {
+		m.state.cancel()
+	}
+}
+
+// height is how many rows fit between the header and the help
+func (m KeySearch) height() int {
+	return max(constants.WindowSize.Height-10, 3)
+}
+
+func (m KeySearch) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		constants.WindowSize = msg
+		return m, nil
+
+	case keySearchProgressMsg:
+		return m, nil
+
+	case tea.KeyMsg:
+		if m.state == nil {
+			return m.updateForm(msg)
+		}
+		switch {
+		case msg.Type == tea.KeyCtrlC, key.Matches(msg, constants.Keymap.Quit):
+			m.stop()
+			m.quitting = true
+			return m, tea.Quit
+
+		case key.Matches(msg, constants.Keymap.Back):
+			m.stop()
+			return m.next()
+
+		case key.Matches(msg, constants.Keymap.Up):
+			m.move(-1)
+
+		case key.Matches(msg, constants.Keymap.Down):
+			m.move(1)
+
+		case key.Matches(msg, constants.Keymap.Enter), key.Matches(msg, constants.Keymap.Next):
+			m.state.mu.Lock()
+			rows := m.rows()
+			cursor := m.cursor(rows)
+			m.state.mu.Unlock()
+			if cursor < 0 {
+				return m, nil
+			}
+			group, key := rows[cursor].group, rows[cursor].group.objects[rows[cursor].object].Key
+			// The object is read in the region of its bucket
+			if group.region != "" && group.region != constants.Session.Config.Region {
+				setRegion(group.region)
+			}
+			return InitObjectAt(group.bucket, key, 0, func() (tea.Model, tea.Cmd) { return m, nil })
+		}
+	}
+	return m, nil
+}
+
+// updateForm edits the form until it is searched for
+func (m KeySearch) updateForm(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case msg.Type == tea.KeyCtrlC:
+		m.quitting = true
+		return m, tea.Quit
+
+	case key.Matches(msg, constants.Keymap.Back):
+		return m.next()
+
+	case key.Matches(msg, constants.Keymap.NextField):
+		return m, m.setFocus(m.focus + 1)
+
+	case key.Matches(msg, constants.Keymap.PrevField):
+		return m, m.setFocus(m.focus - 1)
+
+	case key.Matches(msg, constants.Keymap.Enter):
+		return m.start()
+	}
+
+	if m.focus == keySearchModeField {
+		switch {
+		case key.Matches(msg, constants.Keymap.Next), key.Matches(msg, constants.Keymap.Prev), msg.Type == tea.KeyRight, msg.Type == tea.KeyLeft:
+			m.mode = (m.mode + 1) % len(keySearchModes)
+		}
+		return m, nil
+	}
+	var cmd tea.Cmd
+	if m.focus == keySearchPatternField {
+		m.pattern, cmd = m.pattern.Update(msg)
+	} else {
+		m.include, cmd = m.include.Update(msg)
+	}
+	return m, cmd
+}
+
+func (m KeySearch) View() string {
+	if m.quitting {
+		return ""
+	}
+	if m.state == nil {
+		return m.formView()
+	}
+
+	m.state.mu.Lock()
+	defer m.state.mu.Unlock()
+	s := m.state
+	status := fmt.Sprintf("searched %d of %s • %s", s.searched, plural(s.total, "bucket"), plural(s.matched, "key"))
+	if s.finished {
+		status = constants.HelpStyle(status)
+	} else {
+		status = constants.AlertStyle(status + "...")
+	}
+	rows := []string{"\n", fmt.Sprintf("Keys matching %s %q", keySearchModes[m.mode], m.filter.Pattern), status, ""}
+	if s.matched > s.kept {
+		rows = append(rows, constants.AlertStyle(fmt.Sprintf("only the first %d keys are listed", s.kept)))
+	}
+
+	results := m.rows()
+	cursor := m.cursor(results)
+	start := 0
+	if cursor >= m.height() {
+		start = cursor - m.height() + 1
+	}
+	for i := start; i < len(results) && i < start+m.height(); i++ {
+		rows = append(rows, m.rowView(results[i], i == cursor))
+	}
+	if s.finished && len(results) == 0 {
+		rows = append(rows, "no keys match")
+	}
+	rows = append(rows,
+		constants.ShortHelp(constants.Keymap.Enter, constants.Keymap.Back, constants.Keymap.Quit),
+		constants.ErrStyle(m.error),
+	)
+	return constants.DocStyle.Render(lipgloss.JoinVertical(lipgloss.Left, rows...))
+}
+
+func (m KeySearch) rowView(row keySearchRow, selected bool) string {
+	g := row.group
+	if row.object < 0 {
+		line := fmt.Sprintf("s3://%s", g.bucket)
+		if g.region != "" {
+			line += " (" + g.region + ")"
+		}
+		line = constants.DirStyle(line)
+		switch {
+		case g.err != nil:
+			return line + " " + constants.ErrStyle(g.err.Error())
+		case !g.done:
+			return line + " " + constants.HelpStyle(plural(len(g.objects), "key")+" so far...")
+		}
+		return line + " " + constants.HelpStyle(plural(len(g.objects), "key"))
+	}
+	obj := g.objects[row.object]
+	name := obj.Key
+	cursor := "  "
+	if selected {
+		cursor = "> "
+		name = constants.SelectedStyle(name)
+	}
+	return fmt.Sprintf("  %s%s  %s", cursor, name, constants.HelpStyle(fmt.Sprintf("%s, %s", utils.HumanSize(obj.Size), obj.LastModified.Format("2006-01-02 15:04"))))
+}
+
+func (m KeySearch) formView() string {
+	label := func(field int, name string) string {
+		name = fmt.Sprintf("%-10s", name)
+		if field == m.focus {
+			return constants.SelectedStyle(name)
+		}
+		return name
+	}
+	rows := []string{
+		"\n",
+		fmt.Sprintf("Search the keys of %s", plural(len(m.buckets), "bucket")),
+		"",
+		label(keySearchModeField, "Match") + " < " + keySearchModes[m.mode] + " >",
+		label(keySearchPatternField, "Pattern") + " " + m.pattern.View(),
+		label(keySearchBucketsField, "Buckets") + " " + m.include.View(),
+		constants.HelpStyle("\n " + strings.Join([]string{
+			"globs match anywhere in the key, * crosses slashes",
+			"the pattern ignores case unless it has an upper case letter",
+		}, "\n ")),
+		constants.HelpStyle("\n tab: next field • ←/→ h/l: change • enter: search • esc: back\n"),
+		constants.ErrStyle(m.error),
+	}
+	return constants.DocStyle.Render(lipgloss.JoinVertical(lipgloss.Left, rows...))
+}
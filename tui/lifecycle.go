@@ -0,0 +1,363 @@
+package tui
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Wondrous27/s3-tui/bucket"
+	"github.com/Wondrous27/s3-tui/tui/constants"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// lifecycleTemplate is offered for editing when a bucket has no rules yet
+var lifecycleTemplate = []bucket.LifecycleRule{{
+	ID:          "expire-logs",
+	Status:      "Enabled",
+	Filter:      &bucket.LifecycleFilter{Prefix: "logs/"},
+	Transitions: []bucket.LifecycleTransition{{Days: 30, StorageClass: "STANDARD_IA"}},
+	Expiration:  &bucket.LifecycleExpiration{Days: 365},
+}}
+
+type updatedLifecycleMsg struct {
+	rules []bucket.LifecycleRule
+	err   error
+}
+
+type simulatedLifecycleMsg struct {
+	simulation bucket.LifecycleSimulation
+	err        error
+}
+
+// saveDraft is the help for saving edited rules once they are reviewed
+var saveDraft = key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "save draft"))
+
+// Lifecycle shows the lifecycle rules of a bucket, edits them in $EDITOR
+// and simulates what they will do to the objects in the coming days. Edited
+// rules are a draft, to be simulated before they are saved.
+type Lifecycle struct {
+	bucketName string
+	viewport   viewport.Model
+	rules      []bucket.LifecycleRule
+	// format is how the rules are shown and edited, "yaml" or "json"
+	format string
+	// draft keeps an edit that failed validation, so it is not lost
+	draft string
+	// offered is the document given to the editor, saved only once changed
+	offered string
+	// pending are the rules of a valid draft, non-nil until it is saved or
+	// discarded. An empty draft removes every rule.
+	pending []bucket.LifecycleRule
+	// days asks for the number of days to simulate
+	days       textinput.Model
+	simulation *bucket.LifecycleSimulation
+	mode       mode
+	isSure     bool
+	error      string
+	next       func() (tea.Model, tea.Cmd)
+	quitting   bool
+}
+
+func InitLifecycle(bucketName string, next func() (tea.Model, tea.Cmd)) (tea.Model, tea.Cmd) {
+	days := textinput.New()
+	days.Prompt = "Simulate the next "
+	days.Placeholder = "30"
+	days.CharLimit = 5
+	days.Width = 6
+
+	m := Lifecycle{bucketName: bucketName, format: "yaml", days: days, next: next}
+	top, right, bottom, left := constants.DocStyle.GetMargin()
+	m.viewport = viewport.New(constants.WindowSize.Width-left-right, constants.WindowSize.Height-top-bottom-8)
+	return m, m.getLifecycleCmd()
+}
+
+func (m Lifecycle) Init() tea.Cmd {
+	return nil
+}
+
+func (m Lifecycle) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		constants.WindowSize = msg
+		top, right, bottom, left := constants.DocStyle.GetMargin()
+		m.viewport = viewport.New(msg.Width-left-right, msg.Height-top-bottom-8)
+		m.setViewportContent()
+
+	case updatedLifecycleMsg:
+		if msg.err != nil {
+			m.error = msg.err.Error()
+			return m, nil
+		}
+		m.rules, m.draft, m.pending, m.error = msg.rules, "", nil, ""
+		m.setViewportContent()
+		return m, nil
+
+	case simulatedLifecycleMsg:
+		if msg.err != nil {
+			m.error = msg.err.Error()
+			return m, nil
+		}
+		m.simulation, m.error = &msg.simulation, ""
+		m.setViewportContent()
+		return m, nil
+
+	case editorFinishedMsg:
+		if msg.err != nil {
+			m.error = msg.err.Error()
+			return m, nil
+		}
+		if msg.content == m.offered {
+			return m, nil
+		}
+		m.draft = msg.content
+		rules, err := bucket.DecodeLifecycle(m.draft, m.format)
+		if err == nil {
+			err = bucket.ValidateLifecycle(rules)
+		}
+		if err != nil {
+			m.error = fmt.Sprintf("the rules were not saved, press e to fix them:\n%v", err)
+			return m, nil
+		}
+		if rules == nil {
+			rules = []bucket.LifecycleRule{}
+		}
+		m.pending, m.simulation, m.error = rules, nil, ""
+		m.setViewportContent()
+		return m, nil
+
+	case tea.KeyMsg:
+		if m.days.Focused() {
+			switch {
+			case key.Matches(msg, constants.Keymap.Back):
+				m.days.Blur()
+				return m, nil
+
+			case key.Matches(msg, constants.Keymap.Enter):
+				value := m.days.Value()
+				if value == "" {
+					value = m.days.Placeholder
+				}
+				days, err := strconv.Atoi(value)
+				if err != nil || days <= 0 {
+					m.error = "the number of days must be a positive number"
+					return m, nil
+				}
+				m.days.Blur()
+				return m, m.simulateCmd(days)
+			}
+			m.days, cmd = m.days.Update(msg)
+			return m, cmd
+		}
+
+		// edit confirms saving the draft, del deleting every rule
+		if m.mode == edit || m.mode == del {
+			switch {
+			case key.Matches(msg, constants.Keymap.Quit):
+				m.quitting = true
+				return m, tea.Quit
+
+			case key.Matches(msg, constants.Keymap.Enter):
+				saving := m.mode == edit
+				m.mode = nav
+				if m.isSure && saving {
+					return m, m.putLifecycleCmd(m.pending)
+				}
+				if m.isSure {
+					return m, m.putLifecycleCmd(nil)
+				}
+
+			case key.Matches(msg, constants.Keymap.Next), key.Matches(msg, constants.Keymap.Prev):
+				m.isSure = !m.isSure
+			}
+			return m, nil
+		}
+
+		switch {
+		case key.Matches(msg, constants.Keymap.Quit):
+			m.quitting = true
+			return m, tea.Quit
+
+		case key.Matches(msg, constants.Keymap.Back):
+			if m.simulation != nil {
+				m.simulation = nil
+				m.setViewportContent()
+				return m, nil
+			}
+			if m.pending != nil {
+				// The draft is kept for the next edit
+				m.pending = nil
+				m.setViewportContent()
+				return m, nil
+			}
+			return m.next()
+
+		case key.Matches(msg, constants.Keymap.Enter):
+			if m.pending == nil {
+				return m, nil
+			}
+			m.isSure = false
+			m.mode = edit
+			return m, nil
+
+		case key.Matches(msg, constants.Keymap.Edit):
+			content := m.draft
+			if content == "" {
+				rules := m.rules
+				if len(rules) == 0 {
+					rules = lifecycleTemplate
+				}
+				var err error
+				if content, err = bucket.EncodeLifecycle(rules, m.format); err != nil {
+					m.error = err.Error()
+					return m, nil
+				}
+			}
+			m.offered = content
+			return m, openEditorCmd(content, "."+m.format)
+
+		case key.Matches(msg, constants.Keymap.Format):
+			// A draft is written in the old format, switching discards it
+			m.draft, m.pending, m.simulation = "", nil, nil
+			if m.format == "yaml" {
+				m.format = "json"
+			} else {
+				m.format = "yaml"
+			}
+			m.setViewportContent()
+			return m, nil
+
+		case key.Matches(msg, constants.Keymap.Simulate):
+			m.error = ""
+			return m, m.days.Focus()
+
+		case key.Matches(msg, constants.Keymap.Delete):
+			if len(m.rules) == 0 {
+				return m, nil
+			}
+			m.isSure = false
+			m.mode = del
+			return m, nil
+		}
+	}
+	m.viewport, cmd = m.viewport.Update(msg)
+	return m, cmd
+}
+
+func (m *Lifecycle) setViewportContent() {
+	if m.simulation != nil {
+		m.viewport.SetContent(bucket.FormatLifecycleSimulation(*m.simulation))
+		return
+	}
+	rules := m.rules
+	if m.pending != nil {
+		rules = m.pending
+	}
+	if len(rules) == 0 && m.pending != nil {
+		m.viewport.SetContent(constants.AlertStyle("The draft removes every lifecycle rule, press s to simulate without them or enter to save it."))
+		return
+	}
+	if len(rules) == 0 {
+		m.viewport.SetContent(constants.AlertStyle("This bucket has no lifecycle rules, press e to write some."))
+		return
+	}
+	content, err := bucket.EncodeLifecycle(rules, m.format)
+	if err != nil {
+		m.error = err.Error()
+		return
+	}
+	str, err := renderFile("lifecycle."+m.format, content)
+	if err != nil {
+		m.error = "could not render the lifecycle rules"
+		return
+	}
+	str, _ = constants.FormatLineNumber(str, true)
+	m.viewport.SetContent(str)
+}
+
+func (m Lifecycle) View() string {
+	if m.quitting {
+		return ""
+	}
+	if m.mode == del || m.mode == edit && len(m.pending) == 0 {
+		msg := fmt.Sprintf("Are you sure you want to delete every lifecycle rule of %s?", m.bucketName)
+		return confirmationDialog(msg, m.isSure)
+	}
+	if m.mode == edit {
+		msg := fmt.Sprintf("Are you sure you want to replace the lifecycle rules of %s with the %s of the draft?",
+			m.bucketName, plural(len(m.pending), "rule"))
+		return confirmationDialog(msg, m.isSure)
+	}
+
+	title := fmt.Sprintf("lifecycle of %s", m.bucketName)
+	switch {
+	case m.simulation != nil && m.pending != nil:
+		title = fmt.Sprintf("lifecycle simulation of the draft for %s", m.bucketName)
+	case m.simulation != nil:
+		title = fmt.Sprintf("lifecycle simulation of %s", m.bucketName)
+	case m.pending != nil:
+		title = fmt.Sprintf("draft lifecycle of %s, not saved yet", m.bucketName)
+	}
+	bindings := []key.Binding{constants.Keymap.Back, constants.Keymap.Edit}
+	if m.pending != nil {
+		bindings = append(bindings, saveDraft)
+	}
+	bindings = append(bindings,
+		constants.Keymap.Format,
+		constants.Keymap.Simulate,
+		constants.Keymap.Delete,
+		constants.Keymap.Quit,
+	)
+	prompt := ""
+	if m.days.Focused() {
+		prompt = m.days.View() + " days"
+	}
+	return constants.DocStyle.Render(lipgloss.JoinVertical(
+		lipgloss.Left,
+		"\n",
+		title,
+		m.viewport.View(),
+		prompt,
+		constants.ShortHelp(bindings...),
+		constants.ErrStyle(m.error),
+	))
+}
+
+func (m Lifecycle) getLifecycleCmd() tea.Cmd {
+	return func() tea.Msg {
+		rules, err := constants.Br.GetBucketLifecycle(m.bucketName)
+		if err != nil {
+			return updatedLifecycleMsg{err: fmt.Errorf("[getLifecycleCmd] %v", err)}
+		}
+		return updatedLifecycleMsg{rules: rules}
+	}
+}
+
+func (m Lifecycle) putLifecycleCmd(rules []bucket.LifecycleRule) tea.Cmd {
+	return func() tea.Msg {
+		if err := constants.Br.PutBucketLifecycle(m.bucketName, rules); err != nil {
+			return updatedLifecycleMsg{err: fmt.Errorf("[putLifecycleCmd] %v", err)}
+		}
+		return m.getLifecycleCmd()()
+	}
+}
+
+// simulateCmd simulates the draft when there is one, the saved rules
+// otherwise
+func (m Lifecycle) simulateCmd(days int) tea.Cmd {
+	rules := m.rules
+	if m.pending != nil {
+		rules = m.pending
+	}
+	return func() tea.Msg {
+		objects, err := constants.Or.ListPrefix(m.bucketName, "")
+		if err != nil {
+			return simulatedLifecycleMsg{err: fmt.Errorf("[simulateCmd] %v", err)}
+		}
+		return simulatedLifecycleMsg{simulation: bucket.SimulateLifecycle(rules, objects, time.Now(), days)}
+	}
+}
@@ -0,0 +1,123 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Wondrous27/s3-tui/tui/constants"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type mfaLoginMsg struct{ err error }
+
+type sessionExpiresMsg struct {
+	expires time.Time
+	ok      bool
+}
+
+// MFA prompts for the token of the profile's MFA device and assumes the role
+// before handing over to the next model.
+type MFA struct {
+	input    textinput.Model
+	next     func() (tea.Model, tea.Cmd)
+	error    string
+	quitting bool
+}
+
+func InitMFA(next func() (tea.Model, tea.Cmd)) (tea.Model, tea.Cmd) {
+	input := textinput.New()
+	input.Prompt = "$ "
+	input.Placeholder = "123456"
+	input.CharLimit = 6
+	input.Width = 20
+	input.Focus()
+	return MFA{input: input, next: next}, textinput.Blink
+}
+
+func (m MFA) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+func (m MFA) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	switch msg := msg.(type) {
+	case mfaLoginMsg:
+		if msg.err != nil {
+			m.error = msg.err.Error()
+			m.input.SetValue("")
+			return m, nil
+		}
+		return m.next()
+
+	case tea.KeyMsg:
+		switch {
+		case msg.Type == tea.KeyCtrlC:
+			m.quitting = true
+			return m, tea.Quit
+
+		case key.Matches(msg, constants.Keymap.Enter):
+			token := m.input.Value()
+			m.error = ""
+			return m, mfaLoginCmd(token)
+		}
+	}
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+func (m MFA) View() string {
+	if m.quitting {
+		return ""
+	}
+	title := fmt.Sprintf("MFA token for %s", constants.Session.MFASerial)
+	return constants.DocStyle.Render(lipgloss.JoinVertical(
+		lipgloss.Left,
+		"\n",
+		fmt.Sprintf("profile %s", constants.Session.Profile),
+		title,
+		"",
+		m.input.View(),
+		constants.HelpStyle("\n enter: assume role • ctrl+c: quit\n"),
+		constants.ErrStyle(m.error),
+	))
+}
+
+func mfaLoginCmd(token string) tea.Cmd {
+	return func() tea.Msg {
+		return mfaLoginMsg{err: constants.Session.Login(context.TODO(), token)}
+	}
+}
+
+// sessionExpiresCmd looks up when the credentials expire, which may assume
+// the role, so it is not done while rendering
+func sessionExpiresCmd() tea.Cmd {
+	return func() tea.Msg {
+		expires, ok := constants.Session.Expires(context.TODO())
+		return sessionExpiresMsg{expires: expires, ok: ok}
+	}
+}
+
+// sessionStatus describes the active profile and, for temporary credentials,
+// how long they remain valid.
+func sessionStatus(expires sessionExpiresMsg) string {
+	if constants.Session == nil {
+		return ""
+	}
+	status := fmt.Sprintf("profile: %s • region: %s", constants.Session.Profile, constants.Session.Config.Region)
+	if constants.Br.ReadOnly {
+		status += " • read-only"
+	}
+	if !expires.ok {
+		return constants.HelpStyle(status)
+	}
+	left := time.Until(expires.expires).Round(time.Minute)
+	if left <= 0 {
+		return constants.ErrStyle(status + " • credentials expired")
+	}
+	return constants.HelpStyle(fmt.Sprintf("%s • credentials expire in %v (%s)",
+		status, left, expires.expires.Local().Format("15:04")))
+}
@@ -1,14 +1,18 @@
 package tui
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"log"
-	"os"
+	"path"
 	"strings"
+	"time"
 
 	"github.com/Wondrous27/s3-tui/object"
 	"github.com/Wondrous27/s3-tui/tui/constants"
 	"github.com/alecthomas/chroma/lexers"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/muesli/termenv"
 
 	"github.com/charmbracelet/bubbles/key"
@@ -23,9 +27,16 @@ type errMsg struct{ error }
 
 type UpdatedObject *object.Object
 
+// restorePollInterval is how often the view checks on a restore in progress
+const restorePollInterval = time.Minute
+
+// restoreStatusMsg is the metadata of an object whose restore is tracked
+type restoreStatusMsg *object.Object
+
+// editorFinishedMsg is the content saved in $EDITOR, whose file is gone
 type editorFinishedMsg struct {
-	err  error
-	file *os.File
+	err     error
+	content string
 }
 
 var cmd tea.Cmd
@@ -36,9 +47,25 @@ type Object struct {
 	activeBucketName string
 	error            string
 	object           object.Object
-	quitting         bool
+	// archived is set when the object has to be restored to be read
+	archived bool
+	// keyMissing is set when the object is encrypted client-side with a key
+	// none of the rules hold
+	keyMissing bool
+	// compressed is set when the content is shown decompressed from gzip
+	compressed bool
+	// unreadable is set when the object could not be read, the view then
+	// only shows the error
+	unreadable bool
+	// next is where esc goes back to, the bucket list when it is nil
+	next     func() (tea.Model, tea.Cmd)
+	mode     mode
+	isSure   bool
+	quitting bool
 }
 
+type deletedObjectMsg struct{}
+
 // Init run any intial IO on program start
 func (m Object) Init() tea.Cmd {
 	return nil
@@ -47,41 +74,134 @@ func (m Object) Init() tea.Cmd {
 // initialize the objectui model for your program
 func InitObject(bucketName, key string) (tea.Model, tea.Cmd) {
 	m := Object{activeBucketName: bucketName}
-	top, right, bottom, left := constants.DocStyle.GetMargin()
-	m.viewport = viewport.New(constants.WindowSize.Width-left-right, constants.WindowSize.Height-top-bottom-1)
-	m.viewport.Style = lipgloss.NewStyle().Align(lipgloss.Bottom)
+	msg := m.setupObject(bucketName, key)
+	if err, ok := msg.(errMsg); ok && errors.Is(err.error, object.ErrCustomerKeyRequired) {
+		return InitCustomerKey(bucketName, key)
+	}
+	if err, ok := msg.(errMsg); ok && errors.Is(err.error, object.ErrArchived) {
+		if head, err := constants.Or.HeadObject(bucketName, key); err == nil {
+			view, _ := newObjectView(bucketName, head)
+			m := view.(*Object)
+			m.archived = true
+			m.setViewportContent()
+			return m, m.pollRestoreCmd()
+		}
+	}
+	if err, ok := msg.(errMsg); ok && errors.Is(err.error, object.ErrClientKeyMissing) {
+		if head, err := constants.Or.HeadObject(bucketName, key); err == nil {
+			view, _ := newObjectView(bucketName, head)
+			m := view.(*Object)
+			m.keyMissing = true
+			m.setViewportContent()
+			return m, nil
+		}
+	}
+	obj, ok := msg.(UpdatedObject)
+	if !ok {
+		// Show why the object cannot be read, such as access denied
+		view, _ := newObjectView(bucketName, &object.Object{Key: key})
+		m := view.(*Object)
+		m.unreadable = true
+		if err, ok := msg.(errMsg); ok {
+			m.error = err.Error()
+		}
+		m.setViewportContent()
+		return m, nil
+	}
+	return newObjectView(bucketName, obj)
+}
 
-	obj, ok := m.setupObject(bucketName, key).(UpdatedObject)
+// InitObjectAt opens an object scrolled to a line of its content, numbered
+// from 1 with 0 showing it from the top, and goes back to next
+func InitObjectAt(bucketName, key string, line int, next func() (tea.Model, tea.Cmd)) (tea.Model, tea.Cmd) {
+	view, cmd := InitObject(bucketName, key)
+	m, ok := view.(*Object)
 	if !ok {
-		log.Println("failed to setup object")
-		return m, tea.Quit
+		return view, cmd
 	}
-	m.object = *obj
+	m.next = next
+	if line > 0 {
+		// Keep a few lines of context above the one asked for
+		m.viewport.SetYOffset(contentOffset(m.object) + line - 1 - 3)
+	}
+	return m, cmd
+}
+
+// contentOffset is the number of lines shown before the content of obj
+func contentOffset(obj object.Object) int {
+	obj.Content = ""
+	return strings.Count(object.FormatObject(obj), "\n")
+}
+
+// newObjectView shows an object that was already read
+func newObjectView(bucketName string, obj *object.Object) (tea.Model, tea.Cmd) {
+	m := Object{activeBucketName: bucketName, object: *obj}
+	m.decompress()
+	top, right, bottom, left := constants.DocStyle.GetMargin()
+	m.viewport = viewport.New(constants.WindowSize.Width-left-right, constants.WindowSize.Height-top-bottom-6)
+	m.viewport.Style = lipgloss.NewStyle().Align(lipgloss.Bottom)
 	m.setViewportContent()
 	return &m, nil
 }
 
+// setupObject reads the object, with the customer key it was opened with
+// when it uses SSE-C
 func (m *Object) setupObject(bucketName, key string) tea.Msg {
-	obj, err := constants.Or.GetObject(bucketName, key)
+	enc := constants.Or.DefaultEncryption(bucketName, key)
+	if m.object.Encryption != nil && m.object.Encryption.Mode == object.SSEC {
+		enc = *m.object.Encryption
+	}
+	obj, err := constants.Or.GetEncryptedObject(bucketName, key, enc)
 	if err != nil {
-		return errMsg{fmt.Errorf("cannot get content: %v", err)}
+		return errMsg{fmt.Errorf("cannot get content: %w", err)}
 	}
 	return UpdatedObject(obj)
 }
 
+// decompress replaces gzipped content with what it holds, to be read. Such
+// objects are not edited, as saving them would store them uncompressed.
+func (m *Object) decompress() {
+	m.compressed = false
+	if !object.IsGzip([]byte(m.object.Content)) {
+		return
+	}
+	r, err := object.Decompress(strings.NewReader(m.object.Content))
+	if err == nil {
+		var content []byte
+		if content, err = io.ReadAll(r); err == nil {
+			m.object.Content, m.compressed = string(content), true
+			return
+		}
+	}
+	m.error = fmt.Sprintf("could not decompress the content: %v", err)
+}
+
 func (m *Object) setViewportContent() {
 	var str string
 	var err error
+	if m.unreadable {
+		m.viewport.SetContent(fmt.Sprintf("s3://%s/%s could not be read.", m.activeBucketName, m.object.Key))
+		return
+	}
 	content := object.FormatObject(m.object)
+	if m.archived {
+		m.viewport.SetContent(content + archivedMessage(m.object))
+		return
+	}
+	if m.keyMissing {
+		m.viewport.SetContent(content + constants.AlertStyle(fmt.Sprintf(
+			"%s is encrypted client-side with the key %s.\nAdd a client_encryption rule with its key_file to read it.", m.object.Key, m.object.Encryption.ClientKey)))
+		return
+	}
 	if m.isSelectedMarkdown() {
 		str, err = glamour.Render(content, "dark")
 		if err != nil {
 			m.error = "could not render content with glamour"
 		}
 	} else {
-		str, err = m.renderFile(m.object.Key, content)
+		str, err = renderFile(m.object.Key, content)
 		str, _ = constants.FormatLineNumber(str, true)
-		log.Println("rendering file", str)
+		log.Println("rendering file", m.object.Key)
 		if err != nil {
 			log.Println("error rendering file", err)
 			m.error = "could not render content with renderFile"
@@ -91,7 +211,19 @@ func (m *Object) setViewportContent() {
 	m.viewport.SetContent(str)
 }
 
-func (m *Object) renderFile(path, content string) (string, error) {
+// archivedMessage explains why an archived object shows no content
+func archivedMessage(obj object.Object) string {
+	if obj.Restore != nil && obj.Restore.Ongoing {
+		return constants.AlertStyle(fmt.Sprintf(
+			"%s is in %s and a restore is in progress.\nThe content is shown once it is done.", obj.Key, obj.StorageClass))
+	}
+	return constants.AlertStyle(fmt.Sprintf(
+		"%s is in %s and has to be restored before it can be read.\nPress r to restore a temporary copy.", obj.Key, obj.StorageClass))
+}
+
+// renderFile highlights content with the lexer matching path, or the one
+// guessed from content when path is empty.
+func renderFile(path, content string) (string, error) {
 	lexer := lexers.Match(path)
 	if path == "" {
 		lexer = lexers.Analyse(content)
@@ -134,9 +266,16 @@ func (m Object) isSelectedMarkdown() bool {
 }
 
 func (m Object) helpView() string {
-	// TODO: use the keymaps to populate the help string
-	return constants.HelpStyle(
-		"\n ↑/↓: h/j/k/l navigate  • esc: back • e: edit object • d: delete object • q: quit\n",
+	return constants.ShortHelp(
+		constants.Keymap.Back,
+		constants.Keymap.Edit,
+		constants.Keymap.Delete,
+		constants.Keymap.Presign,
+		constants.Keymap.Copy,
+		constants.Keymap.Tags,
+		constants.Keymap.StorageClass,
+		constants.Keymap.Restore,
+		constants.Keymap.Quit,
 	)
 }
 
@@ -145,6 +284,9 @@ func (m Object) View() string {
 	if m.quitting {
 		return ""
 	}
+	if m.mode == del {
+		return confirmationDialog(deleteQuestion(m.object.Key), m.isSure)
+	}
 
 	formatted := lipgloss.JoinVertical(
 		lipgloss.Left,
@@ -161,6 +303,7 @@ func (m Object) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
+		constants.WindowSize = msg
 		top, right, bottom, left := constants.DocStyle.GetMargin()
 		m.viewport = viewport.New(constants.WindowSize.Width-left-right, constants.WindowSize.Height-top-bottom-6)
 
@@ -169,16 +312,87 @@ func (m Object) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case editorFinishedMsg:
 		if msg.err != nil {
-			return m, tea.Quit
+			m.error = msg.err.Error()
+			return m, nil
 		}
-		cmds = append(cmds, m.updateObjectCmd(msg.file.Name()))
+		cmds = append(cmds, m.updateObjectCmd(msg.content))
 
 	case UpdatedObject:
-		m.object = *msg
+		m.object, m.archived, m.keyMissing = *msg, false, false
+		m.decompress()
+
+	case restoreStatusMsg:
+		if msg.Restore != nil && !msg.Restore.Ongoing {
+			return m, func() tea.Msg { return m.setupObject(m.activeBucketName, m.object.Key) }
+		}
+		m.object.Restore = msg.Restore
+		m.setViewportContent()
+		return m, m.pollRestoreCmd()
+
+	case deletedObjectMsg:
+		return openTree(m.activeBucketName, path.Dir(m.object.Key), m)
 
 	case tea.KeyMsg:
+		if m.mode == del {
+			switch {
+			case key.Matches(msg, constants.Keymap.Quit):
+				m.quitting = true
+				return m, tea.Quit
+
+			case key.Matches(msg, constants.Keymap.Enter):
+				m.mode = nav
+				if m.isSure {
+					return m, m.deleteObjectCmd()
+				}
+
+			case key.Matches(msg, constants.Keymap.Next), key.Matches(msg, constants.Keymap.Prev):
+				m.isSure = !m.isSure
+			}
+			return m, nil
+		}
+
 		switch {
+		case key.Matches(msg, constants.Keymap.Delete):
+			m.isSure = false
+			m.mode = del
+			return m, nil
+
+		case key.Matches(msg, constants.Keymap.Presign):
+			return InitPresign(m.activeBucketName, m.object.Key, func() (tea.Model, tea.Cmd) { return m, nil })
+
+		case key.Matches(msg, constants.Keymap.Copy):
+			return InitCopyMenu(m.activeBucketName, m.object.Key, false, func() (tea.Model, tea.Cmd) { return m, nil })
+
+		case key.Matches(msg, constants.Keymap.Tags):
+			return InitTags(m.activeBucketName, m.object.Key, func() (tea.Model, tea.Cmd) { return m, nil })
+
+		case key.Matches(msg, constants.Keymap.StorageClass):
+			return InitStorageClass(m.activeBucketName, m.object.Key, m.object.StorageClass, m.reload)
+
+		case key.Matches(msg, constants.Keymap.Restore):
+			if !object.IsArchived(types.StorageClass(m.object.StorageClass)) {
+				m.error = "only objects in GLACIER or DEEP_ARCHIVE need a restore"
+				return m, nil
+			}
+			return InitRestore(m.activeBucketName, m.object.Key, m.reload)
+
 		case key.Matches(msg, constants.Keymap.Edit):
+			if m.archived {
+				m.error = "the object has to be restored before it can be edited"
+				return m, nil
+			}
+			if m.keyMissing {
+				m.error = "the object cannot be edited without its client-side key"
+				return m, nil
+			}
+			if m.compressed {
+				m.error = "gzipped objects are shown decompressed and cannot be edited"
+				return m, nil
+			}
+			if m.unreadable {
+				m.error = "the object could not be read, so it cannot be edited"
+				return m, nil
+			}
 			fileContent := m.object.Content
 			keys := strings.Split(m.object.Key, "/")
 			fileName := keys[len(keys)-1]
@@ -186,11 +400,13 @@ func (m Object) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case key.Matches(msg, constants.Keymap.Create):
 			return m, nil
 		case key.Matches(msg, constants.Keymap.Back):
+			if m.next != nil {
+				return m.next()
+			}
 			return InitBuckets()
 
 		case key.Matches(msg, constants.Keymap.Prev):
-			tree := InitTree(m.activeBucketName)
-			return tree.Update(constants.WindowSize)
+			return openTree(m.activeBucketName, m.object.Key, m)
 
 		case key.Matches(msg, constants.Keymap.Quit):
 			m.quitting = true
@@ -203,3 +419,23 @@ func (m Object) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	m.setViewportContent()
 	return m, tea.Batch(cmds...)
 }
+
+// reload opens the object again, after its storage class or restore changed
+func (m Object) reload() (tea.Model, tea.Cmd) {
+	return InitObject(m.activeBucketName, m.object.Key)
+}
+
+// pollRestoreCmd checks on a restore in progress until it is done
+func (m Object) pollRestoreCmd() tea.Cmd {
+	if m.object.Restore == nil || !m.object.Restore.Ongoing {
+		return nil
+	}
+	bucketName, key := m.activeBucketName, m.object.Key
+	return tea.Tick(restorePollInterval, func(time.Time) tea.Msg {
+		obj, err := constants.Or.HeadObject(bucketName, key)
+		if err != nil {
+			return errMsg{fmt.Errorf("[pollRestoreCmd] %v", err)}
+		}
+		return restoreStatusMsg(obj)
+	})
+}
@@ -0,0 +1,208 @@
+package tui
+
+import (
+	"fmt"
+
+	"github.com/Wondrous27/s3-tui/bucket"
+	"github.com/Wondrous27/s3-tui/tui/constants"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// policyTemplate is offered for editing when a bucket has no policy yet
+const policyTemplate = `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Sid": "AllowRead",
+      "Effect": "Allow",
+      "Principal": {"AWS": "arn:aws:iam::123456789012:root"},
+      "Action": "s3:GetObject",
+      "Resource": "arn:aws:s3:::%s/*"
+    }
+  ]
+}
+`
+
+type updatedPolicyMsg struct {
+	policy string
+	err    error
+}
+
+// Policy shows the policy of a bucket and edits it in $EDITOR
+type Policy struct {
+	bucketName string
+	viewport   viewport.Model
+	policy     string
+	// draft keeps an edit that failed validation, so it is not lost
+	draft string
+	// offered is the document given to the editor, saved only once changed
+	offered  string
+	mode     mode
+	isSure   bool
+	error    string
+	next     func() (tea.Model, tea.Cmd)
+	quitting bool
+}
+
+func InitPolicy(bucketName string, next func() (tea.Model, tea.Cmd)) (tea.Model, tea.Cmd) {
+	m := Policy{bucketName: bucketName, next: next}
+	top, right, bottom, left := constants.DocStyle.GetMargin()
+	m.viewport = viewport.New(constants.WindowSize.Width-left-right, constants.WindowSize.Height-top-bottom-7)
+	return m, m.getPolicyCmd()
+}
+
+func (m Policy) Init() tea.Cmd {
+	return nil
+}
+
+func (m Policy) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		constants.WindowSize = msg
+		top, right, bottom, left := constants.DocStyle.GetMargin()
+		m.viewport = viewport.New(msg.Width-left-right, msg.Height-top-bottom-7)
+		m.setViewportContent()
+
+	case updatedPolicyMsg:
+		if msg.err != nil {
+			m.error = msg.err.Error()
+			return m, nil
+		}
+		m.policy, m.draft, m.error = msg.policy, "", ""
+		m.setViewportContent()
+		return m, nil
+
+	case editorFinishedMsg:
+		if msg.err != nil {
+			m.error = msg.err.Error()
+			return m, nil
+		}
+		if msg.content == m.offered {
+			return m, nil
+		}
+		m.draft = msg.content
+		if err := bucket.ValidatePolicy(m.bucketName, m.draft); err != nil {
+			m.error = fmt.Sprintf("the policy was not saved, press e to fix it:\n%v", err)
+			return m, nil
+		}
+		return m, m.putPolicyCmd(m.draft)
+
+	case tea.KeyMsg:
+		if m.mode == del {
+			switch {
+			case key.Matches(msg, constants.Keymap.Quit):
+				m.quitting = true
+				return m, tea.Quit
+
+			case key.Matches(msg, constants.Keymap.Enter):
+				m.mode = nav
+				if m.isSure {
+					return m, m.deletePolicyCmd()
+				}
+
+			case key.Matches(msg, constants.Keymap.Next), key.Matches(msg, constants.Keymap.Prev):
+				m.isSure = !m.isSure
+			}
+			return m, nil
+		}
+
+		switch {
+		case key.Matches(msg, constants.Keymap.Quit):
+			m.quitting = true
+			return m, tea.Quit
+
+		case key.Matches(msg, constants.Keymap.Back):
+			return m.next()
+
+		case key.Matches(msg, constants.Keymap.Edit):
+			content := m.draft
+			if content == "" {
+				content = bucket.FormatPolicy(m.policy)
+			}
+			if content == "" {
+				content = fmt.Sprintf(policyTemplate, m.bucketName)
+			}
+			m.offered = content
+			return m, openEditorCmd(content, ".json")
+
+		case key.Matches(msg, constants.Keymap.Delete):
+			if m.policy == "" {
+				return m, nil
+			}
+			m.isSure = false
+			m.mode = del
+			return m, nil
+		}
+	}
+	m.viewport, cmd = m.viewport.Update(msg)
+	return m, cmd
+}
+
+func (m *Policy) setViewportContent() {
+	if m.policy == "" {
+		m.viewport.SetContent(constants.AlertStyle("This bucket has no policy, press e to write one."))
+		return
+	}
+	str, err := renderFile("policy.json", bucket.FormatPolicy(m.policy))
+	if err != nil {
+		m.error = "could not render the policy"
+		return
+	}
+	str, _ = constants.FormatLineNumber(str, true)
+	m.viewport.SetContent(str)
+}
+
+func (m Policy) View() string {
+	if m.quitting {
+		return ""
+	}
+	if m.mode == del {
+		msg := fmt.Sprintf("Are you sure you want to delete the policy of %s?", m.bucketName)
+		return confirmationDialog(msg, m.isSure)
+	}
+	return constants.DocStyle.Render(lipgloss.JoinVertical(
+		lipgloss.Left,
+		"\n",
+		fmt.Sprintf("policy of %s", m.bucketName),
+		m.viewport.View(),
+		constants.ShortHelp(
+			constants.Keymap.Back,
+			constants.Keymap.Edit,
+			constants.Keymap.Delete,
+			constants.Keymap.Quit,
+		),
+		constants.ErrStyle(m.error),
+	))
+}
+
+func (m Policy) getPolicyCmd() tea.Cmd {
+	return func() tea.Msg {
+		policy, err := constants.Br.GetBucketPolicy(m.bucketName)
+		if err != nil {
+			return updatedPolicyMsg{err: fmt.Errorf("[getPolicyCmd] %v", err)}
+		}
+		return updatedPolicyMsg{policy: policy}
+	}
+}
+
+func (m Policy) putPolicyCmd(policy string) tea.Cmd {
+	return func() tea.Msg {
+		if err := constants.Br.PutBucketPolicy(m.bucketName, policy); err != nil {
+			return updatedPolicyMsg{err: fmt.Errorf("[putPolicyCmd] %v", err)}
+		}
+		return m.getPolicyCmd()()
+	}
+}
+
+func (m Policy) deletePolicyCmd() tea.Cmd {
+	return func() tea.Msg {
+		if err := constants.Br.DeleteBucketPolicy(m.bucketName); err != nil {
+			return updatedPolicyMsg{err: fmt.Errorf("[deletePolicyCmd] %v", err)}
+		}
+		return updatedPolicyMsg{}
+	}
+}
@@ -0,0 +1,267 @@
+package tui
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Wondrous27/s3-tui/object"
+	"github.com/Wondrous27/s3-tui/tui/constants"
+	"github.com/Wondrous27/s3-tui/utils"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var presignMethods = []string{"GET", "PUT", "POST"}
+
+var presignExpiries = []struct {
+	label    string
+	duration time.Duration
+}{
+	{"5 minutes", 5 * time.Minute},
+	{"15 minutes", 15 * time.Minute},
+	{"1 hour", time.Hour},
+	{"6 hours", 6 * time.Hour},
+	{"12 hours", 12 * time.Hour},
+	{"1 day", 24 * time.Hour},
+	{"3 days", 3 * 24 * time.Hour},
+	{"7 days", object.MaxPresignExpiry},
+}
+
+// Fields of the presign form, in tab order
+const (
+	presignMethodField = iota
+	presignExpiryField
+	presignDispositionField
+	presignContentTypeField
+)
+
+type presignedMsg struct {
+	url    string
+	copied bool
+	err    error
+}
+
+// Presign generates presigned URLs for an object and copies them to the
+// clipboard.
+type Presign struct {
+	bucketName  string
+	key         string
+	method      int
+	expiry      int
+	focus       int
+	disposition textinput.Model
+	contentType textinput.Model
+	url         string
+	copied      bool
+	error       string
+	// session is when the credentials signing the URL expire, which ends
+	// the URL sooner than its own expiry
+	session  sessionExpiresMsg
+	next     func() (tea.Model, tea.Cmd)
+	quitting bool
+}
+
+func InitPresign(bucketName, key string, next func() (tea.Model, tea.Cmd)) (tea.Model, tea.Cmd) {
+	disposition := textinput.New()
+	disposition.Prompt = "$ "
+	disposition.Placeholder = fmt.Sprintf("attachment; filename=%q", path.Base(key))
+	disposition.Width = 60
+
+	contentType := textinput.New()
+	contentType.Prompt = "$ "
+	contentType.Placeholder = "application/octet-stream"
+	contentType.Width = 60
+
+	return Presign{
+		bucketName:  bucketName,
+		key:         key,
+		expiry:      2,
+		disposition: disposition,
+		contentType: contentType,
+		next:        next,
+	}, sessionExpiresCmd()
+}
+
+func (m Presign) Init() tea.Cmd {
+	return nil
+}
+
+// fields returns how many form fields the selected method has
+func (m Presign) fields() int {
+	if presignMethods[m.method] == "GET" {
+		return presignContentTypeField + 1
+	}
+	return presignExpiryField + 1
+}
+
+func (m *Presign) setFocus(focus int) tea.Cmd {
+	m.focus = (focus + m.fields()) % m.fields()
+	m.disposition.Blur()
+	m.contentType.Blur()
+	switch m.focus {
+	case presignDispositionField:
+		return m.disposition.Focus()
+	case presignContentTypeField:
+		return m.contentType.Focus()
+	}
+	return nil
+}
+
+func (m Presign) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	switch msg := msg.(type) {
+	case sessionExpiresMsg:
+		m.session = msg
+		return m, nil
+
+	case presignedMsg:
+		m.url, m.copied, m.error = msg.url, msg.copied, ""
+		if msg.err != nil {
+			m.error = msg.err.Error()
+		}
+		return m, nil
+
+	case tea.KeyMsg:
+		switch {
+		case msg.Type == tea.KeyCtrlC:
+			m.quitting = true
+			return m, tea.Quit
+
+		case key.Matches(msg, constants.Keymap.Back):
+			return m.next()
+
+		case key.Matches(msg, constants.Keymap.NextField):
+			return m, m.setFocus(m.focus + 1)
+
+		case key.Matches(msg, constants.Keymap.PrevField):
+			return m, m.setFocus(m.focus - 1)
+
+		case key.Matches(msg, constants.Keymap.Enter):
+			return m, m.presignCmd()
+		}
+
+		if m.focus == presignMethodField || m.focus == presignExpiryField {
+			step := 0
+			switch {
+			case key.Matches(msg, constants.Keymap.Next), msg.Type == tea.KeyRight:
+				step = 1
+			case key.Matches(msg, constants.Keymap.Prev), msg.Type == tea.KeyLeft:
+				step = -1
+			}
+			if m.focus == presignMethodField {
+				m.method = (m.method + step + len(presignMethods)) % len(presignMethods)
+			} else {
+				m.expiry = (m.expiry + step + len(presignExpiries)) % len(presignExpiries)
+			}
+			return m, nil
+		}
+	}
+
+	m.disposition, cmd = m.disposition.Update(msg)
+	cmds := []tea.Cmd{cmd}
+	m.contentType, cmd = m.contentType.Update(msg)
+	return m, tea.Batch(append(cmds, cmd)...)
+}
+
+func (m Presign) View() string {
+	if m.quitting {
+		return ""
+	}
+	label := func(field int, name string) string {
+		name = fmt.Sprintf("%-20s", name)
+		if field == m.focus {
+			return constants.SelectedStyle(name)
+		}
+		return name
+	}
+
+	rows := []string{
+		fmt.Sprintf("Presign s3://%s/%s", m.bucketName, m.key),
+		"",
+		label(presignMethodField, "Method") + " < " + presignMethods[m.method] + " >",
+		label(presignExpiryField, "Expires in") + " < " + presignExpiries[m.expiry].label + " >",
+	}
+	if m.session.ok && time.Now().Add(presignExpiries[m.expiry].duration).After(m.session.expires) {
+		rows = append(rows, constants.AlertStyle(fmt.Sprintf(
+			"The credentials expire at %s, the URL stops working then.", m.session.expires.Local().Format("15:04"))))
+	}
+	if m.fields() > presignDispositionField {
+		rows = append(rows,
+			label(presignDispositionField, "Content-Disposition")+" "+m.disposition.View(),
+			label(presignContentTypeField, "Content-Type")+" "+m.contentType.View(),
+		)
+	}
+	if m.url != "" {
+		width := constants.WindowSize.Width - 4
+		if width <= 0 {
+			width = 80
+		}
+		rows = append(rows, "", lipgloss.NewStyle().Width(width).Render(m.url))
+		if m.copied {
+			rows = append(rows, "", constants.AlertStyle("copied to the clipboard"))
+		}
+	}
+	rows = append(rows,
+		constants.HelpStyle("\n tab: next field • ←/→ h/l: change • enter: presign and copy • esc: back\n"),
+		constants.ErrStyle(m.error),
+	)
+	return constants.DocStyle.Render(lipgloss.JoinVertical(lipgloss.Left, append([]string{"\n"}, rows...)...))
+}
+
+func (m Presign) presignCmd() tea.Cmd {
+	method := presignMethods[m.method]
+	expires := presignExpiries[m.expiry].duration
+	overrides := object.ResponseOverrides{
+		ContentDisposition: m.disposition.Value(),
+		ContentType:        m.contentType.Value(),
+	}
+	return func() tea.Msg {
+		var url string
+		var err error
+		switch method {
+		case "GET":
+			url, err = constants.Or.PresignGet(m.bucketName, m.key, expires, overrides)
+		case "PUT":
+			url, err = constants.Or.PresignPut(m.bucketName, m.key, expires)
+		case "POST":
+			var post *object.PresignedPost
+			if post, err = constants.Or.PresignPost(m.bucketName, m.key, expires); err == nil {
+				url = curlPostCommand(post, path.Base(m.key))
+			}
+		}
+		if err != nil {
+			return presignedMsg{err: fmt.Errorf("[presignCmd] %v", err)}
+		}
+		if err := utils.CopyToClipboard(url); err != nil {
+			return presignedMsg{url: url, err: fmt.Errorf("could not copy to the clipboard: %v", err)}
+		}
+		return presignedMsg{url: url, copied: true}
+	}
+}
+
+// curlPostCommand turns a presigned POST into a ready to run upload command
+func curlPostCommand(post *object.PresignedPost, fileName string) string {
+	names := make([]string, 0, len(post.Fields))
+	for name := range post.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	sb.WriteString("curl")
+	for _, name := range names {
+		// --form-string keeps curl from reading values starting with @ or <
+		sb.WriteString(" --form-string " + shellQuote(name+"="+post.Fields[name]))
+	}
+	fmt.Fprintf(&sb, " -F %s %s", shellQuote("file=@"+fileName), shellQuote(post.URL))
+	return sb.String()
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,130 @@
+package tui
+
+import (
+	"github.com/Wondrous27/s3-tui/tui/constants"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type regionItem struct {
+	code string
+	name string
+}
+
+// Implement the `Item` interface
+func (r regionItem) Title() string       { return r.code }
+func (r regionItem) Description() string { return r.name }
+func (r regionItem) FilterValue() string { return r.code + " " + r.name }
+
+var regions = []list.Item{
+	regionItem{"us-east-1", "US East (N. Virginia)"},
+	regionItem{"us-east-2", "US East (Ohio)"},
+	regionItem{"us-west-1", "US West (N. California)"},
+	regionItem{"us-west-2", "US West (Oregon)"},
+	regionItem{"af-south-1", "Africa (Cape Town)"},
+	regionItem{"ap-east-1", "Asia Pacific (Hong Kong)"},
+	regionItem{"ap-south-1", "Asia Pacific (Mumbai)"},
+	regionItem{"ap-south-2", "Asia Pacific (Hyderabad)"},
+	regionItem{"ap-southeast-1", "Asia Pacific (Singapore)"},
+	regionItem{"ap-southeast-2", "Asia Pacific (Sydney)"},
+	regionItem{"ap-southeast-3", "Asia Pacific (Jakarta)"},
+	regionItem{"ap-southeast-4", "Asia Pacific (Melbourne)"},
+	regionItem{"ap-northeast-1", "Asia Pacific (Tokyo)"},
+	regionItem{"ap-northeast-2", "Asia Pacific (Seoul)"},
+	regionItem{"ap-northeast-3", "Asia Pacific (Osaka)"},
+	regionItem{"ca-central-1", "Canada (Central)"},
+	regionItem{"eu-central-1", "Europe (Frankfurt)"},
+	regionItem{"eu-central-2", "Europe (Zurich)"},
+	regionItem{"eu-west-1", "Europe (Ireland)"},
+	regionItem{"eu-west-2", "Europe (London)"},
+	regionItem{"eu-west-3", "Europe (Paris)"},
+	regionItem{"eu-south-1", "Europe (Milan)"},
+	regionItem{"eu-south-2", "Europe (Spain)"},
+	regionItem{"eu-north-1", "Europe (Stockholm)"},
+	regionItem{"il-central-1", "Israel (Tel Aviv)"},
+	regionItem{"me-south-1", "Middle East (Bahrain)"},
+	regionItem{"me-central-1", "Middle East (UAE)"},
+	regionItem{"sa-east-1", "South America (São Paulo)"},
+}
+
+// Region lets the user pick the region the repositories talk to.
+type Region struct {
+	list     list.Model
+	next     func() (tea.Model, tea.Cmd)
+	quitting bool
+}
+
+func InitRegion(next func() (tea.Model, tea.Cmd)) (tea.Model, tea.Cmd) {
+	m := Region{list: list.New(regions, list.NewDefaultDelegate(), 8, 8), next: next}
+	if constants.WindowSize.Height != 0 {
+		top, right, bottom, left := constants.DocStyle.GetMargin()
+		m.list.SetSize(constants.WindowSize.Width-left-right, constants.WindowSize.Height-top-bottom-1)
+	}
+	m.list.Title = "regions"
+	for i, item := range regions {
+		if item.(regionItem).code == constants.Session.Config.Region {
+			m.list.Select(i)
+		}
+	}
+	return m, nil
+}
+
+func (m Region) Init() tea.Cmd {
+	return nil
+}
+
+func (m Region) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		constants.WindowSize = msg
+		top, right, bottom, left := constants.DocStyle.GetMargin()
+		m.list.SetSize(msg.Width-left-right, msg.Height-top-bottom-1)
+
+	case tea.KeyMsg:
+		if m.list.FilterState() == list.Filtering {
+			break
+		}
+		switch {
+		case key.Matches(msg, constants.Keymap.Quit):
+			m.quitting = true
+			return m, tea.Quit
+
+		case key.Matches(msg, constants.Keymap.Back):
+			// Without a region there is nothing to go back to
+			if constants.Session.Config.Region == "" {
+				return m, nil
+			}
+			return m.next()
+
+		case key.Matches(msg, constants.Keymap.Enter):
+			// The filter may match no region
+			if region, ok := m.list.SelectedItem().(regionItem); ok {
+				setRegion(region.code)
+				return m.next()
+			}
+			return m, nil
+		}
+	}
+	m.list, cmd = m.list.Update(msg)
+	return m, cmd
+}
+
+func (m Region) View() string {
+	if m.quitting {
+		return ""
+	}
+	return constants.DocStyle.Render(m.list.View() + "\n")
+}
+
+// setRegion points the session, both repositories and the KMS client at a
+// new region. The clients are created after the session has switched, so
+// they sign their requests for it.
+func setRegion(region string) {
+	constants.Session.SetRegion(region)
+	client := constants.Session.S3Client()
+	constants.Br.Client = client
+	constants.Or.Client = client
+	constants.Or.KMS = constants.Session.KMSClient()
+}
@@ -0,0 +1,277 @@
+package tui
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/Wondrous27/s3-tui/object"
+	"github.com/Wondrous27/s3-tui/tui/constants"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// restoreTierHelp tells how long each retrieval tier takes
+var restoreTierHelp = map[types.Tier]string{
+	types.TierExpedited: "1-5 minutes, not available for DEEP_ARCHIVE",
+	types.TierStandard:  "3-5 hours, 12 hours for DEEP_ARCHIVE",
+	types.TierBulk:      "5-12 hours, 48 hours for DEEP_ARCHIVE",
+}
+
+// storageDoneMsg reports a storage class change or a restore request
+type storageDoneMsg struct{ err error }
+
+// StorageClassMenu changes the storage class of an object by copying it onto
+// itself in the class picked from a menu.
+type StorageClassMenu struct {
+	bucketName string
+	key        string
+	current    types.StorageClass
+	cursor     int
+	mode       mode
+	isSure     bool
+	error      string
+	next       func() (tea.Model, tea.Cmd)
+	quitting   bool
+}
+
+func InitStorageClass(bucketName, key string, current types.ObjectStorageClass, next func() (tea.Model, tea.Cmd)) (tea.Model, tea.Cmd) {
+	m := StorageClassMenu{bucketName: bucketName, key: key, current: types.StorageClass(current), next: next}
+	if m.current == "" {
+		m.current = types.StorageClassStandard
+	}
+	for i, class := range object.StorageClasses {
+		if class == m.current {
+			m.cursor = i
+		}
+	}
+	return m, nil
+}
+
+func (m StorageClassMenu) Init() tea.Cmd {
+	return nil
+}
+
+func (m StorageClassMenu) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case storageDoneMsg:
+		if msg.err != nil {
+			m.error = msg.err.Error()
+			return m, nil
+		}
+		return m.next()
+
+	case tea.KeyMsg:
+		if m.mode == del {
+			switch {
+			case key.Matches(msg, constants.Keymap.Quit):
+				m.quitting = true
+				return m, tea.Quit
+
+			case key.Matches(msg, constants.Keymap.Enter):
+				m.mode = nav
+				if m.isSure {
+					return m, m.changeStorageClassCmd(object.StorageClasses[m.cursor])
+				}
+
+			case key.Matches(msg, constants.Keymap.Next), key.Matches(msg, constants.Keymap.Prev):
+				m.isSure = !m.isSure
+			}
+			return m, nil
+		}
+
+		switch {
+		case key.Matches(msg, constants.Keymap.Quit):
+			m.quitting = true
+			return m, tea.Quit
+
+		case key.Matches(msg, constants.Keymap.Back), key.Matches(msg, constants.Keymap.Prev):
+			return m.next()
+
+		case key.Matches(msg, constants.Keymap.Up):
+			m.cursor = (m.cursor - 1 + len(object.StorageClasses)) % len(object.StorageClasses)
+
+		case key.Matches(msg, constants.Keymap.Down):
+			m.cursor = (m.cursor + 1) % len(object.StorageClasses)
+
+		case key.Matches(msg, constants.Keymap.Enter), key.Matches(msg, constants.Keymap.Next):
+			if object.StorageClasses[m.cursor] == m.current {
+				return m, nil
+			}
+			m.error = ""
+			m.isSure = false
+			m.mode = del
+		}
+	}
+	return m, nil
+}
+
+func (m StorageClassMenu) View() string {
+	if m.quitting {
+		return ""
+	}
+	class := object.StorageClasses[m.cursor]
+	if m.mode == del {
+		msg := fmt.Sprintf("Are you sure you want to copy %s in place into %s?", m.key, class)
+		if object.IsArchived(class) {
+			msg += "\nIt can only be read again after a restore."
+		}
+		return confirmationDialog(msg, m.isSure)
+	}
+	rows := []string{"\n", fmt.Sprintf("Storage class of %s", m.key), ""}
+	for i, class := range object.StorageClasses {
+		cursor := "  "
+		name := fmt.Sprintf("%-20s", class)
+		if i == m.cursor {
+			cursor = "> "
+			name = constants.SelectedStyle(name)
+		}
+		if class == m.current {
+			name += " current"
+		}
+		rows = append(rows, cursor+name)
+	}
+	rows = append(rows,
+		constants.ShortHelp(constants.Keymap.Enter, constants.Keymap.Back, constants.Keymap.Quit),
+		constants.ErrStyle(m.error),
+	)
+	return constants.DocStyle.Render(lipgloss.JoinVertical(lipgloss.Left, rows...))
+}
+
+func (m StorageClassMenu) changeStorageClassCmd(class types.StorageClass) tea.Cmd {
+	return func() tea.Msg {
+		if err := constants.Or.ChangeStorageClass(m.bucketName, m.key, class); err != nil {
+			return storageDoneMsg{fmt.Errorf("[changeStorageClassCmd] %v", err)}
+		}
+		return storageDoneMsg{}
+	}
+}
+
+// Fields of the restore form, in tab order
+const (
+	restoreTierField = iota
+	restoreDaysField
+)
+
+// RestoreForm starts restoring a temporary copy of an archived object
+type RestoreForm struct {
+	bucketName string
+	key        string
+	tier       int
+	days       textinput.Model
+	focus      int
+	error      string
+	next       func() (tea.Model, tea.Cmd)
+	quitting   bool
+}
+
+func InitRestore(bucketName, key string, next func() (tea.Model, tea.Cmd)) (tea.Model, tea.Cmd) {
+	days := textinput.New()
+	days.Prompt = "$ "
+	days.Placeholder = "7"
+	days.CharLimit = 5
+	days.Width = 6
+
+	return RestoreForm{bucketName: bucketName, key: key, tier: 1, days: days, next: next}, nil
+}
+
+func (m RestoreForm) Init() tea.Cmd {
+	return nil
+}
+
+func (m *RestoreForm) setFocus(focus int) tea.Cmd {
+	m.focus = (focus + restoreDaysField + 1) % (restoreDaysField + 1)
+	if m.focus == restoreDaysField {
+		return m.days.Focus()
+	}
+	m.days.Blur()
+	return nil
+}
+
+func (m RestoreForm) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	switch msg := msg.(type) {
+	case storageDoneMsg:
+		if msg.err != nil {
+			m.error = msg.err.Error()
+			return m, nil
+		}
+		return m.next()
+
+	case tea.KeyMsg:
+		switch {
+		case msg.Type == tea.KeyCtrlC:
+			m.quitting = true
+			return m, tea.Quit
+
+		case key.Matches(msg, constants.Keymap.Back):
+			return m.next()
+
+		case key.Matches(msg, constants.Keymap.NextField):
+			return m, m.setFocus(m.focus + 1)
+
+		case key.Matches(msg, constants.Keymap.PrevField):
+			return m, m.setFocus(m.focus - 1)
+
+		case key.Matches(msg, constants.Keymap.Enter):
+			value := m.days.Value()
+			if value == "" {
+				value = m.days.Placeholder
+			}
+			days, err := strconv.Atoi(value)
+			if err != nil || days <= 0 {
+				m.error = "the number of days must be a positive number"
+				return m, nil
+			}
+			m.error = ""
+			return m, m.restoreCmd(object.RestoreTiers[m.tier], int32(days))
+		}
+
+		if m.focus == restoreTierField {
+			switch {
+			case key.Matches(msg, constants.Keymap.Next), msg.Type == tea.KeyRight:
+				m.tier = (m.tier + 1) % len(object.RestoreTiers)
+			case key.Matches(msg, constants.Keymap.Prev), msg.Type == tea.KeyLeft:
+				m.tier = (m.tier - 1 + len(object.RestoreTiers)) % len(object.RestoreTiers)
+			}
+			return m, nil
+		}
+	}
+	m.days, cmd = m.days.Update(msg)
+	return m, cmd
+}
+
+func (m RestoreForm) View() string {
+	if m.quitting {
+		return ""
+	}
+	label := func(field int, name string) string {
+		name = fmt.Sprintf("%-10s", name)
+		if field == m.focus {
+			return constants.SelectedStyle(name)
+		}
+		return name
+	}
+	tier := object.RestoreTiers[m.tier]
+	return constants.DocStyle.Render(lipgloss.JoinVertical(
+		lipgloss.Left,
+		"\n",
+		fmt.Sprintf("Restore s3://%s/%s", m.bucketName, m.key),
+		"",
+		label(restoreTierField, "Tier")+" < "+string(tier)+" > "+constants.HelpStyle(restoreTierHelp[tier]),
+		label(restoreDaysField, "Days")+" "+m.days.View(),
+		constants.HelpStyle("\n tab: next field • ←/→ h/l: change • enter: restore • esc: back\n"),
+		constants.ErrStyle(m.error),
+	))
+}
+
+func (m RestoreForm) restoreCmd(tier types.Tier, days int32) tea.Cmd {
+	return func() tea.Msg {
+		if err := constants.Or.RestoreObject(m.bucketName, m.key, tier, days); err != nil {
+			return storageDoneMsg{fmt.Errorf("[restoreCmd] %v", err)}
+		}
+		return storageDoneMsg{}
+	}
+}
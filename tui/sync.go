@@ -0,0 +1,458 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Wondrous27/s3-tui/object"
+	"github.com/Wondrous27/s3-tui/tui/constants"
+	"github.com/Wondrous27/s3-tui/utils"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Fields of the sync form, in tab order
+const (
+	syncDirectionField = iota
+	syncDirField
+	syncDeleteField
+)
+
+var syncDirections = []object.SyncDirection{object.SyncUp, object.SyncDown}
+
+// syncNouns name the steps of each action in the summary of a plan
+var syncNouns = map[object.SyncAction]string{
+	object.SyncUpload:   "upload",
+	object.SyncDownload: "download",
+	object.SyncDelete:   "deletion",
+}
+
+type syncPlanMsg struct {
+	plan *object.SyncPlan
+	err  error
+}
+
+// syncProgressMsg is a step of the plan that was applied
+type syncProgressMsg object.SyncResult
+
+type syncReportMsg object.SyncReport
+
+// Sync makes a prefix and a local directory match, in either direction. The
+// plan is shown for review and applied only once it is confirmed.
+type Sync struct {
+	bucketName string
+	prefix     string
+	direction  int
+	dir        textinput.Model
+	delete     bool
+	focus      int
+	planning   bool
+	plan       *object.SyncPlan
+	cursor     int
+	offset     int
+	mode       mode
+	isSure     bool
+	applying   bool
+	// results are the errors of the steps applied so far, by step
+	results  map[int]error
+	report   *object.SyncReport
+	error    string
+	next     func() (tea.Model, tea.Cmd)
+	quitting bool
+}
+
+// InitSync asks how to sync the directory prefix of bucketName
+func InitSync(bucketName, prefix string, next func() (tea.Model, tea.Cmd)) (tea.Model, tea.Cmd) {
+	dir := textinput.New()
+	dir.Prompt = "$ "
+	dir.Placeholder, _ = os.Getwd()
+	dir.Width = 50
+	return Sync{bucketName: bucketName, prefix: strings.Trim(prefix, "/"), dir: dir, next: next}, nil
+}
+
+func (m Sync) Init() tea.Cmd {
+	return nil
+}
+
+func (m *Sync) setFocus(focus int) tea.Cmd {
+	m.focus = (focus + syncDeleteField + 1) % (syncDeleteField + 1)
+	if m.focus == syncDirField {
+		return m.dir.Focus()
+	}
+	m.dir.Blur()
+	return nil
+}
+
+// location is the prefix synced as an S3 URI
+func (m Sync) location() string {
+	if m.prefix == "" {
+		return object.URI(m.bucketName, "")
+	}
+	return object.URI(m.bucketName, m.prefix+"/")
+}
+
+// localDir is the directory entered, the working one when it is empty
+func (m Sync) localDir() string {
+	if m.dir.Value() == "" {
+		return m.dir.Placeholder
+	}
+	return m.dir.Value()
+}
+
+func (m Sync) planCmd() tea.Cmd {
+	direction, bucketName, prefix, dir := syncDirections[m.direction], m.bucketName, m.prefix, m.localDir()
+	opts := object.SyncOptions{Delete: m.delete, Exclude: constants.Trash.Hides}
+	return func() tea.Msg {
+		plan, err := constants.Or.PlanSync(direction, bucketName, prefix, dir, opts)
+		if err != nil {
+			return syncPlanMsg{err: fmt.Errorf("[planCmd] %v", err)}
+		}
+		return syncPlanMsg{plan: plan}
+	}
+}
+
+// applyCmd applies the plan, sending a message after every step
+func (m Sync) applyCmd() tea.Cmd {
+	plan := m.plan
+	remove := func(bucket, key string) error {
+		if constants.Trash != nil {
+			_, err := constants.Trash.Delete(bucket, key)
+			return err
+		}
+		return constants.Or.DeleteObject(bucket, key)
+	}
+	return func() tea.Msg {
+		report := constants.Or.ApplySync(context.Background(), plan, 0, remove, func(result object.SyncResult) {
+			constants.P.Send(syncProgressMsg(result))
+		})
+		return syncReportMsg(report)
+	}
+}
+
+// step returns the index of op in the plan
+func (m Sync) step(op object.SyncOp) int {
+	for i, planned := range m.plan.Ops {
+		if planned.Action == op.Action && planned.Key == op.Key && planned.Path == op.Path {
+			return i
+		}
+	}
+	return -1
+}
+
+// height is how many steps fit between the header and the help
+func (m Sync) height() int {
+	height := constants.WindowSize.Height - 12
+	if m.plan != nil && len(m.plan.Skipped) > 0 {
+		height -= min(len(m.plan.Skipped), skippedShown+1) + 1
+	}
+	return max(height, 3)
+}
+
+func (m *Sync) moveCursor(cursor int) {
+	if len(m.plan.Ops) == 0 {
+		m.cursor, m.offset = 0, 0
+		return
+	}
+	m.cursor = min(max(cursor, 0), len(m.plan.Ops)-1)
+	switch {
+	case m.cursor < m.offset:
+		m.offset = m.cursor
+	case m.cursor >= m.offset+m.height():
+		m.offset = m.cursor - m.height() + 1
+	}
+}
+
+func (m Sync) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		constants.WindowSize = msg
+		return m, nil
+
+	case syncPlanMsg:
+		m.planning = false
+		if msg.err != nil {
+			m.error = msg.err.Error()
+			return m, nil
+		}
+		m.plan, m.cursor, m.offset, m.error = msg.plan, 0, 0, ""
+		return m, nil
+
+	case syncProgressMsg:
+		m.results[m.step(msg.Op)] = msg.Err
+		return m, nil
+
+	case syncReportMsg:
+		report := object.SyncReport(msg)
+		m.applying, m.report = false, &report
+		return m, nil
+
+	case tea.KeyMsg:
+		if msg.Type == tea.KeyCtrlC {
+			m.quitting = true
+			return m, tea.Quit
+		}
+		switch {
+		case m.applying, m.planning:
+			return m, nil
+		case m.mode == del:
+			return m.updateConfirmation(msg)
+		case m.plan != nil:
+			return m.updatePlan(msg)
+		}
+		return m.updateForm(msg)
+	}
+	return m, nil
+}
+
+// updateForm edits how to sync until the plan is asked for
+func (m Sync) updateForm(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, constants.Keymap.Back):
+		return m.next()
+
+	case key.Matches(msg, constants.Keymap.NextField):
+		return m, m.setFocus(m.focus + 1)
+
+	case key.Matches(msg, constants.Keymap.PrevField):
+		return m, m.setFocus(m.focus - 1)
+
+	case key.Matches(msg, constants.Keymap.Enter):
+		m.planning, m.error = true, ""
+		return m, m.planCmd()
+	}
+
+	switch m.focus {
+	case syncDirectionField, syncDeleteField:
+		switch {
+		case key.Matches(msg, constants.Keymap.Next), key.Matches(msg, constants.Keymap.Prev), msg.Type == tea.KeyRight, msg.Type == tea.KeyLeft:
+			if m.focus == syncDirectionField {
+				m.direction = (m.direction + 1) % len(syncDirections)
+			} else {
+				m.delete = !m.delete
+			}
+		}
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.dir, cmd = m.dir.Update(msg)
+	return m, cmd
+}
+
+// updatePlan scrolls through the plan, and asks to apply it
+func (m Sync) updatePlan(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, constants.Keymap.Quit):
+		m.quitting = true
+		return m, tea.Quit
+
+	case key.Matches(msg, constants.Keymap.Back):
+		if m.report != nil {
+			return m.next()
+		}
+		// Back to the form, to change the sync
+		m.plan = nil
+		return m, nil
+
+	case key.Matches(msg, constants.Keymap.Up):
+		m.moveCursor(m.cursor - 1)
+
+	case key.Matches(msg, constants.Keymap.Down):
+		m.moveCursor(m.cursor + 1)
+
+	case key.Matches(msg, constants.Keymap.Enter):
+		if m.report != nil || len(m.plan.Ops) == 0 {
+			return m.next()
+		}
+		m.mode, m.isSure = del, false
+	}
+	return m, nil
+}
+
+func (m Sync) updateConfirmation(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, constants.Keymap.Quit):
+		m.quitting = true
+		return m, tea.Quit
+
+	case key.Matches(msg, constants.Keymap.Enter):
+		m.mode = nav
+		if m.isSure {
+			m.applying, m.results = true, map[int]error{}
+			return m, m.applyCmd()
+		}
+
+	case key.Matches(msg, constants.Keymap.Next), key.Matches(msg, constants.Keymap.Prev):
+		m.isSure = !m.isSure
+	}
+	return m, nil
+}
+
+func (m Sync) View() string {
+	if m.quitting {
+		return ""
+	}
+	if m.mode == del {
+		return confirmationDialog(m.question(), m.isSure)
+	}
+	if m.plan == nil {
+		return m.formView()
+	}
+
+	direction := m.plan.Dir + " → " + m.location()
+	if m.plan.Direction == object.SyncDown {
+		direction = m.location() + " → " + m.plan.Dir
+	}
+	rows := []string{"\n", "Sync " + direction, constants.HelpStyle(m.summary()), ""}
+	if skipped := m.skippedView(); skipped != "" {
+		rows = append(rows, skipped, "")
+	}
+	switch {
+	case m.report != nil:
+		rows = append(rows, m.reportView(), "")
+	case m.applying:
+		rows = append(rows, constants.AlertStyle(fmt.Sprintf("applied %d of %d steps...", len(m.results), len(m.plan.Ops))), "")
+	case len(m.plan.Ops) == 0 && len(m.plan.Skipped) == 0:
+		rows = append(rows, "everything is in sync")
+	}
+	for i := m.offset; i < len(m.plan.Ops) && i < m.offset+m.height(); i++ {
+		rows = append(rows, m.stepView(i))
+	}
+
+	help := "\n ↑/↓ k/j: scroll • enter: apply • esc: change the sync\n"
+	switch {
+	case m.applying:
+		help = "\n applying the plan...\n"
+	case m.report != nil, len(m.plan.Ops) == 0:
+		help = "\n enter/esc: back\n"
+	}
+	rows = append(rows, constants.HelpStyle(help), constants.ErrStyle(m.error))
+	return constants.DocStyle.Render(lipgloss.JoinVertical(lipgloss.Left, rows...))
+}
+
+// summary counts the steps of the plan by action
+func (m Sync) summary() string {
+	var parts []string
+	for _, action := range []object.SyncAction{object.SyncUpload, object.SyncDownload, object.SyncDelete} {
+		if n, size := m.plan.Count(action); n > 0 {
+			parts = append(parts, fmt.Sprintf("%s (%s)", plural(n, syncNouns[action]), utils.HumanSize(size)))
+		}
+	}
+	parts = append(parts, fmt.Sprintf("%d unchanged", m.plan.Unchanged))
+	if n := len(m.plan.Skipped); n > 0 {
+		parts = append(parts, fmt.Sprintf("%d skipped", n))
+	}
+	return strings.Join(parts, " • ")
+}
+
+// skippedShown is how many of the objects a plan leaves out are listed
+const skippedShown = 3
+
+// skippedView lists the first objects the plan leaves out
+func (m Sync) skippedView() string {
+	if len(m.plan.Skipped) == 0 {
+		return ""
+	}
+	var lines []string
+	for i, op := range m.plan.Skipped {
+		if i == skippedShown {
+			lines = append(lines, fmt.Sprintf("and %d more", len(m.plan.Skipped)-skippedShown))
+			break
+		}
+		lines = append(lines, fmt.Sprintf("skipped %s: %s", op.Key, op.Reason))
+	}
+	return constants.ErrStyle(strings.Join(lines, "\n"))
+}
+
+// question asks to apply the plan, warning about what it deletes
+func (m Sync) question() string {
+	question := fmt.Sprintf("Apply the %s of the plan?", plural(len(m.plan.Ops), "step"))
+	n, _ := m.plan.Count(object.SyncDelete)
+	switch {
+	case n == 0:
+		return question
+	case m.plan.Direction == object.SyncDown:
+		return question + fmt.Sprintf("\nIt deletes %s from %s.", plural(n, "local file"), m.plan.Dir)
+	case constants.Trash != nil:
+		return question + fmt.Sprintf("\nIt moves %s to the trash.", plural(n, "object"))
+	}
+	return question + fmt.Sprintf("\nIt deletes %s permanently.", plural(n, "object"))
+}
+
+func (m Sync) stepView(i int) string {
+	op := m.plan.Ops[i]
+	name := strings.TrimPrefix(op.Key, m.plan.Prefix)
+	if op.Key == "" {
+		name = op.Path
+	}
+	var mark string
+	switch op.Action {
+	case object.SyncUpload:
+		mark = constants.DirStyle("↑ upload  ")
+	case object.SyncDownload:
+		mark = constants.DirStyle("↓ download")
+	default:
+		mark = constants.ErrStyle("- delete  ")
+	}
+	status := "  "
+	if err, ok := m.results[i]; ok {
+		status = constants.DirStyle("✓ ")
+		if err != nil {
+			status = constants.ErrStyle("! ")
+		}
+	}
+	cursor := "  "
+	if i == m.cursor {
+		cursor = "> "
+		name = constants.SelectedStyle(name)
+	}
+	return fmt.Sprintf("%s%s%s %10s  %s  %s", cursor, status, mark, utils.HumanSize(op.Size), name, constants.HelpStyle(op.Reason))
+}
+
+func (m Sync) reportView() string {
+	r := m.report
+	lines := []string{constants.AlertStyle(fmt.Sprintf("%d uploaded, %d downloaded, %d deleted, %s in %s",
+		r.Uploaded, r.Downloaded, r.Deleted, utils.HumanSize(r.Bytes), r.Elapsed.Round(time.Millisecond)))}
+	if len(r.Failed) > 0 {
+		lines = append(lines, constants.ErrStyle(fmt.Sprintf("%d failed:", len(r.Failed))))
+		for _, failed := range r.Failed {
+			lines = append(lines, constants.ErrStyle(fmt.Sprintf("  %s: %v", failed.Op.Action, failed.Err)))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (m Sync) formView() string {
+	label := func(field int, name string) string {
+		name = fmt.Sprintf("%-16s", name)
+		if field == m.focus {
+			return constants.SelectedStyle(name)
+		}
+		return name
+	}
+	deletes := "no"
+	if m.delete {
+		deletes = "yes, what is only at the destination"
+	}
+	rows := []string{
+		"\n",
+		"Sync " + m.location(),
+		"",
+		label(syncDirectionField, "Direction") + " < " + syncDirections[m.direction].String() + " >",
+		label(syncDirField, "Local directory") + " " + m.dir.View(),
+		label(syncDeleteField, "Delete") + " < " + deletes + " >",
+	}
+	if m.planning {
+		rows = append(rows, "", constants.AlertStyle("comparing..."))
+	}
+	rows = append(rows,
+		constants.HelpStyle("\n files are compared by size, MD5 and modification time, nothing is changed before the plan is applied"),
+		constants.HelpStyle("\n tab: next field • ←/→ h/l: change • enter: plan • esc: back\n"),
+		constants.ErrStyle(m.error),
+	)
+	return constants.DocStyle.Render(lipgloss.JoinVertical(lipgloss.Left, rows...))
+}
@@ -0,0 +1,165 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/Wondrous27/s3-tui/bucket"
+	"github.com/Wondrous27/s3-tui/tui/constants"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type updatedTagsMsg struct {
+	tags map[string]string
+	err  error
+}
+
+// Tags shows the tags of an object and edits them as YAML in $EDITOR
+type Tags struct {
+	bucketName string
+	key        string
+	viewport   viewport.Model
+	tags       map[string]string
+	// draft keeps an edit that failed to save, so it is not lost
+	draft string
+	// offered is the document given to the editor, saved only once changed
+	offered  string
+	error    string
+	next     func() (tea.Model, tea.Cmd)
+	quitting bool
+}
+
+func InitTags(bucketName, key string, next func() (tea.Model, tea.Cmd)) (tea.Model, tea.Cmd) {
+	m := Tags{bucketName: bucketName, key: key, next: next}
+	top, right, bottom, left := constants.DocStyle.GetMargin()
+	m.viewport = viewport.New(constants.WindowSize.Width-left-right, constants.WindowSize.Height-top-bottom-7)
+	return m, m.getTagsCmd()
+}
+
+func (m Tags) Init() tea.Cmd {
+	return nil
+}
+
+func (m Tags) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		constants.WindowSize = msg
+		top, right, bottom, left := constants.DocStyle.GetMargin()
+		m.viewport = viewport.New(msg.Width-left-right, msg.Height-top-bottom-7)
+		m.setViewportContent()
+
+	case updatedTagsMsg:
+		if msg.err != nil {
+			m.error = msg.err.Error()
+			return m, nil
+		}
+		m.tags, m.draft, m.error = msg.tags, "", ""
+		m.setViewportContent()
+		return m, nil
+
+	case editorFinishedMsg:
+		if msg.err != nil {
+			m.error = msg.err.Error()
+			return m, nil
+		}
+		if msg.content == m.offered {
+			return m, nil
+		}
+		m.draft = msg.content
+		var tags map[string]string
+		if err := bucket.DecodeDocument(m.draft, "yaml", &tags); err != nil && !errors.Is(err, io.EOF) {
+			m.error = fmt.Sprintf("the tags were not saved, press e to fix them:\n%v", err)
+			return m, nil
+		}
+		return m, m.putTagsCmd(tags)
+
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, constants.Keymap.Quit):
+			m.quitting = true
+			return m, tea.Quit
+
+		case key.Matches(msg, constants.Keymap.Back):
+			return m.next()
+
+		case key.Matches(msg, constants.Keymap.Edit):
+			content := m.draft
+			if content == "" {
+				tags := m.tags
+				if len(tags) == 0 {
+					tags = map[string]string{"classification": ""}
+				}
+				var err error
+				if content, err = bucket.EncodeDocument(tags, "yaml"); err != nil {
+					m.error = err.Error()
+					return m, nil
+				}
+			}
+			m.offered = content
+			return m, openEditorCmd(content, ".yaml")
+		}
+	}
+	m.viewport, cmd = m.viewport.Update(msg)
+	return m, cmd
+}
+
+func (m *Tags) setViewportContent() {
+	if len(m.tags) == 0 {
+		m.viewport.SetContent(constants.AlertStyle("This object has no tags, press e to add some."))
+		return
+	}
+	content, err := bucket.EncodeDocument(m.tags, "yaml")
+	if err != nil {
+		m.error = err.Error()
+		return
+	}
+	str, err := renderFile("tags.yaml", content)
+	if err != nil {
+		m.error = "could not render the tags"
+		return
+	}
+	str, _ = constants.FormatLineNumber(str, true)
+	m.viewport.SetContent(str)
+}
+
+func (m Tags) View() string {
+	if m.quitting {
+		return ""
+	}
+	return constants.DocStyle.Render(lipgloss.JoinVertical(
+		lipgloss.Left,
+		"\n",
+		fmt.Sprintf("tags of %s", m.key),
+		m.viewport.View(),
+		constants.ShortHelp(
+			constants.Keymap.Back,
+			constants.Keymap.Edit,
+			constants.Keymap.Quit,
+		),
+		constants.ErrStyle(m.error),
+	))
+}
+
+func (m Tags) getTagsCmd() tea.Cmd {
+	return func() tea.Msg {
+		tags, err := constants.Or.GetObjectTags(m.bucketName, m.key)
+		if err != nil {
+			return updatedTagsMsg{err: fmt.Errorf("[getTagsCmd] %v", err)}
+		}
+		return updatedTagsMsg{tags: tags}
+	}
+}
+
+func (m Tags) putTagsCmd(tags map[string]string) tea.Cmd {
+	return func() tea.Msg {
+		if err := constants.Or.PutObjectTags(m.bucketName, m.key, tags); err != nil {
+			return updatedTagsMsg{err: fmt.Errorf("[putTagsCmd] %v", err)}
+		}
+		return m.getTagsCmd()()
+	}
+}
@@ -0,0 +1,175 @@
+package tui
+
+import (
+	"fmt"
+
+	"github.com/Wondrous27/s3-tui/bucket"
+	"github.com/Wondrous27/s3-tui/trash"
+	"github.com/Wondrous27/s3-tui/tui/constants"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type trashItem struct{ trash.Item }
+
+// Implement the `Item` interface
+func (t trashItem) Title() string { return t.Key }
+
+func (t trashItem) Description() string {
+	return fmt.Sprintf("Deleted: %v • %d bytes • %s",
+		t.DeletedAt.Local().Format(bucket.DDMMYYYYhhmmss), t.Size, t.Location)
+}
+
+func (t trashItem) FilterValue() string { return t.Key }
+
+type updatedTrashMsg struct {
+	items []list.Item
+	err   error
+}
+
+// TrashView lists the trashed objects of a bucket to restore or purge them
+type TrashView struct {
+	bucketName string
+	list       list.Model
+	next       func() (tea.Model, tea.Cmd)
+	mode       mode
+	isSure     bool
+	error      string
+	quitting   bool
+}
+
+func InitTrash(bucketName string, next func() (tea.Model, tea.Cmd)) (tea.Model, tea.Cmd) {
+	m := TrashView{
+		bucketName: bucketName,
+		list:       list.New(nil, list.NewDefaultDelegate(), 8, 8),
+		next:       next,
+	}
+	if constants.WindowSize.Height != 0 {
+		top, right, bottom, left := constants.DocStyle.GetMargin()
+		m.list.SetSize(constants.WindowSize.Width-left-right, constants.WindowSize.Height-top-bottom-1)
+	}
+	m.list.Title = fmt.Sprintf("trash of %s", bucketName)
+	m.list.AdditionalShortHelpKeys = func() []key.Binding {
+		return []key.Binding{
+			constants.Keymap.Restore,
+			constants.Keymap.Delete,
+			constants.Keymap.Back,
+		}
+	}
+	return m, m.listTrashCmd()
+}
+
+func (m TrashView) Init() tea.Cmd {
+	return nil
+}
+
+func (m TrashView) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		constants.WindowSize = msg
+		top, right, bottom, left := constants.DocStyle.GetMargin()
+		m.list.SetSize(msg.Width-left-right, msg.Height-top-bottom-1)
+
+	case updatedTrashMsg:
+		if msg.err != nil {
+			m.error = msg.err.Error()
+			return m, nil
+		}
+		m.error = ""
+		return m, m.list.SetItems(msg.items)
+
+	case tea.KeyMsg:
+		if m.list.FilterState() == list.Filtering {
+			break
+		}
+		if m.mode == del {
+			switch {
+			case key.Matches(msg, constants.Keymap.Quit):
+				m.quitting = true
+				return m, tea.Quit
+
+			case key.Matches(msg, constants.Keymap.Enter):
+				m.mode = nav
+				if m.isSure {
+					return m, m.purgeCmd(m.list.SelectedItem().(trashItem).Item)
+				}
+
+			case key.Matches(msg, constants.Keymap.Next), key.Matches(msg, constants.Keymap.Prev):
+				m.isSure = !m.isSure
+			}
+			return m, nil
+		}
+
+		switch {
+		case key.Matches(msg, constants.Keymap.Quit):
+			m.quitting = true
+			return m, tea.Quit
+
+		case key.Matches(msg, constants.Keymap.Restore):
+			if item, ok := m.list.SelectedItem().(trashItem); ok {
+				return m, m.restoreCmd(item.Item)
+			}
+
+		case key.Matches(msg, constants.Keymap.Delete):
+			if _, ok := m.list.SelectedItem().(trashItem); ok {
+				m.isSure = false
+				m.mode = del
+			}
+			return m, nil
+
+		case key.Matches(msg, constants.Keymap.Back):
+			if m.list.FilterState() == list.FilterApplied {
+				break
+			}
+			return m.next()
+		}
+	}
+	m.list, cmd = m.list.Update(msg)
+	return m, cmd
+}
+
+func (m TrashView) View() string {
+	if m.quitting {
+		return ""
+	}
+	if m.mode == del {
+		item := m.list.SelectedItem().(trashItem)
+		msg := fmt.Sprintf("Permanently delete the trashed copy of %s?", item.Key)
+		return confirmationDialog(msg, m.isSure)
+	}
+	return constants.DocStyle.Render(m.list.View() + "\n" + constants.ErrStyle(m.error))
+}
+
+func (m TrashView) listTrashCmd() tea.Cmd {
+	return func() tea.Msg {
+		items, err := constants.Trash.List(m.bucketName)
+		if err != nil {
+			return updatedTrashMsg{err: err}
+		}
+		listItems := make([]list.Item, 0, len(items))
+		for _, item := range items {
+			listItems = append(listItems, trashItem{item})
+		}
+		return updatedTrashMsg{items: listItems}
+	}
+}
+
+func (m TrashView) restoreCmd(item trash.Item) tea.Cmd {
+	return func() tea.Msg {
+		if err := constants.Trash.Restore(item); err != nil {
+			return updatedTrashMsg{err: fmt.Errorf("[restoreCmd] cannot restore %s %v", item.Key, err)}
+		}
+		return m.listTrashCmd()()
+	}
+}
+
+func (m TrashView) purgeCmd(item trash.Item) tea.Cmd {
+	return func() tea.Msg {
+		if err := constants.Trash.Purge(item); err != nil {
+			return updatedTrashMsg{err: fmt.Errorf("[purgeCmd] cannot purge %s %v", item.Key, err)}
+		}
+		return m.listTrashCmd()()
+	}
+}
@@ -1,14 +1,19 @@
 package tui
 
 import (
+	"fmt"
 	"path/filepath"
+	"slices"
 	"strings"
 
+	"github.com/Wondrous27/s3-tui/object"
 	"github.com/Wondrous27/s3-tui/tree"
 	"github.com/Wondrous27/s3-tui/tui/constants"
+	"github.com/Wondrous27/s3-tui/utils"
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 )
 
 type UpdatedTree *Tree
@@ -20,7 +25,23 @@ type Tree struct {
 	cursor       int
 	input        textinput.Model
 	mode         mode
+	isSure       bool
+	error        string
 	NewObjectKey string
+	// filter asks for a tag filter, tagFilter is the one the tree shows
+	filter    textinput.Model
+	tagFilter object.TagFilter
+	// status tells that a slow command is running
+	status string
+	// NewObjectEncryption is how the object being created is encrypted
+	NewObjectEncryption object.Encryption
+	// upload asks for a local file to upload into the current directory
+	upload textinput.Model
+	// search edits nameFilter, which narrows the children of the current
+	// directory, as a regular expression when searchRegex is set
+	search      textinput.Model
+	searchRegex bool
+	nameFilter  tree.NameFilter
 }
 
 func (f Tree) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -28,14 +49,119 @@ func (f Tree) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	// TODO: Implement custom window resizing
 	case tea.WindowSizeMsg:
+		constants.WindowSize = msg
 
 	case editorFinishedMsg:
-		cmds = append(cmds, f.createObjectCommand(msg.file.Name(), f.NewObjectKey))
+		if msg.err != nil {
+			f.error = msg.err.Error()
+			break
+		}
+		cmds = append(cmds, f.createObjectCommand(msg.content, f.NewObjectKey, f.NewObjectEncryption))
 
 	case UpdatedTree:
 		f = *msg
 
+	case errMsg:
+		f.error = msg.Error()
+		f.status = ""
+
 	case tea.KeyMsg:
+		if f.mode == del {
+			switch {
+			case key.Matches(msg, constants.Keymap.Quit):
+				f.quitting = true
+				return f, tea.Quit
+
+			case key.Matches(msg, constants.Keymap.Enter):
+				f.mode = nav
+				if f.isSure {
+					return f, f.deleteObjectCmd(f.children()[f.cursor].Path())
+				}
+				return f, nil
+
+			case key.Matches(msg, constants.Keymap.Next), key.Matches(msg, constants.Keymap.Prev):
+				f.isSure = !f.isSure
+			}
+			return f, nil
+		}
+
+		if f.filter.Focused() {
+			switch {
+			case key.Matches(msg, constants.Keymap.Back):
+				f.filter.Blur()
+				return f, nil
+
+			case key.Matches(msg, constants.Keymap.Enter):
+				filter, err := object.ParseTagFilter(f.filter.Value())
+				if err != nil {
+					f.error = err.Error()
+					return f, nil
+				}
+				f.filter.Blur()
+				f.error = ""
+				f.status = fmt.Sprintf("fetching the tags under %s/...", f.Root.Path())
+				return f, f.tagFilterCmd(filter)
+			}
+			f.filter, cmd = f.filter.Update(msg)
+			return f, cmd
+		}
+
+		if f.search.Focused() {
+			switch {
+			case key.Matches(msg, constants.Keymap.Back):
+				f.clearSearch()
+				return f, nil
+
+			case key.Matches(msg, constants.Keymap.Enter):
+				f.search.Blur()
+				return f, nil
+
+			case key.Matches(msg, constants.Keymap.NextField):
+				f.searchRegex = !f.searchRegex
+				f.applySearch()
+				return f, nil
+
+			case msg.Type == tea.KeyUp, msg.Type == tea.KeyDown:
+				if n := len(f.children()); n > 0 {
+					step := 1
+					if msg.Type == tea.KeyUp {
+						step = -1
+					}
+					f.cursor = (f.cursor + step + n) % n
+				}
+				return f, nil
+			}
+			f.search, cmd = f.search.Update(msg)
+			f.applySearch()
+			return f, cmd
+		}
+
+		if f.upload.Focused() {
+			switch {
+			case key.Matches(msg, constants.Keymap.Back):
+				f.upload.Blur()
+				return f, nil
+
+			case key.Matches(msg, constants.Keymap.Enter):
+				file := f.upload.Value()
+				if file == "" {
+					return f, nil
+				}
+				s3Key := filepath.Base(file)
+				if dir := f.Root.Path(); dir != "" {
+					s3Key = dir + "/" + s3Key
+				}
+				f.upload.SetValue("")
+				f.upload.Blur()
+				return InitEncryption(f.BucketName, s3Key, func(enc object.Encryption) (tea.Model, tea.Cmd) {
+					f.status = fmt.Sprintf("uploading %s to %s...", file, s3Key)
+					return f, f.uploadCmd(file, s3Key, enc)
+				}, func() (tea.Model, tea.Cmd) { return f, nil })
+			}
+			f.upload, cmd = f.upload.Update(msg)
+			return f, cmd
+		}
+
 		if f.input.Focused() {
 			if key.Matches(msg, constants.Keymap.Back) {
 				f.input.SetValue("")
@@ -50,7 +176,10 @@ func (f Tree) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				f.input.SetValue("")
 				f.mode = nav
 				f.input.Blur()
-				return f, openEditorCmd("", extension)
+				return InitEncryption(f.BucketName, s3Key, func(enc object.Encryption) (tea.Model, tea.Cmd) {
+					f.NewObjectEncryption = enc
+					return f, openEditorCmd("", extension)
+				}, func() (tea.Model, tea.Cmd) { return f, nil })
 			}
 
 			f.input, cmd = f.input.Update(msg)
@@ -66,29 +195,136 @@ func (f Tree) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				f.input.Focus()
 				cmd = textinput.Blink
 
+			case key.Matches(msg, constants.Keymap.Upload):
+				return f, f.upload.Focus()
+
+			case key.Matches(msg, constants.Keymap.Search):
+				f.search.SetValue(f.nameFilter.Pattern)
+				f.search.CursorEnd()
+				return f, f.search.Focus()
+
+			case key.Matches(msg, constants.Keymap.Delete):
+				if len(f.children()) == 0 {
+					return f, nil
+				}
+				if f.children()[f.cursor].IsDir {
+					f.error = "only objects can be deleted"
+					return f, nil
+				}
+				f.error = ""
+				f.isSure = false
+				f.mode = del
+
+			case key.Matches(msg, constants.Keymap.Presign):
+				if len(f.children()) == 0 || f.children()[f.cursor].IsDir {
+					return f, nil
+				}
+				return InitPresign(f.BucketName, f.children()[f.cursor].Path(), func() (tea.Model, tea.Cmd) { return f, nil })
+
+			case key.Matches(msg, constants.Keymap.Copy):
+				if len(f.children()) == 0 {
+					return f, nil
+				}
+				curr := f.children()[f.cursor]
+				return InitCopyMenu(f.BucketName, curr.Path(), curr.IsDir, func() (tea.Model, tea.Cmd) { return f, nil })
+
+			case key.Matches(msg, constants.Keymap.TagFilter):
+				f.filter.SetValue(f.tagFilter.String())
+				f.filter.CursorEnd()
+				return f, f.filter.Focus()
+
+			case key.Matches(msg, constants.Keymap.Find):
+				var keys []string
+				if f.tagFilter == nil {
+					root := f.Root
+					for root.Parent != root {
+						root = root.Parent
+					}
+					keys = treeKeys(root)
+				}
+				return InitFinder(f.BucketName, keys, func() (tea.Model, tea.Cmd) { return f, nil })
+
+			case key.Matches(msg, constants.Keymap.Sort):
+				i := slices.Index(tree.SortFields, constants.TreeSort.Field)
+				constants.TreeSort.Field = tree.SortFields[(i+1)%len(tree.SortFields)]
+				f.resort()
+				return f, nil
+
+			case key.Matches(msg, constants.Keymap.Reverse):
+				constants.TreeSort.Descending = !constants.TreeSort.Descending
+				f.resort()
+				return f, nil
+
+			case key.Matches(msg, constants.Keymap.DirsFirst):
+				constants.TreeSort.DirsFirst = !constants.TreeSort.DirsFirst
+				f.resort()
+				return f, nil
+
+			case key.Matches(msg, constants.Keymap.Usage):
+				return InitUsage(f.BucketName, f.Root.Path(), func() (tea.Model, tea.Cmd) { return f, nil })
+
+			case key.Matches(msg, constants.Keymap.Grep):
+				return InitGrep(f.BucketName, f.Root.Path(), func() (tea.Model, tea.Cmd) { return f, nil })
+
+			case key.Matches(msg, constants.Keymap.Sync):
+				bucketName, prefix := f.BucketName, f.Root.Path()
+				return InitSync(bucketName, prefix, func() (tea.Model, tea.Cmd) {
+					// The sync may have changed the prefix
+					return openTree(bucketName, prefix, f)
+				})
+
+			case key.Matches(msg, constants.Keymap.Undo):
+				return f, f.undoDeleteCmd()
+
+			case key.Matches(msg, constants.Keymap.Trash):
+				if constants.Trash == nil {
+					f.error = "the trash is not enabled in the config"
+					return f, nil
+				}
+				return InitTrash(f.BucketName, func() (tea.Model, tea.Cmd) {
+					return f, func() tea.Msg { return f.setupTree(f.BucketName) }
+				})
+
 			case key.Matches(msg, constants.Keymap.Up):
-				f.cursor = (f.cursor - 1 + len(f.Root.Children)) % len(f.Root.Children)
+				if len(f.children()) == 0 {
+					return f, nil
+				}
+				f.cursor = (f.cursor - 1 + len(f.children())) % len(f.children())
 				return f, nil
 
 			case key.Matches(msg, constants.Keymap.Down):
-				f.cursor = (f.cursor + 1) % len(f.Root.Children)
+				if len(f.children()) == 0 {
+					return f, nil
+				}
+				f.cursor = (f.cursor + 1) % len(f.children())
 				return f, nil
 
 			case key.Matches(msg, constants.Keymap.Enter), key.Matches(msg, constants.Keymap.Next):
-				if len(f.Root.Children) == 0 {
+				if len(f.children()) == 0 {
 					return f, nil
 				}
 
-				curr := f.Root.Children[f.cursor]
+				curr := f.children()[f.cursor]
 				if !curr.IsDir {
-					key := getPath(curr)
+					key := curr.Path()
 					return InitObject(f.BucketName, key)
 				}
 				f.Root = curr
 				f.cursor = 0
+				f.clearSearch()
 				return f, nil
 
+			case key.Matches(msg, constants.Keymap.History):
+				return InitHistory(func() (tea.Model, tea.Cmd) { return f, nil })
+
 			case key.Matches(msg, constants.Keymap.Back):
+				if f.nameFilter.Pattern != "" {
+					f.clearSearch()
+					return f, nil
+				}
+				if f.tagFilter != nil {
+					return f, func() tea.Msg { return f.setupTree(f.BucketName) }
+				}
 				return InitBuckets()
 
 			case key.Matches(msg, constants.Keymap.Prev):
@@ -97,6 +333,7 @@ func (f Tree) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 				f.Root = f.Root.Parent
 				f.cursor = 0
+				f.clearSearch()
 				return f, nil
 
 			}
@@ -107,9 +344,34 @@ func (f Tree) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 // TODO: make this prettier
 func (f Tree) View() string {
+	if f.mode == del {
+		return confirmationDialog(deleteQuestion(f.children()[f.cursor].Path()), f.isSure)
+	}
+
 	var sb strings.Builder
-	for i, child := range f.Root.Children {
-		cursor := " "
+	if constants.TreeSort != tree.DefaultSort {
+		sb.WriteString(constants.HelpStyle("sorted by " + constants.TreeSort.String()))
+		sb.WriteString("\n\n")
+	}
+	if f.tagFilter != nil {
+		sb.WriteString(constants.AlertStyle(fmt.Sprintf("tags %s, esc clears the filter", f.tagFilter)))
+		sb.WriteString("\n\n")
+		if len(f.Root.Children) == 0 {
+			sb.WriteString("no objects match\n\n")
+		}
+	}
+	if f.nameFilter.Pattern != "" {
+		sb.WriteString(constants.AlertStyle(fmt.Sprintf("%s %q matches %d of %d, esc clears the filter",
+			searchMode(f.nameFilter.Regex), f.nameFilter.Pattern, len(f.children()), len(f.Root.Children))))
+		sb.WriteString("\n\n")
+	}
+	nameWidth := 0
+	for _, child := range f.children() {
+		nameWidth = max(nameWidth, lipgloss.Width(child.Name))
+	}
+	nameWidth = min(nameWidth, maxNameWidth)
+	for i, child := range f.children() {
+		cursor := "  "
 		isSelected := false
 		if i == f.cursor {
 			cursor = "> "
@@ -117,12 +379,46 @@ func (f Tree) View() string {
 		}
 		sb.WriteString(cursor)
 		sb.WriteString(styledFileName(child.IsDir, isSelected, child.Name))
+		sb.WriteString(strings.Repeat(" ", max(nameWidth-lipgloss.Width(child.Name), 0)))
+		sb.WriteString(constants.HelpStyle(nodeColumns(child)))
 		sb.WriteString("\n\n")
 	}
 
-	sb.WriteString(constants.HelpStyle(
-		"\n ↑/↓ h/j/k/l: navigate • esc: back • c: create object • q: quit\n",
+	sb.WriteString(constants.ShortHelp(
+		constants.Keymap.Back,
+		constants.Keymap.Create,
+		constants.Keymap.Upload,
+		constants.Keymap.Delete,
+		constants.Keymap.Presign,
+		constants.Keymap.Copy,
+		constants.Keymap.TagFilter,
+		constants.Keymap.Search,
+		constants.Keymap.Find,
+		constants.Keymap.Grep,
+		constants.Keymap.Sync,
+		constants.Keymap.Usage,
+		constants.Keymap.Sort,
+		constants.Keymap.Reverse,
+		constants.Keymap.DirsFirst,
+		constants.Keymap.Undo,
+		constants.Keymap.Trash,
+		constants.Keymap.History,
+		constants.Keymap.Quit,
 	))
+	sb.WriteString(constants.ErrStyle(f.error))
+	if f.status != "" {
+		sb.WriteString(constants.AlertStyle(f.status))
+	}
+	if f.filter.Focused() {
+		return constants.DocStyle.Render(sb.String() + "\n" + f.filter.View())
+	}
+	if f.upload.Focused() {
+		return constants.DocStyle.Render(sb.String() + "\n" + f.upload.View())
+	}
+	if f.search.Focused() {
+		return constants.DocStyle.Render(sb.String() + "\n" + f.search.View() +
+			constants.HelpStyle("  tab: glob/regex • enter: keep • esc: clear"))
+	}
 	if f.input.Focused() {
 		// TODO: Find new style to render this
 		return constants.DocStyle.Render(sb.String() + "\n" + f.input.View())
@@ -130,6 +426,75 @@ func (f Tree) View() string {
 	return constants.DocStyle.Render(sb.String())
 }
 
+// children are the children of the current directory the name filter keeps
+func (f Tree) children() []*tree.Node {
+	return f.nameFilter.Filter(f.Root.Children)
+}
+
+// applySearch filters the current directory with the pattern typed so far,
+// keeping the previous filter while the pattern does not compile
+func (f *Tree) applySearch() {
+	f.search.Prompt = searchMode(f.searchRegex) + "/"
+	filter, err := tree.ParseNameFilter(f.search.Value(), f.searchRegex)
+	if err != nil {
+		f.error = fmt.Sprintf("invalid %s: %v", searchMode(f.searchRegex), err)
+		return
+	}
+	f.error = ""
+	f.nameFilter = filter
+	f.cursor = 0
+}
+
+func (f *Tree) clearSearch() {
+	f.search.Blur()
+	f.search.SetValue("")
+	f.nameFilter = tree.NameFilter{}
+	f.cursor = 0
+}
+
+func searchMode(regex bool) string {
+	if regex {
+		return "regex"
+	}
+	return "glob"
+}
+
+// resort sorts the whole tree in constants.TreeSort, keeping the cursor on
+// the same node
+func (f *Tree) resort() {
+	var selected *tree.Node
+	if len(f.children()) > 0 {
+		selected = f.children()[f.cursor]
+	}
+	root := f.Root
+	for root.Parent != root {
+		root = root.Parent
+	}
+	root.SortBy(constants.TreeSort)
+	f.cursor = max(slices.Index(f.children(), selected), 0)
+}
+
+// maxNameWidth is the widest a name gets aligned to, longer ones push their
+// columns further right
+const maxNameWidth = 40
+
+// nodeColumns renders the size, last-modified and storage class of a file, or
+// the total size and count of a directory
+func nodeColumns(node *tree.Node) string {
+	if node.IsDir {
+		count := fmt.Sprintf("%d objects", node.Count)
+		if node.Count == 1 {
+			count = "1 object"
+		}
+		return fmt.Sprintf("  %10s  %-19s", utils.HumanSize(node.Size), count)
+	}
+	modified := ""
+	if node.LastModified != nil {
+		modified = node.LastModified.Local().Format(object.DDMMYYYYhhmmss)
+	}
+	return fmt.Sprintf("  %10s  %-19s  %s", utils.HumanSize(node.Size), modified, node.StorageClass)
+}
+
 func styledFileName(isDir, isSelected bool, name string) string {
 	if isSelected {
 		return constants.SelectedStyle(name)
@@ -144,41 +509,130 @@ func (f Tree) Init() tea.Cmd {
 	return nil
 }
 
-func InitTree(bucketName string) *Tree {
+// InitTree opens bucketName at path. A directory path opens that directory,
+// a file path opens its parent with the cursor on the file.
+func InitTree(bucketName, path string) (*Tree, error) {
+	objects, err := constants.Or.ListPrefix(bucketName, "")
+	if err != nil {
+		return nil, fmt.Errorf("[InitTree] %v", err)
+	}
+	return newTree(bucketName, path, visibleObjects(objects)), nil
+}
+
+// openTree shows the tree of bucketName at path, or stays on from with the
+// error when the bucket cannot be listed
+func openTree(bucketName, path string, from tea.Model) (tea.Model, tea.Cmd) {
+	tree, err := InitTree(bucketName, path)
+	if err != nil {
+		return from, func() tea.Msg { return errMsg{err} }
+	}
+	return tree.Update(constants.WindowSize)
+}
+
+// visibleObjects drops the trash prefix, which is browsed through the trash
+// view instead
+func visibleObjects(objects []object.Object) []object.Object {
+	visible := objects[:0]
+	for _, obj := range objects {
+		if !constants.Trash.Hides(obj.Key) {
+			visible = append(visible, obj)
+		}
+	}
+	return visible
+}
+
+func newTree(bucketName, path string, objects []object.Object) *Tree {
 	input := textinput.New()
 	input.Prompt = "$ "
 	input.Placeholder = "Object Key..."
 	input.CharLimit = 250
 	input.Width = 50
 
-	objects, err := constants.Or.ListObjects(bucketName)
-	if err != nil {
-		panic(err.Error())
+	filter := textinput.New()
+	filter.Prompt = "tags: "
+	filter.Placeholder = "pii=true"
+	filter.Width = 50
+
+	search := textinput.New()
+	search.Prompt = searchMode(false) + "/"
+	search.Placeholder = "part of a name"
+	search.Width = 50
+
+	upload := textinput.New()
+	upload.Prompt = "upload: "
+	upload.Placeholder = "path of a local file"
+	upload.Width = 50
+
+	root := tree.NewFileTree(nil)
+	for _, obj := range objects {
+		node := root.Root.InsertSized(obj.Key, obj.Size)
+		node.StorageClass = object.StorageClass(obj)
+		node.SetLastModified(obj.LastModified)
 	}
-	root := tree.NewFileTree(objects)
-	return &Tree{
+	root.Root.SortBy(constants.TreeSort)
+	t := &Tree{
 		BucketName: bucketName,
 		Root:       root.Root,
 		cursor:     0,
 		input:      input,
+		filter:     filter,
+		upload:     upload,
+		search:     search,
+	}
+	if node := root.Root.Find(path); node != nil && node != root.Root {
+		if node.IsDir {
+			t.Root = node
+		} else {
+			t.Root = node.Parent
+			for i, child := range t.Root.Children {
+				if child == node {
+					t.cursor = i
+				}
+			}
+		}
 	}
+	return t
 }
 
 func (f Tree) setupTree(bucketName string) tea.Msg {
-	tree := InitTree(bucketName)
+	tree, err := InitTree(bucketName, f.Root.Path())
+	if err != nil {
+		return errMsg{err}
+	}
 	return UpdatedTree(tree)
 }
 
-func getPath(n *tree.Node) string {
-	curr := n
-	path := []string{curr.Name}
-	for curr.Parent.Name != "" {
-		curr = curr.Parent
-		path = append(path, curr.Name)
-	}
-	var p string
-	for i := len(path) - 1; i >= 0; i-- {
-		p += path[i] + "/"
+// tagFilterCmd keeps the objects under the current directory whose tags
+// match filter
+func (f Tree) tagFilterCmd(filter object.TagFilter) tea.Cmd {
+	bucketName, path := f.BucketName, f.Root.Path()
+	return func() tea.Msg {
+		prefix := path
+		if prefix != "" {
+			prefix += "/"
+		}
+		objects, err := constants.Or.ListPrefix(bucketName, prefix)
+		if err != nil {
+			return errMsg{fmt.Errorf("[tagFilterCmd] %v", err)}
+		}
+		objects = visibleObjects(objects)
+		keys := make([]string, 0, len(objects))
+		for _, obj := range objects {
+			keys = append(keys, obj.Key)
+		}
+		matched, err := constants.Or.FilterByTags(bucketName, keys, filter)
+		if err != nil {
+			return errMsg{fmt.Errorf("[tagFilterCmd] %v", err)}
+		}
+		kept := objects[:0]
+		for _, obj := range objects {
+			if len(matched) > 0 && matched[0] == obj.Key {
+				kept = append(kept, obj)
+				matched = matched[1:]
+			}
+		}
+		t := newTree(bucketName, path, kept)
+		t.tagFilter = filter
+		return UpdatedTree(t)
 	}
-	return p[:len(p)-1]
 }
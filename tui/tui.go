@@ -1,18 +1,34 @@
 package tui
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
 
+	"github.com/Wondrous27/s3-tui/auth"
 	"github.com/Wondrous27/s3-tui/bucket"
 	"github.com/Wondrous27/s3-tui/object"
+	"github.com/Wondrous27/s3-tui/trash"
 	"github.com/Wondrous27/s3-tui/tui/constants"
 	tea "github.com/charmbracelet/bubbletea"
 )
 
-func StartTea(br *bucket.S3Repository, or *object.S3Repository) {
-	if f, err := tea.LogToFile("debug.log", "help"); err != nil {
+// Options configure how the TUI starts
+type Options struct {
+	LogFile string
+	// Trash receives deleted objects, nil deletes them permanently
+	Trash *trash.Trash
+	// Grep bounds the searches through the content of objects
+	Grep object.GrepOptions
+	// Bucket and Key open the tree or object view directly instead of the
+	// bucket list
+	Bucket string
+	Key    string
+}
+
+func StartTea(s *auth.Session, br *bucket.S3Repository, or *object.S3Repository, opts Options) {
+	if f, err := tea.LogToFile(opts.LogFile, "help"); err != nil {
 		fmt.Println("Couldn't open a file for logging:", err)
 		os.Exit(1)
 	} else {
@@ -23,18 +39,90 @@ func StartTea(br *bucket.S3Repository, or *object.S3Repository) {
 			}
 		}()
 	}
+	constants.Session = s
 	constants.Br = br
 	constants.Or = or
+	constants.Trash = opts.Trash
+	constants.Grep = opts.Grep
+	constants.Keymap.SetReadOnly(br.ReadOnly || or.ReadOnly)
 
-	m, err := InitBuckets()
-	if err != nil {
-		fmt.Println("Error initializing buckets", err)
+	start := InitBuckets
+	if opts.Bucket != "" {
+		start = InitLocation(opts.Bucket, opts.Key)
+	}
+	m, cmd := start()
+	if m == nil {
+		fmt.Println("Error initializing buckets", cmd())
 		os.Exit(1)
 	}
 
-	constants.P = tea.NewProgram(m, tea.WithAltScreen())
+	s.OnMFARequired = func() {
+		// The credentials are retrieved from commands as well as from Update,
+		// so the message is sent without waiting for the program to read it
+		if constants.P != nil {
+			go constants.P.Send(mfaRequiredMsg{})
+		}
+	}
+	constants.P = tea.NewProgram(app{model: m, init: cmd}, tea.WithAltScreen())
 	if _, err := constants.P.Run(); err != nil {
 		fmt.Println("Error running program:", err)
 		os.Exit(1)
 	}
 }
+
+type mfaRequiredMsg struct{}
+
+// app holds the current view, and prompts for an MFA token whenever the
+// credentials need one, going back to the view once the role is assumed.
+type app struct {
+	model tea.Model
+	// init is the command the first view was created with
+	init tea.Cmd
+}
+
+func (a app) Init() tea.Cmd {
+	return tea.Batch(a.model.Init(), a.init)
+}
+
+func (a app) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	if _, ok := msg.(mfaRequiredMsg); ok {
+		if _, ok := a.model.(MFA); ok {
+			return a, nil
+		}
+		previous := a.model
+		a.model, cmd = InitMFA(func() (tea.Model, tea.Cmd) {
+			return previous, nil
+		})
+		return a, cmd
+	}
+	a.model, cmd = a.model.Update(msg)
+	return a, cmd
+}
+
+func (a app) View() string {
+	return a.model.View()
+}
+
+// InitLocation opens the tree at a prefix of bucketName, or the object view
+// when key names an object.
+func InitLocation(bucketName, key string) func() (tea.Model, tea.Cmd) {
+	return func() (tea.Model, tea.Cmd) {
+		if constants.Session.Config.Region == "" {
+			return InitRegion(InitLocation(bucketName, key))
+		}
+		if constants.Session.NeedsMFA(context.TODO()) {
+			return InitMFA(InitLocation(bucketName, key))
+		}
+		tree, err := InitTree(bucketName, key)
+		if err != nil {
+			return nil, func() tea.Msg { return errMsg{err} }
+		}
+		if len(tree.Root.Children) > 0 {
+			if node := tree.Root.Children[tree.cursor]; !node.IsDir && node.Path() == key {
+				return InitObject(bucketName, key)
+			}
+		}
+		return tree.Update(constants.WindowSize)
+	}
+}
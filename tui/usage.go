@@ -0,0 +1,329 @@
+package tui
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/Wondrous27/s3-tui/object"
+	"github.com/Wondrous27/s3-tui/tree"
+	"github.com/Wondrous27/s3-tui/tui/constants"
+	"github.com/Wondrous27/s3-tui/utils"
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// What the usage view counts, one tree each
+const (
+	usageObjects = iota
+	usageVersions
+	usageUploads
+)
+
+var usageKinds = []struct{ name, unit string }{
+	usageObjects:  {"objects", "object"},
+	usageVersions: {"noncurrent versions", "version"},
+	usageUploads:  {"incomplete uploads", "upload"},
+}
+
+// Orders of the usage rows, cycled through with the sort key
+const (
+	usageBySize = iota
+	usageByCount
+	usageByName
+)
+
+var usageOrders = []string{"size", "count", "name"}
+
+const usageBarWidth = 20
+
+// usageProgressMsg is the number of keys listed by a page of the walk
+type usageProgressMsg int
+
+type usageReportMsg struct {
+	report *object.UsageReport
+	err    error
+}
+
+// usageRow is a child of the current directory, or the totals of another
+// kind under it when node is nil
+type usageRow struct {
+	name  string
+	node  *tree.Node
+	kind  int
+	size  int64
+	count int
+}
+
+// Usage shows what is stored under a prefix, ncdu style: the size and count
+// of every directory and file with a bar of its share, and the noncurrent
+// versions and incomplete uploads as lines of their own.
+type Usage struct {
+	bucketName string
+	// prefix is the directory walked, path the one shown below it
+	prefix string
+	path   string
+	trees  [3]*tree.FileTree
+	kind   int
+	order  int
+	cursor int
+	offset int
+	// listed counts the keys listed so far, until the report arrives
+	listed   int
+	loading  bool
+	error    string
+	next     func() (tea.Model, tea.Cmd)
+	quitting bool
+}
+
+// InitUsage walks bucketName under the directory prefix, the whole bucket
+// when it is empty
+func InitUsage(bucketName, prefix string, next func() (tea.Model, tea.Cmd)) (tea.Model, tea.Cmd) {
+	prefix = strings.Trim(prefix, "/")
+	m := Usage{bucketName: bucketName, prefix: prefix, path: prefix, loading: true, next: next}
+	return m, m.usageCmd()
+}
+
+func (m Usage) Init() tea.Cmd {
+	return nil
+}
+
+func (m Usage) usageCmd() tea.Cmd {
+	bucketName, prefix := m.bucketName, m.prefix
+	if prefix != "" {
+		prefix += "/"
+	}
+	return func() tea.Msg {
+		report, err := constants.Or.Usage(bucketName, prefix, func(listed int) {
+			constants.P.Send(usageProgressMsg(listed))
+		})
+		if err != nil {
+			return usageReportMsg{err: fmt.Errorf("[usageCmd] %v", err)}
+		}
+		return usageReportMsg{report: report}
+	}
+}
+
+// setReport builds a tree per kind, with the totals of every directory
+func (m *Usage) setReport(report *object.UsageReport) {
+	for i := range m.trees {
+		m.trees[i] = tree.NewFileTree(nil)
+	}
+	for _, obj := range report.Objects {
+		if !constants.Trash.Hides(obj.Key) {
+			m.trees[usageObjects].Root.InsertSized(obj.Key, obj.Size)
+		}
+	}
+	for _, version := range report.Versions {
+		m.trees[usageVersions].Root.InsertSized(version.Key, version.Size)
+	}
+	for _, upload := range report.Uploads {
+		m.trees[usageUploads].Root.InsertSized(upload.Key, upload.Size)
+	}
+}
+
+// dir returns the directory shown of a kind, nil when it stores nothing there
+func (m Usage) dir(kind int) *tree.Node {
+	if m.trees[kind] == nil {
+		return nil
+	}
+	return m.trees[kind].Root.Find(m.path)
+}
+
+// rows lists the children of the current directory in the chosen order,
+// after the totals of the other kinds when showing the objects
+func (m Usage) rows() []usageRow {
+	var rows []usageRow
+	if dir := m.dir(m.kind); dir != nil && dir.IsDir {
+		for _, child := range dir.Children {
+			name := child.Name
+			if child.IsDir {
+				name += "/"
+			}
+			rows = append(rows, usageRow{name: name, node: child, kind: m.kind, size: child.Size, count: child.Count})
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		switch {
+		case m.order == usageBySize && a.size != b.size:
+			return a.size > b.size
+		case m.order == usageByCount && a.count != b.count:
+			return a.count > b.count
+		}
+		return a.name < b.name
+	})
+	if m.kind != usageObjects {
+		return rows
+	}
+	var totals []usageRow
+	for _, kind := range []int{usageVersions, usageUploads} {
+		if dir := m.dir(kind); dir != nil && dir.Count > 0 {
+			totals = append(totals, usageRow{name: "[" + usageKinds[kind].name + "]", kind: kind, size: dir.Size, count: dir.Count})
+		}
+	}
+	return append(totals, rows...)
+}
+
+// height is how many rows fit between the header and the help
+func (m Usage) height() int {
+	return max(constants.WindowSize.Height-10, 3)
+}
+
+func (m *Usage) moveCursor(cursor int) {
+	rows := len(m.rows())
+	if rows == 0 {
+		m.cursor, m.offset = 0, 0
+		return
+	}
+	m.cursor = (cursor + rows) % rows
+	switch {
+	case m.cursor < m.offset:
+		m.offset = m.cursor
+	case m.cursor >= m.offset+m.height():
+		m.offset = m.cursor - m.height() + 1
+	}
+}
+
+// open moves into path, of the given kind
+func (m *Usage) open(kind int, path string) {
+	m.kind, m.path = kind, path
+	m.cursor, m.offset = 0, 0
+}
+
+func (m Usage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		constants.WindowSize = msg
+		m.moveCursor(m.cursor)
+
+	case usageProgressMsg:
+		m.listed += int(msg)
+
+	case usageReportMsg:
+		m.loading = false
+		if msg.err != nil {
+			m.error = msg.err.Error()
+			return m, nil
+		}
+		m.setReport(msg.report)
+
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, constants.Keymap.Quit):
+			m.quitting = true
+			return m, tea.Quit
+
+		case key.Matches(msg, constants.Keymap.Up):
+			m.moveCursor(m.cursor - 1)
+
+		case key.Matches(msg, constants.Keymap.Down):
+			m.moveCursor(m.cursor + 1)
+
+		case key.Matches(msg, constants.Keymap.Sort):
+			m.order = (m.order + 1) % len(usageOrders)
+			m.moveCursor(0)
+
+		case key.Matches(msg, constants.Keymap.Enter), key.Matches(msg, constants.Keymap.Next):
+			rows := m.rows()
+			if len(rows) == 0 {
+				return m, nil
+			}
+			row := rows[m.cursor]
+			switch {
+			case row.node == nil:
+				m.open(row.kind, m.path)
+			case row.node.IsDir:
+				m.open(m.kind, row.node.Path())
+			}
+
+		case key.Matches(msg, constants.Keymap.Back), key.Matches(msg, constants.Keymap.Prev):
+			switch {
+			case m.path != m.prefix:
+				m.open(m.kind, strings.TrimSuffix(path.Dir(m.path), "."))
+			case m.kind != usageObjects:
+				m.open(usageObjects, m.path)
+			default:
+				return m.next()
+			}
+		}
+	}
+	return m, nil
+}
+
+func (m Usage) View() string {
+	if m.quitting {
+		return ""
+	}
+	location := "s3://" + m.bucketName + "/" + m.path
+	if m.path != "" {
+		location += "/"
+	}
+	header := []string{"\n", fmt.Sprintf("Usage of %s", location)}
+	if m.loading {
+		header = append(header, "", constants.AlertStyle(fmt.Sprintf("listing... %d keys so far", m.listed)))
+		return constants.DocStyle.Render(lipgloss.JoinVertical(lipgloss.Left, append(header, constants.ErrStyle(m.error))...))
+	}
+
+	var totals []string
+	for kind, k := range usageKinds {
+		if dir := m.dir(kind); dir != nil {
+			totals = append(totals, fmt.Sprintf("%s: %s in %d", k.name, utils.HumanSize(dir.Size), dir.Count))
+		}
+	}
+	if len(totals) == 0 {
+		totals = []string{"nothing is stored here"}
+	}
+	header = append(header, constants.HelpStyle(strings.Join(totals, " • ")+", sorted by "+usageOrders[m.order]), "")
+	if m.kind != usageObjects {
+		header = append(header, constants.AlertStyle(usageKinds[m.kind].name+", esc goes back to the objects"), "")
+	}
+
+	rows := m.rows()
+	var total int64
+	for _, row := range rows {
+		total += row.size
+	}
+	lines := header
+	for i := m.offset; i < len(rows) && i < m.offset+m.height(); i++ {
+		lines = append(lines, m.rowView(rows[i], total, i == m.cursor))
+	}
+	lines = append(lines,
+		constants.ShortHelp(constants.Keymap.Enter, constants.Keymap.Sort, constants.Keymap.Back, constants.Keymap.Quit),
+		constants.ErrStyle(m.error),
+	)
+	return constants.DocStyle.Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+}
+
+func (m Usage) rowView(row usageRow, total int64, selected bool) string {
+	share := 0.0
+	if total > 0 {
+		share = float64(row.size) / float64(total)
+	}
+	filled := int(share*usageBarWidth + 0.5)
+	bar := "[" + strings.Repeat("#", filled) + strings.Repeat(" ", usageBarWidth-filled) + "]"
+	count := plural(row.count, usageKinds[row.kind].unit)
+
+	cursor := "  "
+	name := row.name
+	switch {
+	case selected:
+		cursor = "> "
+		name = constants.SelectedStyle(name)
+	case row.node == nil:
+		name = constants.AlertStyle(name)
+	case row.node.IsDir:
+		name = constants.DirStyle(name)
+	}
+	return fmt.Sprintf("%s%10s %s %5.1f%% %16s  %s", cursor, utils.HumanSize(row.size), bar, share*100, count, name)
+}
+
+// plural counts n of unit, adding an s unless there is exactly one
+func plural(n int, unit string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, unit)
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"os"
+	"strings"
+
+	"github.com/atotto/clipboard"
+	"github.com/aymanbagabas/go-osc52/v2"
+)
+
+// CopyToClipboard copies s with an OSC52 escape sequence, which reaches the
+// clipboard of the terminal the user sits at even over SSH, and also to the
+// local clipboard when there is one.
+func CopyToClipboard(s string) error {
+	seq := osc52.New(s)
+	switch {
+	case os.Getenv("TMUX") != "":
+		seq = seq.Tmux()
+	case strings.HasPrefix(os.Getenv("TERM"), "screen"):
+		seq = seq.Screen()
+	}
+	if _, err := seq.WriteTo(os.Stderr); err != nil {
+		return err
+	}
+	// Headless machines have no local clipboard, OSC52 already covered them
+	_ = clipboard.WriteAll(s)
+	return nil
+}
@@ -3,18 +3,26 @@ package utils
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 )
 
-func CreateTempFile(data, extension string) (*os.File, error) {
-	file, err := os.CreateTemp(os.TempDir(), fmt.Sprintf("*%s", extension))
+// CreateTempFile writes data to a new file in a private directory, readable
+// only by the user since it may hold decrypted content. RemoveTempFile
+// deletes it once it is no longer needed.
+func CreateTempFile(data, extension string) (string, error) {
+	dir, err := os.MkdirTemp("", "s3-tui-*")
 	if err != nil {
-		return nil, fmt.Errorf("unable to create new file: %w", err)
+		return "", fmt.Errorf("unable to create a private directory: %w", err)
 	}
-	// Write the data to the file if it is not empty
-	if data != "" {
-		if err := os.WriteFile(file.Name(), []byte(data), 0o666); err != nil {
-			return nil, fmt.Errorf("unable to write contents to file: %w", err)
-		}
+	path := filepath.Join(dir, "object"+extension)
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("unable to write contents to file: %w", err)
 	}
-	return file, nil
+	return path, nil
+}
+
+// RemoveTempFile deletes a file made by CreateTempFile and its directory
+func RemoveTempFile(path string) error {
+	return os.RemoveAll(filepath.Dir(path))
 }